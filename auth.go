@@ -0,0 +1,74 @@
+package goclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Authenticator adds credentials to an outgoing request. It runs once per
+// request before the before-request hooks, and again when a request is
+// retried after a 401.
+type Authenticator interface {
+	Apply(ctx context.Context, req *http.Request) error
+}
+
+// AuthenticatorFunc adapts an ordinary function to the Authenticator interface
+type AuthenticatorFunc func(ctx context.Context, req *http.Request) error
+
+// Apply implements Authenticator interface
+func (f AuthenticatorFunc) Apply(ctx context.Context, req *http.Request) error {
+	return f(ctx, req)
+}
+
+// BearerAuth authenticates with a bearer token from p
+func BearerAuth(p TokenProvider) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, req *http.Request) error {
+		token, err := p.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// BasicAuth authenticates with HTTP basic credentials
+func BasicAuth(username, password string) Authenticator {
+	return AuthenticatorFunc(func(_ context.Context, req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// SetAuth authenticates this request with a instead of the client's
+// credentials. A nil Authenticator restores the client's.
+func (r *request) SetAuth(a Authenticator) RequestBuilder {
+	r.auth = a
+	return r
+}
+
+// authenticate adds the credentials for this request to req. A request
+// Authenticator replaces all client credentials; otherwise the bearer token,
+// basic auth and Config.Authenticator are applied in that order.
+func (r *request) authenticate(req *http.Request) error {
+	if r.auth != nil {
+		return r.auth.Apply(r.ctx, req)
+	}
+
+	token, err := r.bearerToken()
+	if err != nil {
+		return fmt.Errorf("failed to get bearer token: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if r.client.basicAuth.Username != "" && r.client.basicAuth.Password != "" {
+		req.SetBasicAuth(r.client.basicAuth.Username, r.client.basicAuth.Password)
+	}
+
+	if r.client.auth != nil {
+		return r.client.auth.Apply(r.ctx, req)
+	}
+	return nil
+}
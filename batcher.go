@@ -0,0 +1,457 @@
+package goclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchCodec converts between individual requests and a provider batch call
+type BatchCodec interface {
+	// Encode builds the body and content type of a batch call. base is the
+	// batch endpoint, which request URLs may be expressed relative to.
+	Encode(base *url.URL, reqs []*http.Request) (body []byte, contentType string, err error)
+	// Decode splits a batch response into one response per request, in request order
+	Decode(resp *http.Response, reqs []*http.Request) ([]*http.Response, error)
+}
+
+// BatcherConfig configures a Batcher
+type BatcherConfig struct {
+	// Endpoint is the absolute URL of the provider batch endpoint,
+	// e.g. https://graph.microsoft.com/v1.0/$batch
+	Endpoint string
+	// Codec defaults to GraphBatchCodec
+	Codec BatchCodec
+	// MaxBatchSize defaults to 20, the Microsoft Graph limit
+	MaxBatchSize int
+	// Window is how long the first queued request waits for company, default 10ms
+	Window time.Duration
+	// Transport sends the batch calls, default http.DefaultTransport
+	Transport http.RoundTripper
+	// Header is sent on every batch call. Requests are only batched with
+	// requests carrying the same credential headers, and their shared
+	// Authorization header is forwarded when Header does not set one.
+	Header http.Header
+}
+
+// Batcher is an http.RoundTripper that packs concurrent requests into a
+// single call to a provider batch endpoint and hands each caller its own
+// response. Use it as Config.Interceptor or with RequestBuilder.WithTransport.
+type Batcher struct {
+	cfg      BatcherConfig
+	endpoint *url.URL
+
+	mu      sync.Mutex
+	pending map[string][]*batchItem
+	timer   *time.Timer
+}
+
+type batchItem struct {
+	req  *http.Request
+	done chan batchResult
+}
+
+type batchResult struct {
+	resp *http.Response
+	err  error
+}
+
+// NewBatcher creates a Batcher for the given provider endpoint
+func NewBatcher(cfg BatcherConfig) (*Batcher, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || !endpoint.IsAbs() {
+		return nil, fmt.Errorf("batch endpoint must be an absolute URL: %q", cfg.Endpoint)
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = GraphBatchCodec{}
+	}
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = 20
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Millisecond
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+	return &Batcher{cfg: cfg, endpoint: endpoint}, nil
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (b *Batcher) RoundTrip(req *http.Request) (*http.Response, error) {
+	item := &batchItem{req: req, done: make(chan batchResult, 1)}
+	key := credentialKey(req.Header)
+
+	b.mu.Lock()
+	if b.pending == nil {
+		b.pending = make(map[string][]*batchItem)
+	}
+	b.pending[key] = append(b.pending[key], item)
+	if len(b.pending[key]) >= b.cfg.MaxBatchSize {
+		items := b.pending[key]
+		delete(b.pending, key)
+		b.mu.Unlock()
+		go b.send(items)
+	} else {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.cfg.Window, b.flush)
+		}
+		b.mu.Unlock()
+	}
+
+	select {
+	case res := <-item.done:
+		return res.resp, res.err
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+// Flush sends any queued requests immediately
+func (b *Batcher) Flush() {
+	b.flush()
+}
+
+func (b *Batcher) flush() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+
+	for _, items := range pending {
+		b.send(items)
+	}
+}
+
+// credentialKey identifies the credentials of a request. The batch call
+// carries a single Authorization header, so requests are only batched with
+// requests sending the same credentials.
+func credentialKey(header http.Header) string {
+	var b strings.Builder
+	for _, name := range []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"} {
+		b.WriteString(name)
+		for _, v := range header.Values(name) {
+			b.WriteByte('\x00')
+			b.WriteString(v)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (b *Batcher) send(items []*batchItem) {
+	// Callers that gave up have already returned
+	live := items[:0]
+	for _, item := range items {
+		if item.req.Context().Err() == nil {
+			live = append(live, item)
+		}
+	}
+	items = live
+	if len(items) == 0 {
+		return
+	}
+
+	reqs := make([]*http.Request, len(items))
+	for i, item := range items {
+		reqs[i] = item.req
+	}
+
+	resps, err := b.roundTrip(reqs)
+	for i, item := range items {
+		if err != nil {
+			item.done <- batchResult{err: err}
+			continue
+		}
+		item.done <- batchResult{resp: resps[i]}
+	}
+}
+
+func (b *Batcher) roundTrip(reqs []*http.Request) ([]*http.Response, error) {
+	body, contentType, err := b.cfg.Codec.Encode(b.endpoint, reqs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	// The batch outlives no single caller, so it must not be cancelled by one
+	ctx := context.WithoutCancel(reqs[0].Context())
+	outer, err := http.NewRequestWithContext(ctx, http.MethodPost, b.endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batch request: %w", err)
+	}
+	for k, v := range b.cfg.Header {
+		outer.Header[k] = append([]string(nil), v...)
+	}
+	if outer.Header.Get("Authorization") == "" {
+		if auth := reqs[0].Header.Get("Authorization"); auth != "" {
+			outer.Header.Set("Authorization", auth)
+		}
+	}
+	outer.Header.Set("Content-Type", contentType)
+
+	resp, err := b.cfg.Transport.RoundTrip(outer)
+	if err != nil {
+		return nil, fmt.Errorf("batch request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("batch request failed with status code %d", resp.StatusCode)
+	}
+
+	resps, err := b.cfg.Codec.Decode(resp, reqs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode batch response: %w", err)
+	}
+	if len(resps) != len(reqs) {
+		return nil, fmt.Errorf("batch response has %d responses for %d requests", len(resps), len(reqs))
+	}
+	return resps, nil
+}
+
+// relativeURL expresses u relative to the directory of the batch endpoint,
+// e.g. /me for https://graph.microsoft.com/v1.0/me and a /v1.0/$batch endpoint
+func relativeURL(base, u *url.URL) string {
+	root := path.Dir(base.Path)
+	p := u.Path
+	if u.Host == base.Host && root != "/" && strings.HasPrefix(p, root+"/") {
+		p = strings.TrimPrefix(p, root)
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return p
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
+
+// GraphBatchCodec encodes batches in the Microsoft Graph JSON batching format
+type GraphBatchCodec struct{}
+
+type graphBatchRequest struct {
+	ID      string            `json:"id"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+type graphBatchResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Encode implements BatchCodec
+func (GraphBatchCodec) Encode(base *url.URL, reqs []*http.Request) ([]byte, string, error) {
+	payload := struct {
+		Requests []graphBatchRequest `json:"requests"`
+	}{}
+
+	for i, req := range reqs {
+		item := graphBatchRequest{
+			ID:      strconv.Itoa(i + 1),
+			Method:  req.Method,
+			URL:     relativeURL(base, req.URL),
+			Headers: make(map[string]string),
+		}
+		for k, v := range req.Header {
+			if k != "Authorization" {
+				item.Headers[k] = strings.Join(v, ", ")
+			}
+		}
+
+		body, err := readRequestBody(req)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(body) > 0 {
+			if json.Valid(body) {
+				item.Body = body
+			} else {
+				encoded, _ := json.Marshal(base64.StdEncoding.EncodeToString(body))
+				item.Body = encoded
+			}
+		}
+		payload.Requests = append(payload.Requests, item)
+	}
+
+	body, err := json.Marshal(payload)
+	return body, "application/json", err
+}
+
+// Decode implements BatchCodec
+func (GraphBatchCodec) Decode(resp *http.Response, reqs []*http.Request) ([]*http.Response, error) {
+	var payload struct {
+		Responses []graphBatchResponse `json:"responses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	out := make([]*http.Response, len(reqs))
+	for _, item := range payload.Responses {
+		i, err := strconv.Atoi(item.ID)
+		if err != nil || i < 1 || i > len(reqs) {
+			return nil, fmt.Errorf("unexpected response id %q", item.ID)
+		}
+
+		header := make(http.Header)
+		for k, v := range item.Headers {
+			header.Set(k, v)
+		}
+
+		body := []byte(item.Body)
+		var encoded string
+		if !strings.Contains(header.Get("Content-Type"), "json") && json.Unmarshal(body, &encoded) == nil {
+			if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+				body = decoded
+			}
+		}
+
+		out[i-1] = newBatchResponse(reqs[i-1], item.Status, header, body)
+	}
+
+	for i, r := range out {
+		if r == nil {
+			return nil, fmt.Errorf("missing response for request %d", i+1)
+		}
+	}
+	return out, nil
+}
+
+// MultipartBatchCodec encodes batches as multipart/mixed documents whose parts
+// are application/http messages, as used by OData and Google APIs
+type MultipartBatchCodec struct{}
+
+// Encode implements BatchCodec
+func (MultipartBatchCodec) Encode(base *url.URL, reqs []*http.Request) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	for i, req := range reqs {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"application/http"},
+			"Content-Transfer-Encoding": {"binary"},
+			"Content-Id":                {strconv.Itoa(i + 1)},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		body, err := readRequestBody(req)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if _, err := fmt.Fprintf(part, "%s %s HTTP/1.1\r\n", req.Method, relativeURL(base, req.URL)); err != nil {
+			return nil, "", err
+		}
+		header := req.Header.Clone()
+		header.Del("Authorization")
+		if len(body) > 0 {
+			header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		if err := header.Write(part); err != nil {
+			return nil, "", err
+		}
+		if _, err := io.WriteString(part, "\r\n"); err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(body); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "multipart/mixed; boundary=" + mw.Boundary(), nil
+}
+
+// Decode implements BatchCodec
+func (MultipartBatchCodec) Decode(resp *http.Response, reqs []*http.Request) ([]*http.Response, error) {
+	parts, err := NewPartReader(resp.Header.Get("Content-Type"), resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*http.Response, len(reqs))
+	for i := 0; ; i++ {
+		part, err := parts.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Content-ID is echoed back as "response-<id>" by some providers
+		index := i
+		if id := strings.TrimPrefix(strings.Trim(part.Header.Get("Content-Id"), "<>"), "response-"); id != "" {
+			if n, err := strconv.Atoi(id); err == nil {
+				index = n - 1
+			}
+		}
+		if index < 0 || index >= len(reqs) {
+			return nil, fmt.Errorf("unexpected part %d in batch response", index+1)
+		}
+
+		inner, err := http.ReadResponse(bufio.NewReader(part.Body), reqs[index])
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(inner.Body)
+		inner.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		out[index] = newBatchResponse(reqs[index], inner.StatusCode, inner.Header, body)
+	}
+
+	for i, r := range out {
+		if r == nil {
+			return nil, fmt.Errorf("missing response for request %d", i+1)
+		}
+	}
+	return out, nil
+}
+
+func newBatchResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
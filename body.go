@@ -0,0 +1,86 @@
+package goclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrBodyNotReplayable is returned when a request has to be sent again but its
+// body is a one-shot stream without a rewind function
+var ErrBodyNotReplayable = errors.New("request body is a one-shot stream and cannot be replayed")
+
+// RewindBody resets req.Body so the request can be sent again. Buffered
+// bodies are always replayable; streams set with SetBodyStream are only
+// replayable when a rewind function was supplied. Middlewares that resend
+// requests should call it before every attempt after the first.
+func RewindBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		return ErrBodyNotReplayable
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	req.Body = body
+	return nil
+}
+
+// limitedBody fails reads once more than limit bytes have been streamed
+type limitedBody struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return n, &RequestTooLargeError{Limit: l.limit, Size: l.n}
+	}
+	return n, err
+}
+
+// readCloser pairs a reader with the Close of the body it reads from
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// streamReader applies the client's request size limit to a streamed body.
+// A body that can be closed stays closable, so the transport releases it
+// even when the request fails part way.
+func (r *request) streamReader(body io.Reader) io.Reader {
+	if limit := r.client.maxRequestBytes; limit > 0 {
+		limited := &limitedBody{r: body, limit: limit}
+		if c, ok := body.(io.Closer); ok {
+			return readCloser{limited, c}
+		}
+		return limited
+	}
+	return body
+}
+
+// getBody returns a GetBody function for streamed bodies, or nil when the
+// stream cannot be rewound
+func (r *request) getBody() func() (io.ReadCloser, error) {
+	if r.bodyRewind == nil {
+		return nil
+	}
+	return func() (io.ReadCloser, error) {
+		body, err := r.bodyRewind()
+		if err != nil {
+			return nil, err
+		}
+		if rc, ok := r.streamReader(body).(io.ReadCloser); ok {
+			return rc, nil
+		}
+		return io.NopCloser(r.streamReader(body)), nil
+	}
+}
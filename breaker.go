@@ -0,0 +1,189 @@
+package goclient
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for requests to a host whose circuit is open
+var ErrCircuitOpen = errors.New("goclient: circuit breaker is open")
+
+// CircuitState is the state of a host's circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through and counts consecutive failures
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects requests with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe requests through
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig enables a circuit breaker per host. Transport errors
+// and 5xx responses count as failures.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit, default 5
+	FailureThreshold int
+	// OpenDuration is how long the circuit stays open before probing, default 30s
+	OpenDuration time.Duration
+	// HalfOpenProbes is the number of successful probes that close the circuit, default 1
+	HalfOpenProbes int
+	// OnStateChange is called after a host's circuit changes state
+	OnStateChange func(host string, from, to CircuitState)
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	inFlight int
+	probes   int
+}
+
+// circuitBreaker tracks one circuit per host
+type circuitBreaker struct {
+	cfg   CircuitBreakerConfig
+	clock Clock
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig, clock Clock) *circuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = 30 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	return &circuitBreaker{cfg: cfg, clock: clock, circuits: make(map[string]*circuit)}
+}
+
+// transition changes the state of c and returns a callback reporting it,
+// to be run once the lock is released
+func (b *circuitBreaker) transition(host string, c *circuit, to CircuitState) func() {
+	from := c.state
+	c.state = to
+	c.failures = 0
+	c.probes = 0
+	if to == CircuitOpen {
+		c.openedAt = b.clock.Now()
+	}
+
+	if b.cfg.OnStateChange == nil || from == to {
+		return func() {}
+	}
+	return func() { b.cfg.OnStateChange(host, from, to) }
+}
+
+// allow reports whether a request to host may be sent
+func (b *circuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{}
+		b.circuits[host] = c
+	}
+
+	notify := func() {}
+	if c.state == CircuitOpen && b.clock.Now().Sub(c.openedAt) >= b.cfg.OpenDuration {
+		notify = b.transition(host, c, CircuitHalfOpen)
+	}
+
+	var err error
+	switch {
+	case c.state == CircuitOpen:
+		err = ErrCircuitOpen
+	case c.state == CircuitHalfOpen && c.inFlight >= b.cfg.HalfOpenProbes:
+		err = ErrCircuitOpen
+	default:
+		c.inFlight++
+	}
+	b.mu.Unlock()
+
+	notify()
+	return err
+}
+
+// release returns the slot of a request allowed by allow that ended
+// without an outcome
+func (b *circuitBreaker) release(host string) {
+	b.mu.Lock()
+	b.circuits[host].inFlight--
+	b.mu.Unlock()
+}
+
+// record reports the outcome of a request allowed by allow
+func (b *circuitBreaker) record(host string, failed bool) {
+	b.mu.Lock()
+	c := b.circuits[host]
+	c.inFlight--
+
+	notify := func() {}
+	switch c.state {
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+		} else if c.failures++; c.failures >= b.cfg.FailureThreshold {
+			notify = b.transition(host, c, CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			notify = b.transition(host, c, CircuitOpen)
+		} else if c.probes++; c.probes >= b.cfg.HalfOpenProbes {
+			notify = b.transition(host, c, CircuitClosed)
+		}
+	}
+	b.mu.Unlock()
+
+	notify()
+}
+
+func (b *circuitBreaker) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		host := req.URL.Host
+		if err := b.allow(host); err != nil {
+			return nil, err
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil && req.Context().Err() != nil {
+			// The caller gave up, which says nothing about the host
+			b.release(host)
+			return resp, err
+		}
+		b.record(host, err != nil || resp.StatusCode >= 500)
+		return resp, err
+	})
+}
+
+// states returns the state of every host's circuit
+func (b *circuitBreaker) states() map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	states := make(map[string]string, len(b.circuits))
+	for host, c := range b.circuits {
+		states[host] = c.state.String()
+	}
+	return states
+}
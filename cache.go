@@ -0,0 +1,499 @@
+package goclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache gives access to the client's response cache
+type Cache interface {
+	// Invalidate removes every cached entry whose URL path matches pattern.
+	// Patterns use path.Match syntax, e.g. "/users/*". It returns the number
+	// of entries removed.
+	Invalidate(pattern string) int
+	// Clear removes every cached entry
+	Clear()
+	// Len reports the number of cached entries
+	Len() int
+}
+
+// CacheEntry is a cached response together with its freshness and validators
+type CacheEntry struct {
+	Response *Response
+	Expires  time.Time
+	// StaleUntil is the end of the window in which the stale entry is still
+	// served while it is refreshed in the background
+	StaleUntil   time.Time
+	ETag         string
+	LastModified string
+	// Vary holds the request headers named by the response's Vary header;
+	// the entry only serves requests carrying the same values
+	Vary http.Header
+}
+
+// CacheStore persists cache entries keyed by request URL. Requests carrying
+// credentials get a fingerprint of them as the key's fragment. Implementations
+// must be safe for concurrent use; a store may drop entries at any time.
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// memoryCacheStore is the default in-memory CacheStore
+type memoryCacheStore struct {
+	mu      sync.RWMutex
+	entries map[string]*CacheEntry
+}
+
+// NewMemoryCacheStore returns an in-memory CacheStore
+func NewMemoryCacheStore() CacheStore {
+	return &memoryCacheStore{entries: make(map[string]*CacheEntry)}
+}
+
+func (s *memoryCacheStore) Get(key string) (*CacheEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[key]
+	return entry, ok
+}
+
+func (s *memoryCacheStore) Set(key string, entry *CacheEntry) {
+	s.mu.Lock()
+	s.entries[key] = entry
+	s.mu.Unlock()
+}
+
+func (s *memoryCacheStore) Delete(key string) {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+}
+
+// responseCache stores successful GET responses keyed by their full URL and
+// the credentials they were fetched with.
+// With a plain TTL every response is fresh for ttl. With HTTP semantics the
+// response's Cache-Control and Expires headers decide, ttl is the fallback
+// lifetime, and stale entries carrying an ETag or Last-Modified validator
+// are revalidated with a conditional request.
+type responseCache struct {
+	ttl   time.Duration
+	http  bool
+	swr   time.Duration
+	clock Clock
+	store CacheStore
+
+	// keys indexes the entries this client stored so they can be
+	// invalidated and counted without the store supporting iteration
+	mu         sync.RWMutex
+	keys       map[string]struct{}
+	refreshing map[string]bool
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// cacheState classifies the entry found for a request
+type cacheState int
+
+const (
+	cacheMiss cacheState = iota
+	// cacheFresh entries are served without contacting the server
+	cacheFresh
+	// cacheStale entries are served while they are refreshed in the background
+	cacheStale
+	// cacheRevalidate entries are sent to the server as a conditional request
+	cacheRevalidate
+)
+
+func newResponseCache(ttl time.Duration, httpSemantics bool, swr time.Duration, store CacheStore, clock Clock) *responseCache {
+	if store == nil {
+		store = NewMemoryCacheStore()
+	}
+	return &responseCache{
+		ttl:        ttl,
+		http:       httpSemantics,
+		swr:        swr,
+		clock:      clock,
+		store:      store,
+		keys:       make(map[string]struct{}),
+		refreshing: make(map[string]bool),
+	}
+}
+
+func (c *responseCache) enabled() bool {
+	return c != nil && (c.ttl > 0 || c.http)
+}
+
+// key returns the cache key for req: its URL, with a fingerprint of any
+// credentials it carries as the fragment so that clones of a client sharing
+// the cache never serve one caller's response to another
+func (c *responseCache) key(req *http.Request) string {
+	u := *req.URL
+	u.Fragment, u.RawFragment = "", ""
+	if hasCredentials(req.Header) {
+		sum := sha256.Sum256([]byte(credentialKey(req.Header)))
+		u.Fragment = hex.EncodeToString(sum[:16])
+	}
+	return u.String()
+}
+
+func hasCredentials(h http.Header) bool {
+	for _, name := range []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"} {
+		if h.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the entry cached for key and how it may be used given the
+// request's header. Expired entries are dropped unless they are within their
+// stale-while-revalidate window or can be revalidated.
+func (c *responseCache) lookup(key string, header http.Header) (*CacheEntry, cacheState) {
+	if !c.enabled() {
+		return nil, cacheMiss
+	}
+
+	entry, ok := c.store.Get(key)
+	if !ok {
+		c.forget(key)
+		c.misses.Add(1)
+		return nil, cacheMiss
+	}
+	if !entry.matches(header) {
+		c.misses.Add(1)
+		return nil, cacheMiss
+	}
+
+	now := c.clock.Now()
+	if now.Before(entry.Expires) {
+		c.hits.Add(1)
+		return entry, cacheFresh
+	}
+	if now.Before(entry.StaleUntil) {
+		c.hits.Add(1)
+		return entry, cacheStale
+	}
+	c.misses.Add(1)
+	if c.http && (entry.ETag != "" || entry.LastModified != "") {
+		return entry, cacheRevalidate
+	}
+
+	c.delete(key)
+	return nil, cacheMiss
+}
+
+// startRefresh reports whether the caller should refresh key in the
+// background, ensuring only one refresh per key runs at a time
+func (c *responseCache) startRefresh(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refreshing[key] {
+		return false
+	}
+	c.refreshing[key] = true
+	return true
+}
+
+func (c *responseCache) endRefresh(key string) {
+	c.mu.Lock()
+	delete(c.refreshing, key)
+	c.mu.Unlock()
+}
+
+// set stores resp for key. header is the request's header, from which the
+// values named by the response's Vary header are recorded.
+func (c *responseCache) set(key string, header http.Header, resp *Response) {
+	if !c.enabled() {
+		return
+	}
+	vary, ok := varyValues(resp.Headers, header)
+	if !ok {
+		c.delete(key)
+		return
+	}
+
+	entry := &CacheEntry{
+		Response: resp.clone(),
+		Expires:  c.clock.Now().Add(c.ttl),
+		Vary:     vary,
+	}
+	entry.Response.FromCache = false
+
+	swr := c.swr
+	if c.http {
+		lifetime, store := freshness(resp.Headers, c.clock.Now(), c.ttl)
+		entry.Expires = c.clock.Now().Add(lifetime)
+		entry.ETag = resp.Headers.Get("ETag")
+		entry.LastModified = resp.Headers.Get("Last-Modified")
+		if window, ok := staleWhileRevalidate(resp.Headers); ok {
+			swr = window
+		}
+		if !store || (lifetime <= 0 && swr <= 0 && entry.ETag == "" && entry.LastModified == "") {
+			c.delete(key)
+			return
+		}
+	}
+	entry.StaleUntil = entry.Expires.Add(swr)
+
+	c.store.Set(key, entry)
+	c.mu.Lock()
+	c.keys[key] = struct{}{}
+	c.mu.Unlock()
+}
+
+// refreshInBackground re-sends this GET without consulting the cache so the
+// stale entry for key is replaced once the server answers. The refresh
+// carries everything that shapes the request on the wire, so it reaches the
+// same URL with the same credentials and is stored under the same key.
+func (r *request) refreshInBackground(key string) {
+	cache := r.client.cache
+	if !cache.startRefresh(key) {
+		return
+	}
+
+	refresh := r.client.newRequest(context.WithoutCancel(r.ctx), r.method, r.endpoint)
+	refresh.skipCache = true
+	refresh.headers = r.headers.Clone()
+	refresh.addedHeaders = r.addedHeaders.Clone()
+	refresh.defaultHeaders = copyStringMap(r.defaultHeaders)
+	refresh.pathParams = copyStringMap(r.pathParams)
+	refresh.queryParams = url.Values(http.Header(r.queryParams).Clone())
+	refresh.queryErr = r.queryErr
+	refresh.transport = r.transport
+	refresh.middlewares = slices.Clone(r.middlewares)
+	refresh.meta = r.meta
+	refresh.retry = r.retry
+	refresh.bypassLimit = r.bypassLimit
+	refresh.routingKey = r.routingKey
+	refresh.localAddr = r.localAddr
+	refresh.proxy = r.proxy
+	refresh.tokenProvider = r.tokenProvider
+	refresh.auth = r.auth
+
+	go func() {
+		defer cache.endRefresh(key)
+		_, _ = refresh.Result()
+	}()
+}
+
+// varyValues returns the request header values named by the response's Vary
+// header. ok is false for Vary: *, which no later request can match.
+func varyValues(respHeader, reqHeader http.Header) (vary http.Header, ok bool) {
+	for _, value := range respHeader.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name == "" {
+				continue
+			}
+			if vary == nil {
+				vary = make(http.Header)
+			}
+			vary[http.CanonicalHeaderKey(name)] = reqHeader.Values(name)
+		}
+	}
+	return vary, true
+}
+
+// matches reports whether a request with header selects the entry's variant
+func (e *CacheEntry) matches(header http.Header) bool {
+	for name, want := range e.Vary {
+		if !slices.Equal(header.Values(name), want) {
+			return false
+		}
+	}
+	return true
+}
+
+// revalidated merges the headers of a 304 response into the stale entry's
+// response and returns it
+func (e *CacheEntry) revalidated(header http.Header) *Response {
+	resp := e.Response.clone()
+	for k, v := range header {
+		resp.Headers[k] = v
+	}
+	resp.FromCache = true
+	return resp
+}
+
+// addValidators makes req conditional on entry unless the caller already did
+func (e *CacheEntry) addValidators(h http.Header) {
+	if e.ETag != "" && h.Get("If-None-Match") == "" {
+		h.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" && h.Get("If-Modified-Since") == "" {
+		h.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+// freshness computes how long a response stays fresh from its
+// Cache-Control, Age and Expires headers, falling back to ttl. store is
+// false when the response must not be cached at all.
+func freshness(h http.Header, now time.Time, ttl time.Duration) (lifetime time.Duration, store bool) {
+	directives := parseCacheControl(h.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return 0, true
+	}
+
+	var age time.Duration
+	if secs, err := strconv.Atoi(h.Get("Age")); err == nil && secs > 0 {
+		age = time.Duration(secs) * time.Second
+	}
+
+	if maxAge, ok := directives["max-age"]; ok {
+		secs, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return 0, true
+		}
+		return time.Duration(secs)*time.Second - age, true
+	}
+
+	if expires := h.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, true
+		}
+		date := now
+		if d, err := http.ParseTime(h.Get("Date")); err == nil {
+			date = d
+		}
+		return t.Sub(date) - age, true
+	}
+
+	return ttl, true
+}
+
+// staleWhileRevalidate returns the stale-while-revalidate window of a
+// response (RFC 5861)
+func staleWhileRevalidate(h http.Header) (time.Duration, bool) {
+	value, ok := parseCacheControl(h.Get("Cache-Control"))["stale-while-revalidate"]
+	if !ok {
+		return 0, false
+	}
+	secs, err := strconv.Atoi(value)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// parseCacheControl splits a Cache-Control header into lower-cased
+// directives and their unquoted values
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return directives
+}
+
+func (c *responseCache) delete(key string) {
+	c.store.Delete(key)
+	c.forget(key)
+}
+
+func (c *responseCache) forget(key string) {
+	c.mu.Lock()
+	delete(c.keys, key)
+	c.mu.Unlock()
+}
+
+func (c *responseCache) Invalidate(pattern string) int {
+	return c.removeWhere(func(p string) bool {
+		matched, err := path.Match(pattern, p)
+		return err == nil && matched
+	})
+}
+
+func (c *responseCache) Clear() {
+	c.removeWhere(func(string) bool { return true })
+}
+
+func (c *responseCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.keys)
+}
+
+// invalidateResource drops cached GETs for the resource at u and for its
+// parent collection, which a successful write to u is likely to have changed
+func (c *responseCache) invalidateResource(u *url.URL) {
+	target := strings.TrimSuffix(u.Path, "/")
+	parent := path.Dir(target)
+
+	c.removeWhere(func(p string) bool {
+		p = strings.TrimSuffix(p, "/")
+		return p == target || p == parent
+	}, u.Host)
+}
+
+// removeWhere deletes entries whose URL path satisfies match. When hosts are
+// given only entries for those hosts are considered.
+func (c *responseCache) removeWhere(match func(path string) bool, hosts ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key := range c.keys {
+		u, err := url.Parse(key)
+		if err != nil {
+			continue
+		}
+		if len(hosts) > 0 && !containsString(hosts, u.Host) {
+			continue
+		}
+		if match(u.Path) {
+			c.store.Delete(key)
+			delete(c.keys, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isCacheableMethod reports whether responses to method may be cached
+func isCacheableMethod(method string) bool {
+	return method == http.MethodGet
+}
+
+// isInvalidatingMethod reports whether a successful request with method
+// modifies the target resource
+func isInvalidatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,45 @@
+package goclient
+
+import "net/http"
+
+// capture copies a value from a response into a global request header
+type capture struct {
+	header string
+	cookie string
+	as     string
+}
+
+// CaptureHeader takes responseHeader from this request's successful response
+// and sends its value as requestHeader on every subsequent request of the
+// client, for session tokens handed out by an auth or upload handshake.
+func (r *request) CaptureHeader(responseHeader, requestHeader string) RequestBuilder {
+	r.captures = append(r.captures, capture{header: responseHeader, as: requestHeader})
+	return r
+}
+
+// CaptureCookie takes the cookie name set by this request's successful
+// response and sends its value as requestHeader on subsequent requests
+func (r *request) CaptureCookie(name, requestHeader string) RequestBuilder {
+	r.captures = append(r.captures, capture{cookie: name, as: requestHeader})
+	return r
+}
+
+// applyCaptures stores the captured values as global headers. Values missing
+// from the response leave any previously captured value in place.
+func (r *request) applyCaptures(headers http.Header) {
+	for _, c := range r.captures {
+		value := headers.Get(c.header)
+		if c.cookie != "" {
+			value = ""
+			resp := http.Response{Header: headers}
+			for _, cookie := range resp.Cookies() {
+				if cookie.Name == c.cookie {
+					value = cookie.Value
+				}
+			}
+		}
+		if value != "" {
+			r.client.SetGlobalHeader(c.as, value)
+		}
+	}
+}
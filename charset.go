@@ -0,0 +1,212 @@
+package goclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrUnsupportedCharset is returned when a response declares a charset
+// without a registered decoder
+var ErrUnsupportedCharset = errors.New("unsupported charset")
+
+// CharsetDecoder converts a body in some charset to UTF-8
+type CharsetDecoder func(body []byte) ([]byte, error)
+
+var charsets = struct {
+	sync.RWMutex
+	decoders map[string]CharsetDecoder
+}{decoders: map[string]CharsetDecoder{
+	"utf-8":        decodeUTF8,
+	"utf8":         decodeUTF8,
+	"us-ascii":     decodeUTF8,
+	"ascii":        decodeUTF8,
+	"iso-8859-1":   decodeLatin1,
+	"iso8859-1":    decodeLatin1,
+	"latin1":       decodeLatin1,
+	"windows-1252": decodeWindows1252,
+	"cp1252":       decodeWindows1252,
+	"utf-16":       decodeUTF16,
+	"utf-16le":     decodeUTF16LE,
+	"utf-16be":     decodeUTF16BE,
+}}
+
+// RegisterCharset installs a decoder for a charset name, case-insensitively,
+// replacing any existing one. Use it to plug in decoders such as Shift_JIS
+// from golang.org/x/text:
+//
+//	goclient.RegisterCharset("shift_jis", func(b []byte) ([]byte, error) {
+//		return japanese.ShiftJIS.NewDecoder().Bytes(b)
+//	})
+func RegisterCharset(name string, decode CharsetDecoder) {
+	charsets.Lock()
+	defer charsets.Unlock()
+	charsets.decoders[strings.ToLower(name)] = decode
+}
+
+func charsetDecoder(name string) (CharsetDecoder, bool) {
+	charsets.RLock()
+	defer charsets.RUnlock()
+	decode, ok := charsets.decoders[strings.ToLower(name)]
+	return decode, ok
+}
+
+// CharsetDetector converts a response body to UTF-8 before Into decodes it as
+// JSON, choosing the source charset from contentType and the body itself.
+// Install one with Config.CharsetDetector to override DetectCharset, for
+// example for servers that mislabel their charset.
+type CharsetDetector func(contentType string, body []byte) ([]byte, error)
+
+// DetectCharset is the default CharsetDetector. It decodes the charset
+// declared in contentType and strips or honours a byte order mark.
+func DetectCharset(contentType string, body []byte) ([]byte, error) {
+	return toUTF8(contentType, body)
+}
+
+// Text returns the body as a UTF-8 string, decoding the charset declared in
+// Content-Type. Without a declared charset a byte order mark selects UTF-8
+// or UTF-16; a UTF-8 BOM is always stripped.
+func (r *Response) Text() (string, error) {
+	body, err := toUTF8(r.Headers.Get("Content-Type"), r.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// toUTF8 converts body to UTF-8 according to contentType and its BOM
+func toUTF8(contentType string, body []byte) ([]byte, error) {
+	name := ""
+	if contentType != "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			name = strings.Trim(params["charset"], `"`)
+		}
+	}
+
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		if name == "" || isUTF8Name(name) {
+			return body[3:], nil
+		}
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}), bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		if name == "" || strings.HasPrefix(strings.ToLower(name), "utf-16") {
+			return decodeUTF16(body)
+		}
+	}
+	if name == "" {
+		return body, nil
+	}
+
+	decode, ok := charsetDecoder(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCharset, name)
+	}
+	return decode(body)
+}
+
+func isUTF8Name(name string) bool {
+	name = strings.ToLower(name)
+	return name == "utf-8" || name == "utf8"
+}
+
+func decodeUTF8(body []byte) ([]byte, error) {
+	return bytes.TrimPrefix(body, []byte{0xEF, 0xBB, 0xBF}), nil
+}
+
+func decodeLatin1(body []byte) ([]byte, error) {
+	out := make([]byte, 0, len(body))
+	for _, b := range body {
+		out = utf8.AppendRune(out, rune(b))
+	}
+	return out, nil
+}
+
+// windows1252 maps the bytes 0x80-0x9F where Windows-1252 differs from
+// ISO-8859-1; zero entries are undefined and decode to U+FFFD
+var windows1252 = [32]rune{
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+}
+
+func decodeWindows1252(body []byte) ([]byte, error) {
+	out := make([]byte, 0, len(body))
+	for _, b := range body {
+		r := rune(b)
+		if b >= 0x80 && b <= 0x9F {
+			if r = windows1252[b-0x80]; r == 0 {
+				r = utf8.RuneError
+			}
+		}
+		out = utf8.AppendRune(out, r)
+	}
+	return out, nil
+}
+
+// decodeUTF16 honours a byte order mark and defaults to big endian
+func decodeUTF16(body []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return decodeUTF16LE(body[2:])
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return decodeUTF16BE(body[2:])
+	}
+	return decodeUTF16BE(body)
+}
+
+func decodeUTF16LE(body []byte) ([]byte, error) {
+	return decodeUTF16Units(body, func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 })
+}
+
+func decodeUTF16BE(body []byte) ([]byte, error) {
+	return decodeUTF16Units(body, func(b []byte) uint16 { return uint16(b[0])<<8 | uint16(b[1]) })
+}
+
+func decodeUTF16Units(body []byte, unit func([]byte) uint16) ([]byte, error) {
+	if len(body)%2 != 0 {
+		return nil, errors.New("utf-16 body has an odd number of bytes")
+	}
+	units := make([]uint16, len(body)/2)
+	for i := range units {
+		units[i] = unit(body[2*i:])
+	}
+	out := make([]byte, 0, len(body))
+	for _, r := range utf16.Decode(units) {
+		out = utf8.AppendRune(out, r)
+	}
+	return out, nil
+}
+
+// SetAcceptLanguage sets Accept-Language from tags in order of preference,
+// assigning decreasing q-values. Tags that already carry a q-value are
+// sent unchanged.
+func (r *request) SetAcceptLanguage(tags ...string) RequestBuilder {
+	if len(tags) == 0 {
+		return r
+	}
+
+	// q-values are expressed in thousandths, the precision RFC 9110 allows
+	step := 100
+	if len(tags) > 10 {
+		step = 1000 / len(tags)
+	}
+
+	parts := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		q := max(1000-i*step, 1)
+		if i == 0 || strings.Contains(tag, ";") {
+			parts = append(parts, tag)
+			continue
+		}
+		value := strings.TrimRight(fmt.Sprintf("%03d", q), "0")
+		parts = append(parts, tag+";q=0."+value)
+	}
+	return r.SetHeader("Accept-Language", strings.Join(parts, ", "))
+}
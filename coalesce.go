@@ -0,0 +1,137 @@
+package goclient
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coalescer merges identical GET requests. Callers arriving while a request
+// is in flight wait for its outcome, and for window after it completes
+// successfully its result is served to further identical calls.
+type coalescer struct {
+	window time.Duration
+	clock  Clock
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done     chan struct{}
+	resp     *http.Response
+	body     []byte
+	attempts int
+	err      error
+	expires  time.Time
+}
+
+func newCoalescer(window time.Duration, clock Clock) *coalescer {
+	if window <= 0 {
+		return nil
+	}
+	return &coalescer{window: window, clock: clock, calls: make(map[string]*coalescedCall)}
+}
+
+// coalesceKey identifies requests whose responses are interchangeable. Every
+// header is part of the key, so requests that differ in credentials, cookies
+// or tenant headers are never merged.
+func coalesceKey(req *http.Request) string {
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte('\n')
+	b.WriteString(req.URL.String())
+	for _, name := range names {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		for _, v := range req.Header[name] {
+			b.WriteByte('\x00')
+			b.WriteString(v)
+		}
+	}
+	return b.String()
+}
+
+// do runs send unless an identical request is in flight or completed within
+// the window, in which case it shares that outcome. send runs on its own
+// goroutine, so every caller, including the one that started it, stops
+// waiting when its own ctx is done without affecting the others.
+func (c *coalescer) do(ctx context.Context, key string, send func() (*http.Response, []byte, int, error)) (*http.Response, []byte, int, error) {
+	c.mu.Lock()
+	now := c.clock.Now()
+	for k, call := range c.calls {
+		if !call.expires.IsZero() && !now.Before(call.expires) {
+			delete(c.calls, k)
+		}
+	}
+	call, ok := c.calls[key]
+	if !ok {
+		call = &coalescedCall{done: make(chan struct{})}
+		c.calls[key] = call
+		go c.run(key, call, send)
+	}
+	c.mu.Unlock()
+
+	// Even the caller that sent the request gets a copy, as the original
+	// is shared with the waiters
+	select {
+	case <-call.done:
+		return call.share()
+	case <-ctx.Done():
+		return nil, nil, 0, ctx.Err()
+	}
+}
+
+// run performs the shared request and publishes its outcome
+func (c *coalescer) run(key string, call *coalescedCall, send func() (*http.Response, []byte, int, error)) {
+	call.resp, call.body, call.attempts, call.err = send()
+
+	c.mu.Lock()
+	if call.err == nil && call.resp.StatusCode < 500 {
+		call.expires = c.clock.Now().Add(c.window)
+	} else {
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// share returns a copy of the outcome that the caller may modify
+func (call *coalescedCall) share() (*http.Response, []byte, int, error) {
+	if call.resp == nil {
+		return nil, nil, call.attempts, call.err
+	}
+	resp := *call.resp
+	resp.Header = call.resp.Header.Clone()
+	return &resp, append([]byte(nil), call.body...), call.attempts, call.err
+}
+
+// coalescible reports whether the request may share its outcome with
+// identical requests. Requests with their own transport or streamed
+// decoding always go out on their own.
+func (r *request) coalescible() bool {
+	return r.client.coalescer != nil && r.method == http.MethodGet &&
+		r.transport == nil && len(r.middlewares) == 0 && r.consume == nil
+}
+
+// sendShared returns the send function of a coalesced request. It runs on a
+// copy of the request whose context keeps the caller's values but not its
+// cancellation, as it outlives any caller that gives up waiting. The client
+// timeout still bounds every attempt.
+func (r *request) sendShared(req *http.Request) func() (*http.Response, []byte, int, error) {
+	policy := r.retryPolicy()
+	shared := &request{client: r.client, ctx: context.WithoutCancel(r.ctx), method: r.method, retry: &policy}
+	req = req.WithContext(context.WithoutCancel(req.Context()))
+	return func() (*http.Response, []byte, int, error) {
+		return shared.sendWithRetry(req)
+	}
+}
@@ -0,0 +1,68 @@
+package goclient
+
+import (
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+// codec encodes request bodies and decodes response bodies of one content type
+type codec struct {
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+}
+
+var jsonCodec = codec{marshal: json.Marshal, unmarshal: json.Unmarshal}
+
+// RegisterCodec makes SetBody encode values with marshal when the request's
+// Content-Type is contentType, and Into decode responses of that Content-Type
+// with unmarshal. Either function may be nil to register only one direction.
+// Registering "application/json" replaces the built-in JSON codec. Like Use,
+// it is meant for setup and must not race with requests.
+func (c *client) RegisterCodec(contentType string, marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) Client {
+	if c.codecs == nil {
+		c.codecs = make(map[string]codec)
+	}
+	c.codecs[mediaType(contentType)] = codec{marshal: marshal, unmarshal: unmarshal}
+	return c
+}
+
+// codecFor returns the codec registered for contentType, falling back to JSON
+func (c *client) codecFor(contentType string) codec {
+	found, ok := c.codecs[mediaType(contentType)]
+	if !ok {
+		found, ok = c.codecs["application/json"]
+	}
+	if !ok {
+		return jsonCodec
+	}
+	if found.marshal == nil {
+		found.marshal = jsonCodec.marshal
+	}
+	if found.unmarshal == nil {
+		found.unmarshal = jsonCodec.unmarshal
+	}
+	return found
+}
+
+// jsonCodec returns the JSON codec, honouring Config.JSONMarshal and
+// Config.JSONUnmarshal
+func (c *client) jsonCodec() codec {
+	return c.codecFor("application/json")
+}
+
+// isJSON reports whether bodies of contentType are decoded as JSON, that is
+// whether no other codec is registered for it
+func (c *client) isJSON(contentType string) bool {
+	media := mediaType(contentType)
+	_, registered := c.codecs[media]
+	return !registered || media == "application/json"
+}
+
+// mediaType strips parameters such as charset and normalizes case
+func mediaType(contentType string) string {
+	if parsed, _, err := mime.ParseMediaType(contentType); err == nil {
+		return parsed
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
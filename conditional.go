@@ -0,0 +1,42 @@
+package goclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// SetIfNoneMatch makes the request conditional on the resource's ETag
+func (r *request) SetIfNoneMatch(etag string) RequestBuilder {
+	return r.SetHeader("If-None-Match", etag)
+}
+
+// SetIfModifiedSince makes the request conditional on the resource having
+// changed since t
+func (r *request) SetIfModifiedSince(t time.Time) RequestBuilder {
+	return r.SetHeader("If-Modified-Since", t.UTC().Format(http.TimeFormat))
+}
+
+// Revalidate makes the request conditional on the validators (ETag and
+// Last-Modified) of a previously received response. When the server replies
+// 304 Not Modified, Result returns prev with the refreshed headers and
+// FromCache set, so Into decodes the previously received body.
+func (r *request) Revalidate(prev *Response) RequestBuilder {
+	r.revalidate = prev
+	return r
+}
+
+// conditionalEntry returns the response a 304 reply confirms, either from
+// the client cache or the one passed to Revalidate
+func (r *request) conditionalEntry(stale *CacheEntry) *CacheEntry {
+	if stale != nil {
+		return stale
+	}
+	if r.revalidate == nil {
+		return nil
+	}
+	return &CacheEntry{
+		Response:     r.revalidate,
+		ETag:         r.revalidate.Headers.Get("ETag"),
+		LastModified: r.revalidate.Headers.Get("Last-Modified"),
+	}
+}
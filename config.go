@@ -7,9 +7,12 @@ import (
 
 type Config struct {
 	BaseURL               string
+	TrafficSplit          *TrafficSplit
 	Timeout               time.Duration
 	GlobalHeaders         map[string]string
+	GlobalQueryParams     map[string]string
 	Interceptor           http.RoundTripper
+	Middlewares           []Middleware
 	MaxIdleConns          int
 	MaxIdleConnsPerHost   int
 	MaxConnsPerHost       int
@@ -17,7 +20,57 @@ type Config struct {
 	TLSHandshakeTimeout   time.Duration
 	DisableKeepAlives     bool
 	DisableCompression    bool
+	DisableStatusError    bool
+	ErrorDecoder          func(status int, body []byte) error
+	JSONMarshal           func(v interface{}) ([]byte, error)
+	JSONUnmarshal         func(data []byte, v interface{}) error
+	JSONDecode            JSONDecodeOptions
+	CharsetDetector       CharsetDetector
+	AsyncHandlers         bool
+	DeadlineWarning       *DeadlineWarningConfig
+	SLO                   *SLOConfig
+	Deprecation           *DeprecationConfig
+	TunnelProxy           string
+	TLS                   *TLSConfig
+	Debug                 bool
+	Logger                Logger
+	Logging               *LoggingOptions
+	SniffContentType      bool
+	DefaultContentType    string
+	ProxyURL              string
+	IgnoreEnvProxy        bool
+	Clock                 Clock
+	Rand                  RandSource
+	IDGenerator           IDGenerator
+	Pacing                *PacingConfig
+	RateLimit             *RateLimit
+	Throttle              *ThrottleConfig
+	Maintenance           *MaintenanceConfig
+	ProfilerLabels        bool
+	CircuitBreaker        *CircuitBreakerConfig
+	ProxyProvider         ProxyProvider
+	LocalAddr             string
+	Resolver              Resolver
+	Authenticator         Authenticator
+	Journal               *Journal
+	Outbox                *Outbox
+	OutlierDetection      *OutlierDetectionConfig
+	MaxRetries            int
+	RetryWaitMin          time.Duration
+	RetryWaitMax          time.Duration
+	RetryableStatusCodes  []int
+	MaxRetryAfter         time.Duration
+	RetryAllMethods       bool
 	ResponseHeaderTimeout time.Duration
+	BodyDigest            DigestAlgorithm
+	MaxRequestBytes       int64
+	MaxResponseBytes      int64
+	MaxDecompressionRatio float64
+	CacheTTL              time.Duration
+	CoalesceWindow        time.Duration
+	HTTPCache             bool
+	CacheStore            CacheStore
+	StaleWhileRevalidate  time.Duration
 }
 
 type Option func(*Config)
@@ -45,6 +98,12 @@ func WithBaseURL(url string) Option {
 	}
 }
 
+func WithTrafficSplit(split *TrafficSplit) Option {
+	return func(c *Config) {
+		c.TrafficSplit = split
+	}
+}
+
 func WithTimeout(timeout time.Duration) Option {
 	return func(c *Config) {
 		c.Timeout = timeout
@@ -57,6 +116,12 @@ func WithGlobalHeaders(headers map[string]string) Option {
 	}
 }
 
+func WithGlobalQueryParams(params map[string]string) Option {
+	return func(c *Config) {
+		c.GlobalQueryParams = params
+	}
+}
+
 func WithMaxIdleConns(n int) Option {
 	return func(c *Config) {
 		c.MaxIdleConns = n
@@ -80,3 +145,295 @@ func WithDisableCompression(disable bool) Option {
 		c.DisableCompression = disable
 	}
 }
+
+func WithBodyDigest(alg DigestAlgorithm) Option {
+	return func(c *Config) {
+		c.BodyDigest = alg
+	}
+}
+
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.CacheTTL = ttl
+	}
+}
+
+func WithHTTPCache(store CacheStore) Option {
+	return func(c *Config) {
+		c.HTTPCache = true
+		c.CacheStore = store
+	}
+}
+
+func WithStaleWhileRevalidate(window time.Duration) Option {
+	return func(c *Config) {
+		c.StaleWhileRevalidate = window
+	}
+}
+
+func WithMaxRequestBytes(n int64) Option {
+	return func(c *Config) {
+		c.MaxRequestBytes = n
+	}
+}
+
+func WithDisableStatusError(disable bool) Option {
+	return func(c *Config) {
+		c.DisableStatusError = disable
+	}
+}
+
+func WithErrorDecoder(decoder func(status int, body []byte) error) Option {
+	return func(c *Config) {
+		c.ErrorDecoder = decoder
+	}
+}
+
+func WithClock(clock Clock) Option {
+	return func(c *Config) {
+		c.Clock = clock
+	}
+}
+
+func WithRand(src RandSource) Option {
+	return func(c *Config) {
+		c.Rand = src
+	}
+}
+
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(c *Config) {
+		c.IDGenerator = gen
+	}
+}
+
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *Config) {
+		c.MaxResponseBytes = n
+	}
+}
+
+// WithMaxDecompressionRatio bounds how much a compressed response may expand.
+// Zero uses the default ratio and a negative value disables the check. Only
+// gzip and deflate responses are decoded and checked; br and zstd bodies are
+// returned still encoded, subject to MaxResponseBytes only.
+func WithMaxDecompressionRatio(ratio float64) Option {
+	return func(c *Config) {
+		c.MaxDecompressionRatio = ratio
+	}
+}
+
+func WithPacing(pacing PacingConfig) Option {
+	return func(c *Config) {
+		c.Pacing = &pacing
+	}
+}
+
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *Config) {
+		c.RateLimit = &RateLimit{RequestsPerSecond: requestsPerSecond, Burst: burst}
+	}
+}
+
+func WithThrottle(throttle ThrottleConfig) Option {
+	return func(c *Config) {
+		c.Throttle = &throttle
+	}
+}
+
+func WithProfilerLabels(enable bool) Option {
+	return func(c *Config) {
+		c.ProfilerLabels = enable
+	}
+}
+
+// WithCoalescing merges identical GET requests that are in flight, and serves
+// the result of a completed one to identical calls arriving within window
+func WithCoalescing(window time.Duration) Option {
+	return func(c *Config) {
+		c.CoalesceWindow = window
+	}
+}
+
+// WithJSON replaces encoding/json, for example with json-iterator or sonic:
+//
+//	goclient.WithJSON(sonic.Marshal, sonic.Unmarshal)
+func WithJSON(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) Option {
+	return func(c *Config) {
+		c.JSONMarshal = marshal
+		c.JSONUnmarshal = unmarshal
+	}
+}
+
+func WithJSONDecodeOptions(opts JSONDecodeOptions) Option {
+	return func(c *Config) {
+		c.JSONDecode = opts
+	}
+}
+
+func WithCharsetDetector(detect CharsetDetector) Option {
+	return func(c *Config) {
+		c.CharsetDetector = detect
+	}
+}
+
+// WithAsyncHandlers runs OnSuccess and OnError handlers on their own
+// goroutines so slow handlers do not delay the caller
+func WithAsyncHandlers() Option {
+	return func(c *Config) {
+		c.AsyncHandlers = true
+	}
+}
+
+func WithDeadlineWarning(cfg DeadlineWarningConfig) Option {
+	return func(c *Config) {
+		c.DeadlineWarning = &cfg
+	}
+}
+
+func WithSLO(cfg SLOConfig) Option {
+	return func(c *Config) {
+		c.SLO = &cfg
+	}
+}
+
+func WithDeprecationWarnings(cfg DeprecationConfig) Option {
+	return func(c *Config) {
+		c.Deprecation = &cfg
+	}
+}
+
+func WithDebug() Option {
+	return func(c *Config) {
+		c.Debug = true
+	}
+}
+
+func WithLogger(logger Logger) Option {
+	return func(c *Config) {
+		c.Logger = logger
+	}
+}
+
+func WithLoggingOptions(opts LoggingOptions) Option {
+	return func(c *Config) {
+		c.Logging = &opts
+	}
+}
+
+// WithContentSniffing detects the type of responses without a Content-Type
+// before decoding them. fallback is assumed for binary data; empty keeps it
+// application/octet-stream.
+func WithContentSniffing(fallback string) Option {
+	return func(c *Config) {
+		c.SniffContentType = true
+		c.DefaultContentType = fallback
+	}
+}
+
+// WithProxyURL sends every request through the proxy at rawURL. Supported
+// schemes are http, https, socks5 and socks5h.
+func WithProxyURL(rawURL string) Option {
+	return func(c *Config) {
+		c.ProxyURL = rawURL
+	}
+}
+
+func WithMaintenance(maintenance MaintenanceConfig) Option {
+	return func(c *Config) {
+		c.Maintenance = &maintenance
+	}
+}
+
+func WithCircuitBreaker(breaker CircuitBreakerConfig) Option {
+	return func(c *Config) {
+		c.CircuitBreaker = &breaker
+	}
+}
+
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Config) {
+		c.Middlewares = append(c.Middlewares, mw...)
+	}
+}
+
+func WithProxyProvider(provider ProxyProvider) Option {
+	return func(c *Config) {
+		c.ProxyProvider = provider
+	}
+}
+
+// WithLocalAddr sends every request from the local IP address ip. The
+// connections are dialed with the dial timeout and keep-alive of
+// http.DefaultTransport, not a custom DialContext of the Interceptor.
+func WithLocalAddr(ip string) Option {
+	return func(c *Config) {
+		c.LocalAddr = ip
+	}
+}
+
+func WithResolver(resolver Resolver) Option {
+	return func(c *Config) {
+		c.Resolver = resolver
+	}
+}
+
+func WithOutlierDetection(detection OutlierDetectionConfig) Option {
+	return func(c *Config) {
+		c.OutlierDetection = &detection
+	}
+}
+
+func WithJournal(journal *Journal) Option {
+	return func(c *Config) {
+		c.Journal = journal
+	}
+}
+
+// WithHMACSigning signs every request with HMACSigning
+func WithHMACSigning(keyID, secret string, opts SignerOptions) Option {
+	return func(c *Config) {
+		c.Middlewares = append(c.Middlewares, HMACSigning(keyID, secret, opts))
+	}
+}
+
+func WithAuthenticator(a Authenticator) Option {
+	return func(c *Config) {
+		c.Authenticator = a
+	}
+}
+
+func WithOutbox(outbox *Outbox) Option {
+	return func(c *Config) {
+		c.Outbox = outbox
+	}
+}
+
+func WithRetry(maxRetries int, waitMin, waitMax time.Duration) Option {
+	return func(c *Config) {
+		c.MaxRetries = maxRetries
+		c.RetryWaitMin = waitMin
+		c.RetryWaitMax = waitMax
+	}
+}
+
+func WithMaxRetryAfter(max time.Duration) Option {
+	return func(c *Config) {
+		c.MaxRetryAfter = max
+	}
+}
+
+// WithRetryAllMethods retries POST, PATCH and other requests that are not
+// idempotent. By default only idempotent methods and requests with an
+// Idempotency-Key header are retried.
+func WithRetryAllMethods() Option {
+	return func(c *Config) {
+		c.RetryAllMethods = true
+	}
+}
+
+func WithRetryableStatusCodes(codes ...int) Option {
+	return func(c *Config) {
+		c.RetryableStatusCodes = codes
+	}
+}
@@ -0,0 +1,295 @@
+package goclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CrawlerConfig configures a Crawler
+type CrawlerConfig struct {
+	// Workers bounds the number of concurrent requests, default 4
+	Workers int
+	// FollowRels lists the Link header relations to follow, default ["next"]
+	FollowRels []string
+	// ExtractLinks returns additional URLs to follow from a response
+	ExtractLinks func(resp *Response) []string
+	// ItemsField names the JSON field holding the items of a page. When empty
+	// the body itself must be a JSON array.
+	ItemsField string
+	// RatePerHost caps requests per second to each host, 0 means unlimited
+	RatePerHost float64
+	// MaxPages stops the crawl after this many pages, 0 means unlimited
+	MaxPages int
+	// Clock times the per-host pauses, default the client's clock
+	Clock Clock
+}
+
+// Crawler follows pagination and links from a seed endpoint and streams the
+// decoded items of every page to a callback. URLs are visited at most once and
+// hosts that report an exhausted rate limit are paused until it resets.
+type Crawler struct {
+	client Client
+	cfg    CrawlerConfig
+
+	mu    sync.Mutex
+	seen  map[string]bool
+	pages int
+	hosts map[string]time.Time
+}
+
+// NewCrawler creates a Crawler that issues requests through c
+func NewCrawler(c Client, cfg CrawlerConfig) *Crawler {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if len(cfg.FollowRels) == 0 {
+		cfg.FollowRels = []string{"next"}
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+		if cl, ok := c.(*client); ok {
+			cfg.Clock = cl.clock
+		}
+	}
+	return &Crawler{client: c, cfg: cfg}
+}
+
+// Run crawls from seed until there is nothing left to visit, ctx is done or fn
+// returns an error. fn is never called concurrently. Failed pages do not stop
+// the crawl; their errors are returned as a *MultiError once it finishes.
+func (cr *Crawler) Run(ctx context.Context, seed string, fn func(item json.RawMessage) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cr.seen = make(map[string]bool)
+	cr.hosts = make(map[string]time.Time)
+	cr.pages = 0
+
+	pool := cr.client.Pool(cr.cfg.Workers)
+	defer pool.Wait()
+
+	sem := make(chan struct{}, cr.cfg.Workers)
+	var wg sync.WaitGroup
+	var errMu, fnMu sync.Mutex
+	var errs []error
+	var fnErr error
+
+	var visit func(target string)
+	visit = func(target string) {
+		if !cr.claim(target) {
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			if err := cr.wait(ctx, target); err != nil {
+				return
+			}
+
+			result := <-pool.Submit(cr.client.GetWithContext(ctx, target))
+			if result.Error != nil {
+				if ctx.Err() == nil {
+					errMu.Lock()
+					errs = append(errs, fmt.Errorf("%s: %w", target, result.Error))
+					errMu.Unlock()
+				}
+				return
+			}
+			cr.observeRateLimit(target, result.Response.Headers)
+
+			items, err := cr.items(result.Response)
+			if err != nil {
+				errMu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", target, err))
+				errMu.Unlock()
+			}
+
+			fnMu.Lock()
+			for _, item := range items {
+				if fnErr != nil {
+					break
+				}
+				if err := fn(item); err != nil {
+					fnErr = err
+					cancel()
+				}
+			}
+			fnMu.Unlock()
+
+			for _, link := range cr.links(target, result.Response) {
+				visit(link)
+			}
+		}()
+	}
+
+	visit(seed)
+	wg.Wait()
+
+	if fnErr != nil {
+		return fnErr
+	}
+	if err := newMultiError(errs); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// claim marks target as visited, reporting false if it was seen already or
+// the page budget is spent
+func (cr *Crawler) claim(target string) bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if cr.seen[target] || (cr.cfg.MaxPages > 0 && cr.pages >= cr.cfg.MaxPages) {
+		return false
+	}
+	cr.seen[target] = true
+	cr.pages++
+	return true
+}
+
+// wait blocks until a request to target's host is allowed
+func (cr *Crawler) wait(ctx context.Context, target string) error {
+	host := hostOf(target)
+
+	cr.mu.Lock()
+	now := cr.cfg.Clock.Now()
+	next := cr.hosts[host]
+	if next.Before(now) {
+		next = now
+	}
+	if cr.cfg.RatePerHost > 0 {
+		cr.hosts[host] = next.Add(time.Duration(float64(time.Second) / cr.cfg.RatePerHost))
+	}
+	cr.mu.Unlock()
+
+	if delay := next.Sub(now); delay > 0 {
+		return cr.cfg.Clock.Sleep(ctx, delay)
+	}
+	return ctx.Err()
+}
+
+// observeRateLimit pauses a host whose rate limit headers report no remaining quota
+func (cr *Crawler) observeRateLimit(target string, h http.Header) {
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+
+	now := cr.cfg.Clock.Now()
+	var resume time.Time
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+		resume = now.Add(time.Duration(secs) * time.Second)
+	} else if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		// Small values are a delay in seconds, large ones a Unix timestamp
+		if reset < 1e9 {
+			resume = now.Add(time.Duration(reset) * time.Second)
+		} else {
+			resume = time.Unix(reset, 0)
+		}
+	}
+	if resume.IsZero() {
+		return
+	}
+
+	host := hostOf(target)
+	cr.mu.Lock()
+	if resume.After(cr.hosts[host]) {
+		cr.hosts[host] = resume
+	}
+	cr.mu.Unlock()
+}
+
+func (cr *Crawler) items(resp *Response) ([]json.RawMessage, error) {
+	if len(resp.Body) == 0 {
+		return nil, nil
+	}
+
+	body := resp.Body
+	if cr.cfg.ItemsField != "" {
+		var err error
+		if body, err = UnwrapField(cr.cfg.ItemsField)(body); err != nil {
+			return nil, err
+		}
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, fmt.Errorf("failed to decode page items: %w", err)
+	}
+	return items, nil
+}
+
+// links returns the absolute URLs to follow from a page fetched from target
+func (cr *Crawler) links(target string, resp *Response) []string {
+	base, err := url.Parse(target)
+	if err != nil {
+		return nil
+	}
+
+	var raw []string
+	links := ParseLinkHeader(resp.Headers)
+	for _, rel := range cr.cfg.FollowRels {
+		raw = append(raw, links[rel]...)
+	}
+	if cr.cfg.ExtractLinks != nil {
+		raw = append(raw, cr.cfg.ExtractLinks(resp)...)
+	}
+
+	out := make([]string, 0, len(raw))
+	for _, link := range raw {
+		if u, err := base.Parse(link); err == nil {
+			out = append(out, u.String())
+		}
+	}
+	return out
+}
+
+// ParseLinkHeader parses RFC 8288 Link headers into URLs keyed by relation
+func ParseLinkHeader(h http.Header) map[string][]string {
+	links := make(map[string][]string)
+	for _, value := range h.Values("Link") {
+		for _, entry := range strings.Split(value, ",") {
+			segments := strings.Split(entry, ";")
+			target := strings.TrimSpace(segments[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+
+			for _, param := range segments[1:] {
+				key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(val, `"`)) {
+					rel = strings.ToLower(rel)
+					links[rel] = append(links[rel], target)
+				}
+			}
+		}
+	}
+	return links
+}
+
+func hostOf(target string) string {
+	if u, err := url.Parse(target); err == nil {
+		return u.Host
+	}
+	return ""
+}
@@ -0,0 +1,156 @@
+package goclient
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// CSVRows iterates over the records of a CSV document with a header row
+type CSVRows struct {
+	Header []string
+	r      *csv.Reader
+	index  map[string]int
+}
+
+// CSVRow is a single record addressed by column name
+type CSVRow struct {
+	Values []string
+	index  map[string]int
+}
+
+// Get returns the value of column, or "" when the column does not exist
+func (r CSVRow) Get(column string) string {
+	if i, ok := r.index[strings.ToLower(column)]; ok && i < len(r.Values) {
+		return r.Values[i]
+	}
+	return ""
+}
+
+// NewCSVRows reads the header row from src and returns an iterator over the
+// remaining records. Records are read lazily.
+func NewCSVRows(src io.Reader) (*CSVRows, error) {
+	r := csv.NewReader(src)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = false
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	// Strip a UTF-8 byte order mark, common in spreadsheet exports
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	return &CSVRows{Header: header, r: r, index: index}, nil
+}
+
+// Next returns the next record, or io.EOF when the document is exhausted
+func (c *CSVRows) Next() (CSVRow, error) {
+	values, err := c.r.Read()
+	if err != nil {
+		return CSVRow{}, err
+	}
+	return CSVRow{Values: values, index: c.index}, nil
+}
+
+// CSVRows returns an iterator over a text/csv response body
+func (r *Response) CSVRows() (*CSVRows, error) {
+	return NewCSVRows(bytes.NewReader(r.Body))
+}
+
+// DecodeCSV decodes a CSV document with a header row into v, which must be a
+// pointer to a slice of structs. Columns are matched to fields by their
+// `csv:"name"` tag, falling back to the field name, case-insensitively.
+// time.Time fields are parsed as RFC 3339 and time.Duration with time.ParseDuration.
+func DecodeCSV(src io.Reader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice ||
+		rv.Elem().Type().Elem().Kind() != reflect.Struct {
+		return errors.New("CSV target must be a pointer to a slice of structs")
+	}
+
+	rows, err := NewCSVRows(src)
+	if err != nil {
+		return err
+	}
+
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+
+	// Map struct fields to column positions once
+	columns := make(map[int]int)
+	for i := 0; i < elemType.NumField(); i++ {
+		field := elemType.Field(i)
+		name := field.Tag.Get("csv")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if col, ok := rows.index[strings.ToLower(name)]; ok {
+			columns[i] = col
+		}
+	}
+
+	for line := 2; ; line++ {
+		row, err := rows.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV record: %w", err)
+		}
+
+		elem := reflect.New(elemType).Elem()
+		for fieldIndex, col := range columns {
+			if col >= len(row.Values) || row.Values[col] == "" {
+				continue
+			}
+			if err := setCSVField(elem.Field(fieldIndex), row.Values[col]); err != nil {
+				return fmt.Errorf("line %d, column %s: %w", line, rows.Header[col], err)
+			}
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+}
+
+func setCSVField(f reflect.Value, value string) error {
+	switch f.Type() {
+	case timeType:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	case reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	return setBasicField(f, value)
+}
+
+// IntoCSV decodes a CSV response into v (see DecodeCSV)
+func (r *request) IntoCSV(v interface{}) error {
+	resp, err := r.Result()
+	if err != nil {
+		return r.decodeInto(resp, err, nil)
+	}
+	return DecodeCSV(bytes.NewReader(resp.Body), v)
+}
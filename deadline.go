@@ -0,0 +1,78 @@
+package goclient
+
+import "time"
+
+// DeadlineWarningConfig reports requests that use most of their time budget,
+// flagging calls that are about to start timing out before they do. The
+// budget is the earlier of the context deadline and Config.Timeout.
+type DeadlineWarningConfig struct {
+	// Threshold is the fraction of the budget, between 0 and 1, after which
+	// a request is reported. Zero defaults to 0.8.
+	Threshold float64
+	// OnWarning is called for every reported request. Without it the
+	// warning is written to the client's logger.
+	OnWarning func(DeadlineWarning)
+}
+
+// DeadlineWarning describes a request that came close to its deadline
+type DeadlineWarning struct {
+	Method   string
+	Endpoint string
+	Elapsed  time.Duration
+	Budget   time.Duration
+	// Err is the request's error, if it failed
+	Err error
+}
+
+// timeBudget returns how long the request may take, or zero when unbounded.
+// Context deadlines are wall-clock times, so they are measured against the
+// system clock rather than the client's.
+func (r *request) timeBudget() time.Duration {
+	budget := r.client.httpClient.Timeout
+	if deadline, ok := r.ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); budget == 0 || remaining < budget {
+			budget = remaining
+		}
+	}
+	return budget
+}
+
+// checkDeadline reports the request if it used more than the configured
+// share of its budget
+func (r *request) checkDeadline(start time.Time, budget time.Duration) {
+	cfg := r.client.deadlineWarn
+	if cfg == nil || budget <= 0 {
+		return
+	}
+	threshold := cfg.Threshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = 0.8
+	}
+
+	elapsed := r.client.clock.Now().Sub(start)
+	if float64(elapsed) < threshold*float64(budget) {
+		return
+	}
+	r.client.stats.nearDeadline.Add(1)
+
+	warning := DeadlineWarning{
+		Method:   r.method,
+		Endpoint: r.endpoint,
+		Elapsed:  elapsed,
+		Budget:   budget,
+		Err:      r.err,
+	}
+	if cfg.OnWarning != nil {
+		cfg.OnWarning(warning)
+		return
+	}
+	if logger := r.client.currentLogger(); logger != nil {
+		logger.Log(LogLevelWarn, "Request close to deadline", map[string]interface{}{
+			"method":   warning.Method,
+			"endpoint": warning.Endpoint,
+			"elapsed":  warning.Elapsed.String(),
+			"budget":   warning.Budget.String(),
+			"used":     float64(elapsed) / float64(budget),
+		})
+	}
+}
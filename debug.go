@@ -0,0 +1,181 @@
+package goclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxRecentErrors bounds the errors kept for DebugHandler
+const maxRecentErrors = 20
+
+// clientStats counts client activity for DebugHandler. Clones share it.
+type clientStats struct {
+	requests    atomic.Int64
+	inFlight    atomic.Int64
+	failures    atomic.Int64
+	pools       atomic.Int64
+	poolPending atomic.Int64
+	batches     atomic.Int64
+
+	// nearDeadline counts requests reported by DeadlineWarningConfig
+	nearDeadline atomic.Int64
+	// deprecated counts responses announcing a deprecated endpoint
+	deprecated atomic.Int64
+
+	mu     sync.Mutex
+	recent []debugError
+}
+
+type debugError struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Endpoint string    `json:"endpoint"`
+	Error    string    `json:"error"`
+}
+
+func (s *clientStats) begin() {
+	s.requests.Add(1)
+	s.inFlight.Add(1)
+}
+
+// end records the outcome of an executed request
+func (s *clientStats) end(r *request) {
+	s.inFlight.Add(-1)
+	if r.err == nil {
+		return
+	}
+	s.failures.Add(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.recent) == maxRecentErrors {
+		s.recent = s.recent[1:]
+	}
+	s.recent = append(s.recent, debugError{
+		Time:     r.client.clock.Now(),
+		Method:   r.method,
+		Endpoint: r.endpoint,
+		Error:    redactedError(r.err),
+	})
+}
+
+func (s *clientStats) recentErrors() []debugError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]debugError{}, s.recent...)
+}
+
+type debugSnapshot struct {
+	Config      debugConfig                 `json:"config"`
+	Requests    debugRequests               `json:"requests"`
+	Pools       debugPools                  `json:"pools"`
+	Cache       debugCache                  `json:"cache"`
+	Circuits    map[string]string           `json:"circuits,omitempty"`
+	Throttle    map[string]ThrottleState    `json:"throttle,omitempty"`
+	Maintenance map[string]MaintenanceState `json:"maintenance,omitempty"`
+	Errors      []debugError                `json:"recent_errors"`
+}
+
+type debugConfig struct {
+	Client        string            `json:"client"`
+	BaseURL       string            `json:"base_url"`
+	Timeout       string            `json:"timeout"`
+	GlobalHeaders map[string]string `json:"global_headers"`
+	GlobalQuery   map[string]string `json:"global_query"`
+	Debug         bool              `json:"debug"`
+}
+
+type debugRequests struct {
+	Total        int64 `json:"total"`
+	InFlight     int64 `json:"in_flight"`
+	Failed       int64 `json:"failed"`
+	NearDeadline int64 `json:"near_deadline"`
+	Deprecated   int64 `json:"deprecated"`
+}
+
+type debugPools struct {
+	Active  int64 `json:"active"`
+	Pending int64 `json:"pending"`
+	Batches int64 `json:"batches"`
+}
+
+type debugCache struct {
+	Entries int     `json:"entries"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// redactedValues copies m with the values of sensitive keys redacted
+func redactedValues(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if isSensitiveKey(k) {
+			v = redacted
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// snapshot collects the current state of the client with credentials redacted
+func (c *client) snapshot() debugSnapshot {
+	c.headersMu.RLock()
+	headers := redactedValues(c.globalHeaders)
+	c.headersMu.RUnlock()
+
+	s := debugSnapshot{
+		Config: debugConfig{
+			Client:        c.String(),
+			BaseURL:       c.base(),
+			Timeout:       c.httpClient.Timeout.String(),
+			GlobalHeaders: headers,
+			GlobalQuery:   redactedValues(c.globalQuery),
+			Debug:         c.debugEnabled.Load(),
+		},
+		Requests: debugRequests{
+			Total:        c.stats.requests.Load(),
+			InFlight:     c.stats.inFlight.Load(),
+			Failed:       c.stats.failures.Load(),
+			NearDeadline: c.stats.nearDeadline.Load(),
+			Deprecated:   c.stats.deprecated.Load(),
+		},
+		Pools: debugPools{
+			Active:  c.stats.pools.Load(),
+			Pending: c.stats.poolPending.Load(),
+			Batches: c.stats.batches.Load(),
+		},
+		Cache: debugCache{
+			Entries: c.cache.Len(),
+			Hits:    c.cache.hits.Load(),
+			Misses:  c.cache.misses.Load(),
+		},
+		Throttle:    c.ThrottleStats(),
+		Maintenance: c.MaintenanceStats(),
+		Errors:      c.stats.recentErrors(),
+	}
+	if lookups := s.Cache.Hits + s.Cache.Misses; lookups > 0 {
+		s.Cache.HitRate = float64(s.Cache.Hits) / float64(lookups)
+	}
+	if c.breaker != nil {
+		s.Circuits = c.breaker.states()
+	}
+	return s
+}
+
+// DebugHandler returns an http.Handler serving a JSON snapshot of the
+// client: its redacted configuration, request and pool counters, circuit
+// breaker states, cache hit rate and the most recent errors. Mount it under
+// a path such as /debug/goclient on an internal listener.
+func (c *client) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(c.snapshot())
+	})
+}
@@ -0,0 +1,187 @@
+package goclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// DecodeHook transforms a response body before it is decoded by Into
+type DecodeHook func(body []byte) ([]byte, error)
+
+// UnwrapField returns a DecodeHook that extracts a top-level JSON field, for
+// APIs that wrap every payload in an envelope such as {"data": ..., "meta": ...}.
+// Bodies without the field are passed through unchanged.
+func UnwrapField(field string) DecodeHook {
+	return func(body []byte) ([]byte, error) {
+		var envelope map[string]json.RawMessage
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return body, nil
+		}
+		if inner, ok := envelope[field]; ok {
+			return inner, nil
+		}
+		return body, nil
+	}
+}
+
+// OnDecode registers a hook applied to every response body before Into
+// decodes it. Hooks run in registration order.
+func (c *client) OnDecode(hook DecodeHook) Client {
+	c.decodeHooks = append(c.decodeHooks, hook)
+	return c
+}
+
+// JSONDecodeOptions tighten how JSON responses are decoded. When any option
+// is set, JSON bodies are decoded with encoding/json even if another engine
+// was installed with WithJSON, so strictness is never silently dropped.
+type JSONDecodeOptions struct {
+	// DisallowUnknownFields fails decoding when an object has a key that
+	// matches no field of the destination struct, catching schema drift
+	DisallowUnknownFields bool
+	// UseNumber decodes numbers held in interface{} values as json.Number
+	// instead of float64, so large IDs keep their precision
+	UseNumber bool
+}
+
+// SetJSONDecodeOptions overrides Config.JSONDecode for this request
+func (r *request) SetJSONDecodeOptions(opts JSONDecodeOptions) RequestBuilder {
+	r.jsonDecode = &opts
+	return r
+}
+
+// jsonDecodeOptions returns the request's decode options, falling back to
+// the client's
+func (r *request) jsonDecodeOptions() JSONDecodeOptions {
+	if r.jsonDecode != nil {
+		return *r.jsonDecode
+	}
+	return r.client.jsonDecode
+}
+
+// apply configures dec with the options
+func (o JSONDecodeOptions) apply(dec *json.Decoder) {
+	if o.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if o.UseNumber {
+		dec.UseNumber()
+	}
+}
+
+// unmarshal decodes a single JSON value from data into v, rejecting
+// trailing data like json.Unmarshal
+func (o JSONDecodeOptions) unmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	o.apply(dec)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level JSON value")
+	}
+	return nil
+}
+
+// decode runs the decode hooks over body and unmarshals the result into v
+// with the codec registered for contentType. JSON bodies are converted to
+// UTF-8 first and honour opts.
+func (c *client) decode(contentType string, body []byte, v interface{}, opts JSONDecodeOptions) error {
+	declared := contentType != ""
+	contentType = c.contentTypeFor(contentType, body)
+	isJSON := c.isJSON(contentType)
+	if isJSON {
+		detect := c.charset
+		if detect == nil {
+			detect = DetectCharset
+		}
+		var err error
+		if body, err = detect(contentType, body); err != nil {
+			return err
+		}
+	}
+	for _, hook := range c.decodeHooks {
+		var err error
+		if body, err = hook(body); err != nil {
+			return fmt.Errorf("decode hook failed: %w", err)
+		}
+	}
+	if !declared {
+		if _, registered := c.codecs[mediaType(contentType)]; !registered {
+			if ok, err := decodeUntyped(contentType, body, v); ok {
+				return err
+			}
+		}
+	}
+	if opts != (JSONDecodeOptions{}) && isJSON {
+		return opts.unmarshal(body, v)
+	}
+	return c.codecFor(contentType).unmarshal(body, v)
+}
+
+// IntoStream decodes a successful JSON response directly from the network
+// stream instead of buffering the whole body first. Top-level arrays decoded
+// into a slice are read one element at a time, so memory stays flat for
+// large collections. Decode hooks, registered codecs and charset detection
+// are not applied, after-response hooks and TeeBody see an empty body, and
+// the response is not stored in the cache. Error responses and cache hits are decoded as by Into.
+func (r *request) IntoStream(v interface{}) error {
+	streamed := false
+	opts := r.jsonDecodeOptions()
+	r.consume = func(_ *http.Response, body io.Reader) error {
+		streamed = true
+		return decodeStream(body, v, opts)
+	}
+
+	resp, err := r.Result()
+	if streamed && err == nil {
+		return nil
+	}
+	return r.decodeInto(resp, err, v)
+}
+
+// decodeStream decodes one JSON value from r into v. A top-level array bound
+// for a slice is decoded element by element so the decoder never buffers
+// more than one element.
+func decodeStream(r io.Reader, v interface{}, opts JSONDecodeOptions) error {
+	dec := json.NewDecoder(r)
+	opts.apply(dec)
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return dec.Decode(v)
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	slice := rv.Elem()
+	switch tok {
+	case json.Delim('['):
+	case nil:
+		slice.SetZero()
+		return nil
+	default:
+		return fmt.Errorf("cannot decode %v into %s", tok, slice.Type())
+	}
+
+	slice.SetLen(0)
+	for n := 0; dec.More(); n++ {
+		if n == slice.Cap() {
+			slice.Grow(1)
+		}
+		slice.SetLen(n + 1)
+		elem := slice.Index(n)
+		elem.SetZero()
+		if err := dec.Decode(elem.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
@@ -0,0 +1,187 @@
+package goclient
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// defaultMaxDecompressionRatio is used when Config.MaxDecompressionRatio is zero
+	defaultMaxDecompressionRatio = 200
+	// minRatioCheckBytes avoids flagging small, highly compressible bodies
+	minRatioCheckBytes = 1 << 20
+)
+
+// ResponseTooLargeError is returned when a response body exceeds
+// Config.MaxResponseBytes after decompression
+type ResponseTooLargeError struct {
+	Limit int64
+	Size  int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body too large: read at least %d bytes, limit=%d", e.Size, e.Limit)
+}
+
+// DecompressionBombError is returned when a gzip or deflate response expands
+// beyond Config.MaxDecompressionRatio. Brotli and zstd are not decoded, so
+// the client only advertises gzip and deflate.
+type DecompressionBombError struct {
+	Encoding     string
+	Compressed   int64
+	Decompressed int64
+	MaxRatio     float64
+}
+
+func (e *DecompressionBombError) Error() string {
+	return fmt.Sprintf("%s response expanded from %d to %d bytes, exceeding ratio %.0f",
+		e.Encoding, e.Compressed, e.Decompressed, e.MaxRatio)
+}
+
+// countingReader counts the bytes read from the wire
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// guardedReader enforces the size and ratio limits on a decoded body
+type guardedReader struct {
+	r        io.Reader
+	wire     countingReader
+	encoding string
+	maxBytes int64
+	maxRatio float64
+	n        int64
+}
+
+func (g *guardedReader) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	g.n += int64(n)
+
+	if g.maxBytes > 0 && g.n > g.maxBytes {
+		return n, &ResponseTooLargeError{Limit: g.maxBytes, Size: g.n}
+	}
+	if g.encoding != "" && g.maxRatio > 0 && g.n > minRatioCheckBytes && g.wire.n > 0 &&
+		float64(g.n)/float64(g.wire.n) > g.maxRatio {
+		return n, &DecompressionBombError{
+			Encoding:     g.encoding,
+			Compressed:   g.wire.n,
+			Decompressed: g.n,
+			MaxRatio:     g.maxRatio,
+		}
+	}
+	return n, err
+}
+
+// acceptEncoding advertises the encodings the client decodes itself. Setting
+// it explicitly stops net/http from decompressing transparently, which would
+// hide the compressed size needed for the ratio check. Range requests ask for
+// identity, as a slice of an encoded body cannot be decoded on its own.
+func (c *client) acceptEncoding(req *http.Request) {
+	if c.disableCompression || req.Header.Get("Accept-Encoding") != "" || req.Method == http.MethodHead {
+		return
+	}
+	if req.Header.Get("Range") != "" {
+		req.Header.Set("Accept-Encoding", "identity")
+		return
+	}
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+}
+
+// readBody reads the response body, decoding gzip and deflate content while
+// enforcing the configured size and ratio limits. Other encodings are
+// returned as-is.
+func (c *client) readBody(resp *http.Response) ([]byte, error) {
+	guard, done, err := c.bodyReader(resp)
+	if guard == nil || err != nil {
+		return nil, err
+	}
+	defer done()
+
+	size := resp.ContentLength
+	if guard.encoding != "" || (c.maxResponseBytes > 0 && size > c.maxResponseBytes) {
+		size = -1
+	}
+	return readAll(guard, size)
+}
+
+// bodyReader wraps the response body with decompression and the size and
+// ratio guards. The guard is nil for an empty compressed body. done releases
+// the decompressor.
+func (c *client) bodyReader(resp *http.Response) (guard *guardedReader, done func(), err error) {
+	// The wire counter lives inside the guard to save an allocation
+	guard = &guardedReader{
+		wire:     countingReader{r: resp.Body},
+		maxBytes: c.maxResponseBytes,
+		maxRatio: c.maxDecompressionRatio,
+	}
+	wire := &guard.wire
+	guard.r = wire
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	var decoder io.ReadCloser
+	switch encoding {
+	case "gzip", "x-gzip":
+		decoder, err = gzip.NewReader(wire)
+	case "deflate":
+		decoder, err = zlib.NewReader(wire)
+	}
+	if errors.Is(err, io.EOF) {
+		// Empty bodies carry no compressed stream
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s response: %w", encoding, err)
+	}
+
+	done = func() {}
+	if decoder != nil {
+		done = func() { decoder.Close() }
+		guard.r = decoder
+		guard.encoding = encoding
+
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	return guard, done, nil
+}
+
+// maxPresize bounds the buffer allocated up front from Content-Length
+const maxPresize = 64 << 20
+
+// readAll reads r to EOF like io.ReadAll but allocates the buffer once when
+// the size is known in advance
+func readAll(r io.Reader, size int64) ([]byte, error) {
+	if size <= 0 || size > maxPresize {
+		return io.ReadAll(r)
+	}
+
+	// One spare byte lets the final read observe EOF without growing
+	b := make([]byte, 0, size+1)
+	for {
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return b, err
+		}
+		if len(b) == cap(b) {
+			b = append(b, 0)[:len(b)]
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package goclient
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeprecationConfig reports responses that announce the upstream API is
+// deprecated, through the Deprecation (RFC 9745) and Sunset (RFC 8594)
+// headers or a Warning header with code 299. The request itself proceeds
+// normally.
+type DeprecationConfig struct {
+	// OnDeprecation is called the first time each method and route is seen
+	// deprecated. Without it the notice is written to the client's logger.
+	OnDeprecation func(DeprecationNotice)
+}
+
+// DeprecationNotice describes a deprecated endpoint
+type DeprecationNotice struct {
+	Method string
+	// Route is the endpoint template of the request
+	Route string
+	URL   string
+	// Deprecated is when the endpoint was or will be deprecated; zero when
+	// the server only says that it is
+	Deprecated time.Time
+	// Sunset is when the endpoint stops working, if announced
+	Sunset time.Time
+	// Links are the documentation links with rel "deprecation" or "sunset"
+	Links []string
+	// Warnings holds the text of 299 Warning headers
+	Warnings []string
+}
+
+// deprecationMonitor remembers which operations were already reported
+type deprecationMonitor struct {
+	cfg  DeprecationConfig
+	seen sync.Map
+}
+
+func newDeprecationMonitor(cfg *DeprecationConfig) *deprecationMonitor {
+	if cfg == nil {
+		return nil
+	}
+	return &deprecationMonitor{cfg: *cfg}
+}
+
+// parseDeprecation returns the deprecation notice carried by h, if any
+func parseDeprecation(h http.Header) (DeprecationNotice, bool) {
+	var notice DeprecationNotice
+	found := false
+
+	if value := strings.TrimSpace(h.Get("Deprecation")); value != "" {
+		found = true
+		if secs, err := strconv.ParseInt(strings.TrimPrefix(value, "@"), 10, 64); err == nil && strings.HasPrefix(value, "@") {
+			notice.Deprecated = time.Unix(secs, 0).UTC()
+		} else if t, err := http.ParseTime(value); err == nil {
+			notice.Deprecated = t
+		}
+	}
+	if value := h.Get("Sunset"); value != "" {
+		if t, err := http.ParseTime(strings.TrimSpace(value)); err == nil {
+			found = true
+			notice.Sunset = t
+		}
+	}
+	for _, value := range h.Values("Warning") {
+		code, text, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if code != "299" {
+			continue
+		}
+		found = true
+		// The agent precedes the quoted text
+		if _, quoted, ok := strings.Cut(text, `"`); ok {
+			text, _, _ = strings.Cut(quoted, `"`)
+		}
+		notice.Warnings = append(notice.Warnings, text)
+	}
+	if !found {
+		return notice, false
+	}
+
+	links := ParseLinkHeader(h)
+	notice.Links = append(links["deprecation"], links["sunset"]...)
+	return notice, true
+}
+
+// checkDeprecation reports a response announcing deprecation
+func (r *request) checkDeprecation(url string, h http.Header) {
+	m := r.client.deprecation
+	if m == nil {
+		return
+	}
+	notice, ok := parseDeprecation(h)
+	if !ok {
+		return
+	}
+	r.client.stats.deprecated.Add(1)
+
+	if _, reported := m.seen.LoadOrStore(r.method+" "+r.endpoint, true); reported {
+		return
+	}
+	notice.Method = r.method
+	notice.Route = r.endpoint
+	notice.URL = url
+
+	if m.cfg.OnDeprecation != nil {
+		m.cfg.OnDeprecation(notice)
+		return
+	}
+	if logger := r.client.currentLogger(); logger != nil {
+		fields := map[string]interface{}{
+			"method": notice.Method,
+			"route":  notice.Route,
+		}
+		if !notice.Sunset.IsZero() {
+			fields["sunset"] = notice.Sunset.Format(time.RFC3339)
+		}
+		if len(notice.Warnings) > 0 {
+			fields["warnings"] = notice.Warnings
+		}
+		logger.Log(LogLevelWarn, "Upstream endpoint is deprecated", fields)
+	}
+}
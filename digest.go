@@ -0,0 +1,123 @@
+package goclient
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// DigestAlgorithm selects the integrity header computed over request bodies
+type DigestAlgorithm int
+
+const (
+	// DigestNone disables body digests
+	DigestNone DigestAlgorithm = iota
+	// DigestContentMD5 sets the Content-MD5 header (RFC 1864)
+	DigestContentMD5
+	// DigestSHA256 sets a legacy Digest header using SHA-256 (RFC 3230)
+	DigestSHA256
+	// DigestSHA512 sets a legacy Digest header using SHA-512 (RFC 3230)
+	DigestSHA512
+	// ContentDigestSHA256 sets a Content-Digest header using SHA-256 (RFC 9530)
+	ContentDigestSHA256
+	// ContentDigestSHA512 sets a Content-Digest header using SHA-512 (RFC 9530)
+	ContentDigestSHA512
+)
+
+func (a DigestAlgorithm) newHash() hash.Hash {
+	switch a {
+	case DigestContentMD5:
+		return md5.New()
+	case DigestSHA256, ContentDigestSHA256:
+		return sha256.New()
+	case DigestSHA512, ContentDigestSHA512:
+		return sha512.New()
+	default:
+		return nil
+	}
+}
+
+// header formats a computed sum into the header name and value for the algorithm
+func (a DigestAlgorithm) header(sum []byte) (string, string) {
+	encoded := base64.StdEncoding.EncodeToString(sum)
+	switch a {
+	case DigestContentMD5:
+		return "Content-MD5", encoded
+	case DigestSHA256:
+		return "Digest", "SHA-256=" + encoded
+	case DigestSHA512:
+		return "Digest", "SHA-512=" + encoded
+	case ContentDigestSHA256:
+		return "Content-Digest", "sha-256=:" + encoded + ":"
+	case ContentDigestSHA512:
+		return "Content-Digest", "sha-512=:" + encoded + ":"
+	default:
+		return "", ""
+	}
+}
+
+// digestWriter hashes everything written through it so the body only has to
+// be streamed once regardless of where it comes from
+type digestWriter struct {
+	alg  DigestAlgorithm
+	hash hash.Hash
+}
+
+func newDigestWriter(alg DigestAlgorithm) *digestWriter {
+	h := alg.newHash()
+	if h == nil {
+		return nil
+	}
+	return &digestWriter{alg: alg, hash: h}
+}
+
+func (d *digestWriter) Write(p []byte) (int, error) {
+	return d.hash.Write(p)
+}
+
+// apply sets the digest header on the request
+func (d *digestWriter) apply(header http.Header) {
+	name, value := d.alg.header(d.hash.Sum(nil))
+	if name != "" {
+		header.Set(name, value)
+	}
+}
+
+// computeDigest streams r through the configured algorithm and sets the
+// resulting header
+func computeDigest(alg DigestAlgorithm, r io.Reader, header http.Header) error {
+	d := newDigestWriter(alg)
+	if d == nil {
+		return nil
+	}
+	if _, err := io.Copy(d, r); err != nil {
+		return err
+	}
+	d.apply(header)
+	return nil
+}
+
+// digestBody computes the digest of the request body. Streamed bodies are
+// hashed through a rewound copy so they never have to be held in memory.
+func (r *request) digestBody(alg DigestAlgorithm, buffered []byte, header http.Header) error {
+	if !r.stream {
+		return computeDigest(alg, bytes.NewReader(buffered), header)
+	}
+	if r.bodyRewind == nil {
+		return ErrBodyNotReplayable
+	}
+
+	body, err := r.bodyRewind()
+	if err != nil {
+		return err
+	}
+	if c, ok := body.(io.Closer); ok {
+		defer c.Close()
+	}
+	return computeDigest(alg, body, header)
+}
@@ -0,0 +1,264 @@
+package goclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type resolverEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// resolverCache keeps resolved addresses for a refresh interval so service
+// registries are polled rather than queried on every request
+type resolverCache struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[string]resolverEntry
+}
+
+func newResolverCache(ttl time.Duration, clock Clock) *resolverCache {
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &resolverCache{ttl: ttl, clock: clock, entries: make(map[string]resolverEntry)}
+}
+
+// get returns the cached addresses for key or calls fetch to refresh them
+func (c *resolverCache) get(key string, fetch func() ([]string, error)) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.clock.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("service %s has no healthy instances", key)
+	}
+
+	c.mu.Lock()
+	c.entries[key] = resolverEntry{addrs: addrs, expires: c.clock.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// ConsulResolverConfig configures a ConsulResolver
+type ConsulResolverConfig struct {
+	// Address is the Consul HTTP API, default http://127.0.0.1:8500
+	Address string
+	// Token is sent as X-Consul-Token when set
+	Token      string
+	Datacenter string
+	// RefreshInterval is how long instances are cached, default 10s
+	RefreshInterval time.Duration
+	// Clock times the refresh interval, default the system clock
+	Clock Clock
+}
+
+// ConsulResolver resolves hosts written in Consul DNS form,
+// "service.service.consul" or "tag.service.service.consul", to the
+// instances passing their health checks in the Consul catalog
+type ConsulResolver struct {
+	cfg    ConsulResolverConfig
+	client Client
+	cache  *resolverCache
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+// NewConsulResolver returns a resolver backed by the Consul health API
+func NewConsulResolver(cfg ConsulResolverConfig) *ConsulResolver {
+	if cfg.Address == "" {
+		cfg.Address = "http://127.0.0.1:8500"
+	}
+
+	client := New(Config{
+		BaseURL: strings.TrimRight(cfg.Address, "/"),
+		Timeout: 10 * time.Second,
+		Clock:   cfg.Clock,
+	})
+	if cfg.Token != "" {
+		client.SetGlobalHeader("X-Consul-Token", cfg.Token)
+	}
+
+	return &ConsulResolver{cfg: cfg, client: client, cache: newResolverCache(cfg.RefreshInterval, cfg.Clock)}
+}
+
+// Resolve implements Resolver interface
+func (r *ConsulResolver) Resolve(ctx context.Context, host string) ([]string, error) {
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	if !strings.HasSuffix(name, ".service.consul") {
+		return nil, nil
+	}
+
+	labels := strings.Split(strings.TrimSuffix(name, ".service.consul"), ".")
+	var tag, service string
+	switch len(labels) {
+	case 1:
+		service = labels[0]
+	case 2:
+		tag, service = labels[0], labels[1]
+	default:
+		return nil, nil
+	}
+
+	return r.cache.get(name, func() ([]string, error) {
+		return r.instances(ctx, service, tag)
+	})
+}
+
+func (r *ConsulResolver) instances(ctx context.Context, service, tag string) ([]string, error) {
+	rb := r.client.GetWithContext(ctx, "/v1/health/service/{service}").
+		SetPathParam("service", service).
+		SetQueryParam("passing", "true")
+	if tag != "" {
+		rb.SetQueryParam("tag", tag)
+	}
+	if r.cfg.Datacenter != "" {
+		rb.SetQueryParam("dc", r.cfg.Datacenter)
+	}
+
+	var entries []consulServiceEntry
+	if err := rb.Into(&entries); err != nil {
+		return nil, fmt.Errorf("consul health %s: %w", service, err)
+	}
+
+	addrs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		addr := e.Service.Address
+		if addr == "" {
+			addr = e.Node.Address
+		}
+		addrs = append(addrs, net.JoinHostPort(addr, strconv.Itoa(e.Service.Port)))
+	}
+	return addrs, nil
+}
+
+// EtcdResolverConfig configures an EtcdResolver
+type EtcdResolverConfig struct {
+	// Endpoint is the etcd v3 HTTP gateway, default http://127.0.0.1:2379
+	Endpoint string
+	// Prefix is the key prefix under which services register, default "/services"
+	Prefix string
+	// Domain is the host suffix handled by the resolver, default "etcd"
+	Domain string
+	// RefreshInterval is how long instances are cached, default 10s
+	RefreshInterval time.Duration
+	// Clock times the refresh interval, default the system clock
+	Clock Clock
+}
+
+// EtcdResolver resolves hosts such as "orders.etcd" to the addresses stored
+// under the key prefix "/services/orders/". Each value is either a plain
+// "host:port" address or a JSON object with an "Addr" field.
+type EtcdResolver struct {
+	cfg    EtcdResolverConfig
+	client Client
+	cache  *resolverCache
+}
+
+type etcdRangeResponse struct {
+	Kvs []struct {
+		Value string `json:"value"`
+	} `json:"kvs"`
+}
+
+// NewEtcdResolver returns a resolver backed by the etcd v3 JSON gateway
+func NewEtcdResolver(cfg EtcdResolverConfig) *EtcdResolver {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "http://127.0.0.1:2379"
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "/services"
+	}
+	if cfg.Domain == "" {
+		cfg.Domain = "etcd"
+	}
+
+	return &EtcdResolver{
+		cfg: cfg,
+		client: New(Config{
+			BaseURL: strings.TrimRight(cfg.Endpoint, "/"),
+			Timeout: 10 * time.Second,
+			Clock:   cfg.Clock,
+		}),
+		cache: newResolverCache(cfg.RefreshInterval, cfg.Clock),
+	}
+}
+
+// Resolve implements Resolver interface
+func (r *EtcdResolver) Resolve(ctx context.Context, host string) ([]string, error) {
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	service := strings.TrimSuffix(name, "."+r.cfg.Domain)
+	if service == name || service == "" || strings.Contains(service, ".") {
+		return nil, nil
+	}
+
+	return r.cache.get(name, func() ([]string, error) {
+		return r.instances(ctx, service)
+	})
+}
+
+func (r *EtcdResolver) instances(ctx context.Context, service string) ([]string, error) {
+	prefix := strings.TrimRight(r.cfg.Prefix, "/") + "/" + service + "/"
+	rangeEnd := []byte(prefix)
+	rangeEnd[len(rangeEnd)-1]++
+
+	var out etcdRangeResponse
+	err := r.client.PostWithContext(ctx, "/v3/kv/range").
+		SetBody(map[string]string{
+			"key":       base64.StdEncoding.EncodeToString([]byte(prefix)),
+			"range_end": base64.StdEncoding.EncodeToString(rangeEnd),
+		}).
+		Into(&out)
+	if err != nil {
+		return nil, fmt.Errorf("etcd range %s: %w", prefix, err)
+	}
+
+	addrs := make([]string, 0, len(out.Kvs))
+	for _, kv := range out.Kvs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			continue
+		}
+		var endpoint struct{ Addr string }
+		if json.Unmarshal(value, &endpoint) == nil && endpoint.Addr != "" {
+			addrs = append(addrs, endpoint.Addr)
+		} else if addr := strings.TrimSpace(string(value)); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
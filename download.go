@@ -0,0 +1,204 @@
+package goclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when a downloaded file does not match the
+// checksum given with WithSHA256
+var ErrChecksumMismatch = errors.New("goclient: checksum mismatch")
+
+// DownloadOption configures DownloadFile
+type DownloadOption func(*downloadOptions)
+
+type downloadOptions struct {
+	sha256   string
+	noResume bool
+	perm     os.FileMode
+	segments int
+}
+
+// WithSHA256 verifies the downloaded file against a hex-encoded SHA-256 sum
+func WithSHA256(sum string) DownloadOption {
+	return func(o *downloadOptions) {
+		o.sha256 = strings.ToLower(sum)
+	}
+}
+
+// WithoutResume always downloads the whole file, discarding partial data
+// left by an earlier attempt
+func WithoutResume() DownloadOption {
+	return func(o *downloadOptions) {
+		o.noResume = true
+	}
+}
+
+// WithFileMode sets the permissions of the downloaded file, 0644 by default
+func WithFileMode(perm os.FileMode) DownloadOption {
+	return func(o *downloadOptions) {
+		o.perm = perm
+	}
+}
+
+// DownloadFile streams the response body to path. Data is written to
+// path+".part" and renamed into place once complete; a later call resumes
+// from an existing .part file with a Range request when the server supports
+// it. The size is checked against Content-Length and, with WithSHA256, the
+// contents against a checksum. A failed transfer keeps the .part file for
+// the next attempt, except after a checksum mismatch or a 416 response.
+func (r *request) DownloadFile(path string, opts ...DownloadOption) error {
+	o := downloadOptions{perm: 0o644}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	part := path + ".part"
+	if o.segments > 1 {
+		if size := r.rangeSize(); size >= int64(o.segments)*minSegmentSize {
+			if err := r.downloadSegments(part, size, o.segments, o.perm); err != nil {
+				return err
+			}
+			return finishDownload(part, path, o.sha256, nil)
+		}
+	}
+
+	var offset int64
+	if o.noResume {
+		os.Remove(part)
+	} else if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+	if offset > 0 {
+		r.SetHeader("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	r.skipCache = true
+
+	var sum hash.Hash
+	if o.sha256 != "" {
+		sum = sha256.New()
+	}
+
+	streamed := false
+	var written, expected int64 = 0, -1
+	r.consume = func(resp *http.Response, body io.Reader) error {
+		streamed = true
+		flags := os.O_CREATE | os.O_WRONLY
+		if offset > 0 && resp.StatusCode == http.StatusPartialContent {
+			start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+			if !ok || start != offset {
+				return fmt.Errorf("unexpected Content-Range %q for offset %d", resp.Header.Get("Content-Range"), offset)
+			}
+			expected = total
+			flags |= os.O_APPEND
+			if sum != nil {
+				if err := hashFile(sum, part); err != nil {
+					return err
+				}
+			}
+		} else {
+			// The server ignored the range, so start over
+			offset = 0
+			flags |= os.O_TRUNC
+			if resp.Header.Get("Content-Encoding") == "" {
+				expected = resp.ContentLength
+			}
+		}
+
+		f, err := os.OpenFile(part, flags, o.perm)
+		if err != nil {
+			return err
+		}
+		var w io.Writer = f
+		if sum != nil {
+			w = io.MultiWriter(f, sum)
+		}
+		n, err := io.Copy(w, body)
+		written = offset + n
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+
+	resp, err := r.Result()
+	if err != nil {
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			os.Remove(part)
+		}
+		return err
+	}
+	if !streamed {
+		return fmt.Errorf("download %s: unexpected status %d", path, resp.StatusCode)
+	}
+
+	if expected >= 0 && written != expected {
+		return fmt.Errorf("download %s: got %d bytes, expected %d", path, written, expected)
+	}
+	return finishDownload(part, path, o.sha256, sum)
+}
+
+// finishDownload checks a complete part file against the expected SHA-256,
+// hashing the file unless sum already holds its digest, and moves it to path
+func finishDownload(part, path, want string, sum hash.Hash) error {
+	if want != "" {
+		if sum == nil {
+			sum = sha256.New()
+			if err := hashFile(sum, part); err != nil {
+				return err
+			}
+		}
+		if got := hex.EncodeToString(sum.Sum(nil)); got != want {
+			os.Remove(part)
+			return fmt.Errorf("%w: %s has sha256 %s, expected %s", ErrChecksumMismatch, path, got, want)
+		}
+	}
+	return os.Rename(part, path)
+}
+
+// parseContentRange parses "bytes start-end/total". An unknown total is -1.
+func parseContentRange(value string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, total, true
+}
+
+// hashFile feeds the contents of path to h
+func hashFile(h hash.Hash, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
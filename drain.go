@@ -0,0 +1,82 @@
+package goclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// destination is a base URL together with the number of requests in flight
+// to it
+type destination struct {
+	baseURL string
+	active  atomic.Int64
+	retired atomic.Bool
+	once    sync.Once
+	drained chan struct{}
+}
+
+func newDestination(baseURL string) *destination {
+	return &destination{baseURL: baseURL, drained: make(chan struct{})}
+}
+
+// release ends a request started by acquireDestination
+func (d *destination) release() {
+	if d.active.Add(-1) == 0 && d.retired.Load() {
+		d.once.Do(func() { close(d.drained) })
+	}
+}
+
+// retire stops new requests from using d
+func (d *destination) retire() {
+	d.retired.Store(true)
+	if d.active.Load() == 0 {
+		d.once.Do(func() { close(d.drained) })
+	}
+}
+
+// base returns the current base URL
+func (c *client) base() string {
+	return c.dest.Load().baseURL
+}
+
+// acquireDestination returns the current destination with the request
+// counted as in flight to it
+func (c *client) acquireDestination() *destination {
+	for {
+		d := c.dest.Load()
+		d.active.Add(1)
+		if !d.retired.Load() {
+			return d
+		}
+		// Switched concurrently; the new destination is already stored
+		d.release()
+	}
+}
+
+// SwitchBaseURL sends new requests to baseURL immediately while requests
+// in flight to the previous base URL finish, then closes idle connections
+// so the previous destination is released. Connections to the new base URL
+// are opened on demand. It blocks until the previous destination is
+// drained or ctx is done, whichever comes first; in the latter case idle
+// connections are still closed and ctx's error is returned, leaving the
+// remaining requests bounded by their own timeouts.
+func (c *client) SwitchBaseURL(ctx context.Context, baseURL string) error {
+	if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("goclient: invalid base URL %q", baseURL)
+	}
+
+	old := c.dest.Swap(newDestination(baseURL))
+	old.retire()
+
+	var err error
+	select {
+	case <-old.drained:
+	case <-ctx.Done():
+		err = fmt.Errorf("drain %s: %w", old.baseURL, ctx.Err())
+	}
+	c.httpClient.CloseIdleConnections()
+	return err
+}
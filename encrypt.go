@@ -0,0 +1,54 @@
+package goclient
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrDecrypt is returned when persisted data cannot be decrypted, because
+// the key is wrong or the data was modified
+var ErrDecrypt = errors.New("goclient: cannot decrypt persisted data")
+
+// Cipher encrypts state persisted to disk, such as outbox and journal
+// entries, with AES-GCM so tokens and personal data are never written in
+// plaintext. Each record gets a random nonce.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher from a 16, 24 or 32 byte key, selecting
+// AES-128, AES-192 or AES-256
+func NewCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("goclient: cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("goclient: cipher: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts plaintext, returning the nonce followed by the ciphertext
+func (c *Cipher) Seal(plaintext []byte) []byte {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	rand.Read(nonce)
+	return c.aead.Seal(nonce, nonce, plaintext, nil)
+}
+
+// Open decrypts data produced by Seal
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(data) < n+c.aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := c.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
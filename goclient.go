@@ -3,15 +3,18 @@ package goclient
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,23 +24,63 @@ type Client interface {
 	Put(endpoint string) RequestBuilder
 	Patch(endpoint string) RequestBuilder
 	Delete(endpoint string) RequestBuilder
+	Head(endpoint string) RequestBuilder
+	Options(endpoint string) RequestBuilder
+	Request(method, endpoint string) RequestBuilder
+	GraphQL(endpoint string) *GraphQLRequest
 
 	GetWithContext(ctx context.Context, endpoint string) RequestBuilder
 	PostWithContext(ctx context.Context, endpoint string) RequestBuilder
 	PutWithContext(ctx context.Context, endpoint string) RequestBuilder
 	PatchWithContext(ctx context.Context, endpoint string) RequestBuilder
 	DeleteWithContext(ctx context.Context, endpoint string) RequestBuilder
+	HeadWithContext(ctx context.Context, endpoint string) RequestBuilder
+	OptionsWithContext(ctx context.Context, endpoint string) RequestBuilder
+	RequestWithContext(ctx context.Context, method, endpoint string) RequestBuilder
+	GraphQLWithContext(ctx context.Context, endpoint string) *GraphQLRequest
 
 	SetBearerToken(token string) Client
+	WithBearerToken(token string) Client
 	WithBasicAuth(username, password string) Client
+	WithOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) Client
+	SetTokenProvider(p TokenProvider) Client
+	OnUnauthorized(refresh func(ctx context.Context) error) Client
+	ReplayJournal(ctx context.Context) (int, error)
+	Clone() Client
+	SwitchBaseURL(ctx context.Context, baseURL string) error
+
+	SetGlobalHeader(key, value string) Client
+	SetHeaderIfAbsent(key, value string) Client
+	RemoveGlobalHeader(key string) Client
 
 	Batch() BatchRequest
 	Pool(workers int) RequestPool
+	PoolWithContext(ctx context.Context, workers int) RequestPool
+	Poll(endpoint string) *Poller
+	Cache() Cache
+
+	Probe(endpoint string) (*ProbeResult, error)
+	ProbeWithContext(ctx context.Context, endpoint string) (*ProbeResult, error)
+	Preconnect(ctx context.Context, hosts ...string) error
+	KeepWarm(ctx context.Context, hosts ...string) (stop func())
+	Prime(ctx context.Context, endpoints ...string) error
 
 	// Debugging and logging
 	EnableDebug() Client
 	DisableDebug() Client
 	SetLogger(logger Logger) Client
+	SetLoggingOptions(opts LoggingOptions) Client
+	DebugString() string
+
+	OnDecode(hook DecodeHook) Client
+	Use(mw ...Middleware) Client
+	OnBeforeRequest(hook RequestHook) Client
+	OnAfterResponse(hook ResponseHook) Client
+	ThrottleStats() map[string]ThrottleState
+	MaintenanceStats() map[string]MaintenanceState
+	DebugHandler() http.Handler
+	RegisterCodec(contentType string, marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) Client
+	PublishExpvar(name string) error
 }
 
 // Logger interface for request/response logging
@@ -86,35 +129,77 @@ func (l *DefaultLogger) Log(level LogLevel, message string, fields map[string]in
 	for k, v := range fields {
 		fieldStrs = append(fieldStrs, fmt.Sprintf("%s=%v", k, v))
 	}
-	
+
 	fieldsStr := ""
 	if len(fieldStrs) > 0 {
 		fieldsStr = " | " + strings.Join(fieldStrs, " | ")
 	}
-	
+
 	l.logger.Printf("[%s] %s%s", level.String(), message, fieldsStr)
 }
 
 type RequestBuilder interface {
 	SetHeader(key, value string) RequestBuilder
+	SetHeaderIfAbsent(key, value string) RequestBuilder
+	AddHeader(key, value string) RequestBuilder
 	SetHeaders(headers map[string]string) RequestBuilder
 	SetBody(body interface{}) RequestBuilder
+	SetBodyStream(body io.Reader, rewind func() (io.Reader, error)) RequestBuilder
+	SetFile(field, path string) RequestBuilder
+	SetFileReader(field, filename string, reader io.Reader) RequestBuilder
+	SetMultipartFields(fields map[string]string) RequestBuilder
+	SetPathParam(name, value string) RequestBuilder
+	SetPathParams(params map[string]string) RequestBuilder
 	SetQueryParam(key, value string) RequestBuilder
 	SetQueryParams(params map[string]string) RequestBuilder
+	SetQueryParamsFromStruct(v interface{}) RequestBuilder
+	AddQueryParam(key, value string) RequestBuilder
+	SetQueryParamsFromValues(values url.Values) RequestBuilder
 	OnSuccess(fn func(*Response)) RequestBuilder
 	OnError(fn func(*RequestError)) RequestBuilder
 	SetError(v interface{}) RequestBuilder
+	WithTransport(rt http.RoundTripper) RequestBuilder
+	WithMiddleware(mw ...Middleware) RequestBuilder
+	UseMiddleware(mw ...Middleware) RequestBuilder
+	SetBodyDigest(alg DigestAlgorithm) RequestBuilder
+	SetMeta(key string, value interface{}) RequestBuilder
+	SetRetry(count int, backoff time.Duration) RequestBuilder
+	SetRetryCondition(fn func(*Response, error) bool) RequestBuilder
+	TeeBody(w io.Writer) RequestBuilder
+	SetRateLimitBypass() RequestBuilder
+	CaptureHeader(responseHeader, requestHeader string) RequestBuilder
+	CaptureCookie(name, requestHeader string) RequestBuilder
+	SetIfNoneMatch(etag string) RequestBuilder
+	SetIfModifiedSince(t time.Time) RequestBuilder
+	Revalidate(prev *Response) RequestBuilder
+	SetRoutingKey(key string) RequestBuilder
+	SetLocalAddr(ip string) RequestBuilder
+	SetProxy(rawURL string) RequestBuilder
+	SetTokenProvider(p TokenProvider) RequestBuilder
+	SetAuth(a Authenticator) RequestBuilder
+	SetAcceptLanguage(tags ...string) RequestBuilder
+	SetJSONDecodeOptions(opts JSONDecodeOptions) RequestBuilder
 	Into(v interface{}) error
+	IntoStream(v interface{}) error
+	IntoWriter(w io.Writer) error
+	Stream() (io.ReadCloser, error)
+	DownloadFile(path string, opts ...DownloadOption) error
+	IntoWithHeaders(v interface{}, h interface{}) error
+	IntoCSV(v interface{}) error
+	Extract(path string, v interface{}) error
 	Result() (*Response, error)
+	DebugString() string
 }
 
 type BatchRequest interface {
 	Add(rb RequestBuilder) BatchRequest
 	Execute(ctx context.Context) ([]*Response, []error)
+	ExecuteAll(ctx context.Context) ([]*Response, error)
 }
 
 type RequestPool interface {
 	Submit(rb RequestBuilder) <-chan Result
+	Drain() error
 	Wait()
 }
 
@@ -124,18 +209,62 @@ type Result struct {
 }
 
 type client struct {
-	httpClient    *http.Client
-	baseURL       string
-	globalHeaders map[string]string
-	interceptor   http.RoundTripper
-	pool          sync.Pool
-	bearerToken   string
-	basicAuth     struct {
+	httpClient     *http.Client
+	dest           atomic.Pointer[destination]
+	split          *TrafficSplit
+	headersMu      sync.RWMutex
+	globalHeaders  map[string]string
+	defaultHeaders map[string]string
+	globalQuery    map[string]string
+	interceptor    http.RoundTripper
+	transport      http.RoundTripper
+	middlewares    []Middleware
+	pool           sync.Pool
+	tokenProvider  TokenProvider
+	onUnauthorized func(ctx context.Context) error
+	basicAuth      struct {
 		Username string
 		Password string
 	}
-	debugEnabled  bool
-	logger        Logger
+	debugEnabled          atomic.Bool
+	logger                atomic.Pointer[Logger]
+	bodyDigest            DigestAlgorithm
+	maxRequestBytes       int64
+	disableStatusError    bool
+	disableCompression    bool
+	maxResponseBytes      int64
+	maxDecompressionRatio float64
+	clock                 Clock
+	rand                  RandSource
+	newID                 IDGenerator
+
+	cache *responseCache
+
+	decodeHooks   []DecodeHook
+	beforeRequest []RequestHook
+	afterResponse []ResponseHook
+	errorDecoder  func(status int, body []byte) error
+	retry         retryPolicy
+	limiter       *rateLimiter
+	auth          Authenticator
+	journal       *Journal
+	outbox        *Outbox
+	throttler     *throttler
+	maintenance   *maintenance
+	breaker       *circuitBreaker
+	stats         *clientStats
+	codecs        map[string]codec
+	coalescer     *coalescer
+	pprofLabels   bool
+	jsonDecode    JSONDecodeOptions
+	charset       CharsetDetector
+	asyncNotify   bool
+	deadlineWarn  *DeadlineWarningConfig
+	slo           *sloMonitor
+	deprecation   *deprecationMonitor
+	logOpts       atomic.Pointer[LoggingOptions]
+	sniff         bool
+	defaultType   string
 }
 
 type request struct {
@@ -143,16 +272,46 @@ type request struct {
 	method         string
 	endpoint       string
 	ctx            context.Context
-	headers        map[string]string
+	headers        http.Header
+	addedHeaders   http.Header
+	defaultHeaders map[string]string
+
 	body           interface{}
-	queryParams    map[string]string
+	stream         bool
+	bodyRewind     func() (io.Reader, error)
+	form           []formPart
+	consume        func(resp *http.Response, body io.Reader) error
+	consumeErr     error
+	attempts       []Attempt
+	pathParams     map[string]string
+	queryParams    url.Values
+	queryErr       error
 	successHandler func(*Response)
 	errorHandler   func(*RequestError)
 	errorType      interface{}
-	result         interface{}
-	executed       bool
-	response       *Response
-	err            error
+	transport      http.RoundTripper
+	middlewares    []Middleware
+	bodyDigest     *DigestAlgorithm
+	meta           map[string]interface{}
+	retry          *retryPolicy
+	tee            io.Writer
+	bypassLimit    bool
+	captures       []capture
+	revalidate     *Response
+	skipCache      bool
+	routingKey     string
+	localAddr      string
+	proxy          string
+	jsonDecode     *JSONDecodeOptions
+	tokenProvider  TokenProvider
+	journalID      string
+	fromOutbox     bool
+	auth           Authenticator
+
+	result   interface{}
+	executed bool
+	response *Response
+	err      error
 }
 
 type batchRequest struct {
@@ -166,39 +325,201 @@ type batchRequest struct {
 
 type requestPool struct {
 	client   *client
+	ctx      context.Context
 	workers  int
 	jobs     chan RequestBuilder
 	results  chan Result
 	wg       sync.WaitGroup
 	shutdown chan struct{}
+	pending  sync.WaitGroup
+	mu       sync.Mutex
+	failures []error
 }
 
 func New(config ...Config) Client {
 	cfg := defaultConfig(config...)
 
-	transport := http.DefaultTransport
+	if cfg.MaxDecompressionRatio == 0 {
+		cfg.MaxDecompressionRatio = defaultMaxDecompressionRatio
+	}
+
+	clock := cfg.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
 
+	var transport http.RoundTripper
 	if cfg.Interceptor != nil {
 		transport = cfg.Interceptor
+	} else if t, err := newTransport(cfg); err == nil {
+		transport = t
+	} else {
+		transport = RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, err
+		})
+	}
+
+	provider := cfg.ProxyProvider
+	if provider == nil && cfg.ProxyURL != "" {
+		if p, err := NewRoundRobinProxyProvider(0, cfg.ProxyURL); err == nil {
+			provider = p
+		} else {
+			transport = RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, err
+			})
+		}
+	}
+	transport = proxyTransport(transport, !cfg.IgnoreEnvProxy)
+	if cfg.TunnelProxy != "" {
+		// Proxies and source addresses would bypass the tunnel
+		if provider != nil || cfg.LocalAddr != "" {
+			err := errors.New("goclient: a tunnel cannot be combined with a proxy or local address")
+			transport = RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, err
+			})
+			provider = nil
+		}
+		transport = tunnelTransport(transport, cfg.TunnelProxy)
+	} else {
+		transport = localAddrTransport(transport, cfg.LocalAddr)
+	}
+	if provider != nil {
+		transport = proxyMiddleware(provider)(transport)
+	}
+
+	rand := newRandSource(cfg.Rand)
+
+	if cfg.Resolver != nil {
+		var outliers *outlierDetector
+		if cfg.OutlierDetection != nil {
+			outliers = newOutlierDetector(*cfg.OutlierDetection, clock, rand)
+		}
+		transport = resolverMiddleware(cfg.Resolver, outliers, clock)(transport)
+	}
+
+	var throttle *throttler
+	if cfg.Throttle != nil {
+		throttle = newThrottler(*cfg.Throttle, clock)
+		transport = throttle.middleware(transport)
+	}
+
+	var windows *maintenance
+	if cfg.Maintenance != nil {
+		windows = newMaintenance(*cfg.Maintenance, clock)
+		transport = windows.middleware(transport)
+	}
+
+	var breaker *circuitBreaker
+	if cfg.CircuitBreaker != nil {
+		breaker = newCircuitBreaker(*cfg.CircuitBreaker, clock)
+		transport = breaker.middleware(transport)
+	}
+
+	if cfg.Pacing != nil {
+		transport = newPacer(*cfg.Pacing, clock).middleware(transport)
 	}
 
 	c := &client{
 		httpClient: &http.Client{
 			Timeout:   cfg.Timeout,
-			Transport: transport,
+			Transport: chainMiddleware(transport, cfg.Middlewares...),
 		},
-		baseURL:       cfg.BaseURL,
-		globalHeaders: cfg.GlobalHeaders,
-		interceptor:   cfg.Interceptor,
+		transport:             transport,
+		middlewares:           append([]Middleware(nil), cfg.Middlewares...),
+		split:                 cfg.TrafficSplit,
+		globalHeaders:         copyStringMap(cfg.GlobalHeaders),
+		globalQuery:           cfg.GlobalQueryParams,
+		interceptor:           cfg.Interceptor,
+		bodyDigest:            cfg.BodyDigest,
+		maxRequestBytes:       cfg.MaxRequestBytes,
+		disableStatusError:    cfg.DisableStatusError,
+		disableCompression:    cfg.DisableCompression,
+		maxResponseBytes:      cfg.MaxResponseBytes,
+		maxDecompressionRatio: cfg.MaxDecompressionRatio,
+		clock:                 clock,
+		rand:                  rand,
+		newID:                 newIDGenerator(cfg.IDGenerator),
+
+		cache:        newResponseCache(cfg.CacheTTL, cfg.HTTPCache, cfg.StaleWhileRevalidate, cfg.CacheStore, clock),
+		errorDecoder: cfg.ErrorDecoder,
+		retry:        newRetryPolicy(cfg),
+		limiter:      newRateLimiter(cfg.RateLimit, clock),
+		auth:         cfg.Authenticator,
+		journal:      cfg.Journal,
+		outbox:       cfg.Outbox,
+		throttler:    throttle,
+		maintenance:  windows,
+		breaker:      breaker,
+		stats:        &clientStats{},
+		pprofLabels:  cfg.ProfilerLabels,
+		coalescer:    newCoalescer(cfg.CoalesceWindow, clock),
+		jsonDecode:   cfg.JSONDecode,
+		charset:      cfg.CharsetDetector,
+		asyncNotify:  cfg.AsyncHandlers,
+		deadlineWarn: cfg.DeadlineWarning,
+		slo:          newSLOMonitor(cfg.SLO, clock),
+		deprecation:  newDeprecationMonitor(cfg.Deprecation),
+		sniff:        cfg.SniffContentType,
+		defaultType:  cfg.DefaultContentType,
 	}
 
+	c.dest.Store(newDestination(cfg.BaseURL))
+	if cfg.Logger != nil {
+		c.SetLogger(cfg.Logger)
+	}
+	if cfg.Logging != nil {
+		c.SetLoggingOptions(*cfg.Logging)
+	}
+	if cfg.Debug {
+		c.EnableDebug()
+	}
 	c.pool.New = func() interface{} {
 		return &request{client: c}
 	}
+	if cfg.JSONMarshal != nil || cfg.JSONUnmarshal != nil {
+		c.RegisterCodec("application/json", cfg.JSONMarshal, cfg.JSONUnmarshal)
+	}
+	if c.outbox != nil {
+		c.outbox.start(c)
+	}
 
 	return c
 }
 
+// newTransport builds the base transport from the connection and TLS
+// settings in cfg. Zero values keep the defaults of http.DefaultTransport.
+func newTransport(cfg Config) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLS != nil {
+		tlsConfig, err := cfg.TLS.build()
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = tlsConfig
+	}
+	if cfg.MaxIdleConns > 0 {
+		t.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+	t.DisableKeepAlives = cfg.DisableKeepAlives
+	t.DisableCompression = cfg.DisableCompression
+	return t, nil
+}
+
 func (c *client) Batch() BatchRequest {
 	return &batchRequest{
 		client:    c,
@@ -209,12 +530,19 @@ func (c *client) Batch() BatchRequest {
 }
 
 func (c *client) Pool(workers int) RequestPool {
+	return c.PoolWithContext(context.Background(), workers)
+}
+
+// PoolWithContext creates a pool whose requests are also canceled when ctx
+// is done, in addition to their own contexts
+func (c *client) PoolWithContext(ctx context.Context, workers int) RequestPool {
 	if workers <= 0 {
 		workers = 10 // Default number of workers
 	}
 
 	pool := &requestPool{
 		client:   c,
+		ctx:      ctx,
 		workers:  workers,
 		jobs:     make(chan RequestBuilder),
 		results:  make(chan Result),
@@ -223,10 +551,16 @@ func (c *client) Pool(workers int) RequestPool {
 
 	// Start workers
 	pool.start()
+	c.stats.pools.Add(1)
 
 	return pool
 }
 
+// Cache returns the client's response cache
+func (c *client) Cache() Cache {
+	return c.cache
+}
+
 // Simple methods (use context.Background() internally)
 func (c *client) Get(endpoint string) RequestBuilder {
 	return c.GetWithContext(context.Background(), endpoint)
@@ -248,78 +582,197 @@ func (c *client) Delete(endpoint string) RequestBuilder {
 	return c.DeleteWithContext(context.Background(), endpoint)
 }
 
-// Context-aware methods for explicit context control
-func (c *client) GetWithContext(ctx context.Context, endpoint string) RequestBuilder {
+func (c *client) Head(endpoint string) RequestBuilder {
+	return c.HeadWithContext(context.Background(), endpoint)
+}
+
+func (c *client) Options(endpoint string) RequestBuilder {
+	return c.OptionsWithContext(context.Background(), endpoint)
+}
+
+// Request builds a request with any method, such as PROPFIND or PURGE.
+// Methods are case-sensitive and sent exactly as given.
+func (c *client) Request(method, endpoint string) RequestBuilder {
+	return c.RequestWithContext(context.Background(), method, endpoint)
+}
+
+// newRequest takes a request from the pool and prepares it for method and endpoint
+func (c *client) newRequest(ctx context.Context, method, endpoint string) *request {
 	req := c.pool.Get().(*request)
 	req.reset()
-	req.method = http.MethodGet
+	req.method = method
 	req.endpoint = endpoint
 	req.ctx = ctx
 	return req
 }
 
+// Context-aware methods for explicit context control
+func (c *client) GetWithContext(ctx context.Context, endpoint string) RequestBuilder {
+	return c.newRequest(ctx, http.MethodGet, endpoint)
+}
+
 func (c *client) PostWithContext(ctx context.Context, endpoint string) RequestBuilder {
-	req := c.pool.Get().(*request)
-	req.reset()
-	req.method = http.MethodPost
-	req.endpoint = endpoint
-	req.ctx = ctx
-	return req
+	return c.newRequest(ctx, http.MethodPost, endpoint)
 }
 
 func (c *client) PutWithContext(ctx context.Context, endpoint string) RequestBuilder {
-	req := c.pool.Get().(*request)
-	req.reset()
-	req.method = http.MethodPut
-	req.endpoint = endpoint
-	req.ctx = ctx
-	return req
+	return c.newRequest(ctx, http.MethodPut, endpoint)
 }
 
 func (c *client) PatchWithContext(ctx context.Context, endpoint string) RequestBuilder {
-	req := c.pool.Get().(*request)
-	req.reset()
-	req.method = http.MethodPatch
-	req.endpoint = endpoint
-	req.ctx = ctx
-	return req
+	return c.newRequest(ctx, http.MethodPatch, endpoint)
 }
 
 func (c *client) DeleteWithContext(ctx context.Context, endpoint string) RequestBuilder {
-	req := c.pool.Get().(*request)
-	req.reset()
-	req.method = http.MethodDelete
-	req.endpoint = endpoint
-	req.ctx = ctx
-	return req
+	return c.newRequest(ctx, http.MethodDelete, endpoint)
+}
+
+func (c *client) HeadWithContext(ctx context.Context, endpoint string) RequestBuilder {
+	return c.newRequest(ctx, http.MethodHead, endpoint)
+}
+
+func (c *client) OptionsWithContext(ctx context.Context, endpoint string) RequestBuilder {
+	return c.newRequest(ctx, http.MethodOptions, endpoint)
+}
+
+func (c *client) RequestWithContext(ctx context.Context, method, endpoint string) RequestBuilder {
+	return c.newRequest(ctx, method, endpoint)
 }
 
 func (c *client) SetBearerToken(token string) Client {
-	c.bearerToken = token
+	if token == "" {
+		c.tokenProvider = nil
+	} else {
+		c.tokenProvider = StaticToken(token)
+	}
 	return c
 }
 
+// WithBearerToken returns a copy of the client that authenticates with
+// token, leaving c unchanged. Unlike SetBearerToken it is safe to call while
+// c serves requests, for example to derive a client per tenant.
+func (c *client) WithBearerToken(token string) Client {
+	clone := c.clone()
+	clone.SetBearerToken(token)
+	return clone
+}
+
+// WithBasicAuth returns a copy of the client that authenticates with basic
+// auth credentials, leaving c unchanged
 func (c *client) WithBasicAuth(username, password string) Client {
-	c.basicAuth.Username = username
-	c.basicAuth.Password = password
-	return c
+	clone := c.clone()
+	clone.basicAuth.Username = username
+	clone.basicAuth.Password = password
+	return clone
+}
+
+// Clone returns an independent copy of the client. Setters on the copy do
+// not affect c. The copy shares the connection pool, response cache, rate
+// limiter and other runtime state with c.
+func (c *client) Clone() Client {
+	return c.clone()
+}
+
+func (c *client) clone() *client {
+	c.headersMu.RLock()
+	globalHeaders := copyStringMap(c.globalHeaders)
+	defaultHeaders := copyStringMap(c.defaultHeaders)
+	c.headersMu.RUnlock()
+
+	httpClient := *c.httpClient
+	clone := &client{
+		httpClient:            &httpClient,
+		split:                 c.split,
+		globalHeaders:         globalHeaders,
+		defaultHeaders:        defaultHeaders,
+		globalQuery:           copyStringMap(c.globalQuery),
+		interceptor:           c.interceptor,
+		transport:             c.transport,
+		middlewares:           slices.Clone(c.middlewares),
+		tokenProvider:         c.tokenProvider,
+		onUnauthorized:        c.onUnauthorized,
+		basicAuth:             c.basicAuth,
+		bodyDigest:            c.bodyDigest,
+		maxRequestBytes:       c.maxRequestBytes,
+		disableStatusError:    c.disableStatusError,
+		disableCompression:    c.disableCompression,
+		maxResponseBytes:      c.maxResponseBytes,
+		maxDecompressionRatio: c.maxDecompressionRatio,
+		clock:                 c.clock,
+		rand:                  c.rand,
+		newID:                 c.newID,
+
+		cache:         c.cache,
+		decodeHooks:   slices.Clone(c.decodeHooks),
+		beforeRequest: slices.Clone(c.beforeRequest),
+		afterResponse: slices.Clone(c.afterResponse),
+		errorDecoder:  c.errorDecoder,
+		retry:         c.retry,
+		limiter:       c.limiter,
+		auth:          c.auth,
+		journal:       c.journal,
+		outbox:        c.outbox,
+		throttler:     c.throttler,
+		maintenance:   c.maintenance,
+		breaker:       c.breaker,
+		stats:         c.stats,
+		codecs:        maps.Clone(c.codecs),
+		coalescer:     c.coalescer,
+		pprofLabels:   c.pprofLabels,
+		jsonDecode:    c.jsonDecode,
+		charset:       c.charset,
+		asyncNotify:   c.asyncNotify,
+		deadlineWarn:  c.deadlineWarn,
+		slo:           c.slo,
+		deprecation:   c.deprecation,
+		sniff:         c.sniff,
+		defaultType:   c.defaultType,
+	}
+	clone.dest.Store(newDestination(c.base()))
+	clone.debugEnabled.Store(c.debugEnabled.Load())
+	clone.logger.Store(c.logger.Load())
+	clone.logOpts.Store(c.logOpts.Load())
+	clone.pool.New = func() interface{} {
+		return &request{client: clone}
+	}
+	return clone
 }
 
-func (c *client) EnableDebug() Client {
-	c.debugEnabled = true
-	if c.logger == nil {
-		c.logger = NewDefaultLogger()
+// SetGlobalHeader adds or replaces a header sent with every request. It is
+// safe to call while requests are in flight.
+func (c *client) SetGlobalHeader(key, value string) Client {
+	c.headersMu.Lock()
+	defer c.headersMu.Unlock()
+
+	if c.globalHeaders == nil {
+		c.globalHeaders = make(map[string]string)
 	}
+	c.globalHeaders[key] = value
 	return c
 }
 
-func (c *client) DisableDebug() Client {
-	c.debugEnabled = false
+// SetHeaderIfAbsent registers a default header for every request. It never
+// overrides a value set through global or per-request headers, which lets
+// libraries built on top of a client provide defaults safely.
+func (c *client) SetHeaderIfAbsent(key, value string) Client {
+	c.headersMu.Lock()
+	defer c.headersMu.Unlock()
+
+	if c.defaultHeaders == nil {
+		c.defaultHeaders = make(map[string]string)
+	}
+	c.defaultHeaders[key] = value
 	return c
 }
 
-func (c *client) SetLogger(logger Logger) Client {
-	c.logger = logger
+// RemoveGlobalHeader stops sending a global header. It is safe to call while
+// requests are in flight.
+func (c *client) RemoveGlobalHeader(key string) Client {
+	c.headersMu.Lock()
+	defer c.headersMu.Unlock()
+
+	delete(c.globalHeaders, key)
+	delete(c.defaultHeaders, key)
 	return c
 }
 
@@ -348,8 +801,17 @@ func (p *requestPool) worker() {
 func (p *requestPool) Submit(rb RequestBuilder) <-chan Result {
 	resultChan := make(chan Result, 1)
 
+	p.pending.Add(1)
+	p.client.stats.poolPending.Add(1)
 	go func() {
-		resp, err := rb.Result()
+		defer p.pending.Done()
+		defer p.client.stats.poolPending.Add(-1)
+		resp, err := resultWithin(p.ctx, rb)
+		if err != nil {
+			p.mu.Lock()
+			p.failures = append(p.failures, err)
+			p.mu.Unlock()
+		}
 		resultChan <- Result{Response: resp, Error: err}
 		close(resultChan)
 	}()
@@ -357,9 +819,23 @@ func (p *requestPool) Submit(rb RequestBuilder) <-chan Result {
 	return resultChan
 }
 
+// Drain waits for every submitted request to finish and returns their
+// failures as a *MultiError. Failures are reported only once.
+func (p *requestPool) Drain() error {
+	p.pending.Wait()
+
+	p.mu.Lock()
+	failures := p.failures
+	p.failures = nil
+	p.mu.Unlock()
+
+	return newMultiError(failures)
+}
+
 func (p *requestPool) Wait() {
 	close(p.shutdown)
 	p.wg.Wait()
+	p.client.stats.pools.Add(-1)
 }
 
 // Batch request implementation
@@ -369,34 +845,112 @@ func (b *batchRequest) Add(rb RequestBuilder) BatchRequest {
 }
 
 func (b *batchRequest) Execute(ctx context.Context) ([]*Response, []error) {
+	b.client.stats.batches.Add(1)
+	b.mu.Lock()
+	b.responses = make([]*Response, len(b.requests))
+	b.errors = make([]error, len(b.requests))
+	b.mu.Unlock()
+
 	b.wg.Add(len(b.requests))
 
-	for _, req := range b.requests {
-		go func(rb RequestBuilder) {
+	for i, req := range b.requests {
+		go func(i int, rb RequestBuilder) {
 			defer b.wg.Done()
-			resp, err := rb.Result()
+			resp, err := resultWithin(ctx, rb)
 
 			b.mu.Lock()
-			b.responses = append(b.responses, resp)
-			b.errors = append(b.errors, err)
+			b.responses[i] = resp
+			b.errors[i] = err
 			b.mu.Unlock()
-		}(req)
+		}(i, req)
 	}
 
 	b.wg.Wait()
 	return b.responses, b.errors
 }
 
+// resultWithin executes rb so that it is canceled when either its own
+// context or ctx is done. Requests not yet started when ctx is done fail
+// without being sent.
+func resultWithin(ctx context.Context, rb RequestBuilder) (*Response, error) {
+	if ctx == nil {
+		return rb.Result()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, context.Cause(ctx)
+	}
+	if r, ok := rb.(*request); ok && !r.executed {
+		release := r.bindContext(ctx)
+		defer release()
+	}
+	return rb.Result()
+}
+
+// bindContext makes the request also stop when parent is done, keeping the
+// values and deadline of its own context. The returned function releases
+// the binding.
+func (r *request) bindContext(parent context.Context) context.CancelFunc {
+	ctx, cancel := context.WithCancelCause(r.ctx)
+	stop := context.AfterFunc(parent, func() { cancel(context.Cause(parent)) })
+	r.ctx = ctx
+	return func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// ExecuteAll runs the batch and aggregates every failure into a *MultiError.
+// Responses stay positional; failed requests have a nil response.
+func (b *batchRequest) ExecuteAll(ctx context.Context) ([]*Response, error) {
+	responses, errs := b.Execute(ctx)
+
+	wrapped := make([]error, len(errs))
+	for i, err := range errs {
+		if err != nil {
+			wrapped[i] = fmt.Errorf("request %d: %w", i, err)
+		}
+	}
+	return responses, newMultiError(wrapped)
+}
+
 func (r *request) reset() {
 	r.method = ""
 	r.endpoint = ""
 	r.ctx = nil
 	r.headers = nil
+	r.addedHeaders = nil
+	r.defaultHeaders = nil
 	r.body = nil
+	r.stream = false
+	r.bodyRewind = nil
+	r.form = nil
+	r.consume = nil
+	r.consumeErr = nil
+	r.attempts = nil
+	r.pathParams = nil
 	r.queryParams = nil
+	r.queryErr = nil
 	r.successHandler = nil
 	r.errorHandler = nil
 	r.errorType = nil
+	r.transport = nil
+	r.middlewares = nil
+	r.bodyDigest = nil
+	r.meta = nil
+	r.retry = nil
+	r.tee = nil
+	r.bypassLimit = false
+	r.captures = nil
+	r.revalidate = nil
+	r.skipCache = false
+	r.routingKey = ""
+	r.localAddr = ""
+	r.proxy = ""
+	r.jsonDecode = nil
+	r.tokenProvider = nil
+	r.journalID = ""
+	r.fromOutbox = false
+	r.auth = nil
 	r.result = nil
 	r.executed = false
 	r.response = nil
@@ -405,7 +959,8 @@ func (r *request) reset() {
 
 func (r *request) Result() (*Response, error) {
 	if !r.executed {
-		r.execute()
+		r.runLabeled()
+		r.notify()
 	}
 
 	// Return request to pool
@@ -416,17 +971,23 @@ func (r *request) Result() (*Response, error) {
 
 func (r *request) Into(v interface{}) error {
 	resp, err := r.Result()
+	return r.decodeInto(resp, err, v)
+}
+
+// decodeInto decodes a request outcome into v, enriching errors with the
+// registered error type when possible
+func (r *request) decodeInto(resp *Response, err error, v interface{}) error {
 	if err != nil {
 		// If it's a RequestError and we have an error type set, try to unmarshal
 		if reqErr, ok := err.(*RequestError); ok && r.errorType != nil {
-			if unmarshalErr := json.Unmarshal(reqErr.Response, r.errorType); unmarshalErr == nil {
+			if unmarshalErr := r.client.jsonCodec().unmarshal(reqErr.Response, r.errorType); unmarshalErr == nil {
 				// Add the unmarshaled error details to the error
 				return fmt.Errorf("%w: %+v", err, r.errorType)
 			}
 		}
 		return err
 	}
-	return json.Unmarshal(resp.Body, v)
+	return r.client.decode(resp.Headers.Get("Content-Type"), resp.Body, v, r.jsonDecodeOptions())
 }
 
 func (r *request) SetError(v interface{}) RequestBuilder {
@@ -434,62 +995,245 @@ func (r *request) SetError(v interface{}) RequestBuilder {
 	return r
 }
 
+// WithTransport replaces the client's transport for this request only
+func (r *request) WithTransport(rt http.RoundTripper) RequestBuilder {
+	r.transport = rt
+	return r
+}
+
+// WithMiddleware wraps the transport used for this request with additional middleware
+func (r *request) WithMiddleware(mw ...Middleware) RequestBuilder {
+	r.middlewares = append(r.middlewares, mw...)
+	return r
+}
+
+// SetBodyDigest overrides the client's body digest algorithm for this request
+func (r *request) SetBodyDigest(alg DigestAlgorithm) RequestBuilder {
+	r.bodyDigest = &alg
+	return r
+}
+
+// SetMeta attaches a value to the request that middlewares and hooks can read
+// from the request context with MetaFromContext. It is never sent over the wire.
+func (r *request) SetMeta(key string, value interface{}) RequestBuilder {
+	if r.meta == nil {
+		r.meta = make(map[string]interface{})
+	}
+	r.meta[key] = value
+	return r
+}
+
+// SetRetry overrides the client's retry count and initial backoff for this
+// request. A count of zero disables retries. Unlike the client's policy it
+// applies to every method, so a POST or PATCH set to retry may be sent twice.
+func (r *request) SetRetry(count int, backoff time.Duration) RequestBuilder {
+	policy := r.retryPolicy()
+	policy.maxRetries = count
+	policy.allMethods = true
+	if backoff > 0 {
+		policy.waitMin = backoff
+		if policy.waitMax < backoff {
+			policy.waitMax = backoff
+		}
+	}
+	r.retry = &policy
+	return r
+}
+
+// SetRetryCondition replaces the status and transport error checks that decide
+// whether an attempt of this request is retried. The response is nil when the
+// attempt failed before a response was received.
+func (r *request) SetRetryCondition(fn func(*Response, error) bool) RequestBuilder {
+	policy := r.retryPolicy()
+	policy.condition = fn
+	policy.allMethods = true
+	r.retry = &policy
+	return r
+}
+
+// retryPolicy returns the request's retry override or the client's policy
+func (r *request) retryPolicy() retryPolicy {
+	if r.retry != nil {
+		return *r.retry
+	}
+	return r.client.retry
+}
+
+// TeeBody copies the received response body to w, for example to archive it
+// while it is decoded with Into. Only the body of the final attempt is written,
+// after decompression; responses served from the cache are not written.
+func (r *request) TeeBody(w io.Writer) RequestBuilder {
+	r.tee = w
+	return r
+}
+
+// SetRateLimitBypass exempts this request from the client's rate limit
+func (r *request) SetRateLimitBypass() RequestBuilder {
+	r.bypassLimit = true
+	return r
+}
+
+// UseMiddleware is an alias of WithMiddleware
+func (r *request) UseMiddleware(mw ...Middleware) RequestBuilder {
+	return r.WithMiddleware(mw...)
+}
+
+func (r *request) digestAlgorithm() DigestAlgorithm {
+	if r.bodyDigest != nil {
+		return *r.bodyDigest
+	}
+	return r.client.bodyDigest
+}
+
+// httpClient returns the http.Client to use for this request, honouring any
+// per-request transport or middleware overrides. Client middlewares always
+// run before request middlewares.
+func (r *request) httpClient() *http.Client {
+	if r.transport == nil && len(r.middlewares) == 0 {
+		return r.client.httpClient
+	}
+
+	transport := r.client.transport
+	if r.transport != nil {
+		transport = r.transport
+	}
+
+	chain := make([]Middleware, 0, len(r.client.middlewares)+len(r.middlewares))
+	chain = append(chain, r.client.middlewares...)
+	chain = append(chain, r.middlewares...)
+
+	hc := *r.client.httpClient
+	hc.Transport = chainMiddleware(transport, chain...)
+	return &hc
+}
+
 // RequestBuilder implementation methods
 func (r *request) SetHeader(key, value string) RequestBuilder {
 	if r.headers == nil {
-		r.headers = make(map[string]string)
+		r.headers = make(http.Header)
 	}
-	r.headers[key] = value
+	r.headers.Set(key, value)
+	r.addedHeaders.Del(key)
 	return r
 }
 
-func (r *request) SetHeaders(headers map[string]string) RequestBuilder {
-	if r.headers == nil {
-		r.headers = make(map[string]string)
+// SetHeaderIfAbsent sets a header only if it is not set explicitly elsewhere,
+// either on this request or through the client's global headers
+func (r *request) SetHeaderIfAbsent(key, value string) RequestBuilder {
+	if r.defaultHeaders == nil {
+		r.defaultHeaders = make(map[string]string)
+	}
+	r.defaultHeaders[key] = value
+	return r
+}
+
+// AddHeader appends a header value without replacing existing values,
+// including those coming from the client's global headers
+func (r *request) AddHeader(key, value string) RequestBuilder {
+	if r.addedHeaders == nil {
+		r.addedHeaders = make(http.Header)
 	}
+	r.addedHeaders.Add(key, value)
+	return r
+}
+
+func (r *request) SetHeaders(headers map[string]string) RequestBuilder {
 	for k, v := range headers {
-		r.headers[k] = v
+		r.SetHeader(k, v)
 	}
 	return r
 }
 
 func (r *request) SetBody(body interface{}) RequestBuilder {
 	r.body = body
+	r.stream = false
+	r.bodyRewind = nil
+	return r
+}
+
+// SetBodyStream sends body without buffering it in memory. The request can
+// only be replayed (e.g. by retrying middleware) when rewind is non-nil;
+// rewind must return a fresh reader positioned at the start of the body.
+func (r *request) SetBodyStream(body io.Reader, rewind func() (io.Reader, error)) RequestBuilder {
+	r.body = body
+	r.stream = true
+	r.bodyRewind = rewind
+	return r
+}
+
+// SetPathParam sets the value substituted for {name} in the endpoint template.
+// Values are path-escaped automatically.
+func (r *request) SetPathParam(name, value string) RequestBuilder {
+	if r.pathParams == nil {
+		r.pathParams = make(map[string]string)
+	}
+	r.pathParams[name] = value
+	return r
+}
+
+// SetPathParams sets several path parameters at once
+func (r *request) SetPathParams(params map[string]string) RequestBuilder {
+	for name, value := range params {
+		r.SetPathParam(name, value)
+	}
 	return r
 }
 
 func (r *request) SetQueryParam(key, value string) RequestBuilder {
 	if r.queryParams == nil {
-		r.queryParams = make(map[string]string)
+		r.queryParams = url.Values{}
 	}
-	r.queryParams[key] = value
+	r.queryParams.Set(key, value)
 	return r
 }
 
 func (r *request) SetQueryParams(params map[string]string) RequestBuilder {
+	for k, v := range params {
+		r.SetQueryParam(k, v)
+	}
+	return r
+}
+
+// AddQueryParam appends a value to key, so repeated keys such as
+// ?id=1&id=2 are preserved
+func (r *request) AddQueryParam(key, value string) RequestBuilder {
 	if r.queryParams == nil {
-		r.queryParams = make(map[string]string)
+		r.queryParams = url.Values{}
 	}
-	for k, v := range params {
-		r.queryParams[k] = v
+	r.queryParams.Add(key, value)
+	return r
+}
+
+// SetQueryParamsFromValues sets every key in values with all of its values,
+// replacing earlier values for those keys
+func (r *request) SetQueryParamsFromValues(values url.Values) RequestBuilder {
+	for key, vs := range values {
+		if r.queryParams == nil {
+			r.queryParams = url.Values{}
+		}
+		r.queryParams[key] = slices.Clone(vs)
 	}
 	return r
 }
 
+// OnSuccess registers a handler called when the request succeeds. Handlers
+// registered after the request has run are called immediately.
 func (r *request) OnSuccess(fn func(*Response)) RequestBuilder {
 	r.successHandler = fn
 	if r.executed && r.err == nil && r.response != nil {
-		fn(r.response)
+		resp := r.response
+		r.runHandler(func() { fn(resp) })
 	}
 	return r
 }
 
+// OnError registers a handler called when the request fails. Handlers
+// registered after the request has run are called immediately.
 func (r *request) OnError(fn func(*RequestError)) RequestBuilder {
 	r.errorHandler = fn
 	if r.executed && r.err != nil {
-		if reqErr, ok := r.err.(*RequestError); ok {
-			fn(reqErr)
-		}
+		reqErr := r.requestError()
+		r.runHandler(func() { fn(reqErr) })
 	}
 	return r
 }
@@ -499,6 +1243,21 @@ type Response struct {
 	StatusCode int
 	Headers    http.Header
 	Body       []byte
+	// FromCache is true when the response was served or revalidated from the cache
+	FromCache bool
+}
+
+// clone returns a copy of the response that shares no mutable state
+func (r *Response) clone() *Response {
+	if r == nil {
+		return nil
+	}
+	return &Response{
+		StatusCode: r.StatusCode,
+		Headers:    r.Headers.Clone(),
+		Body:       append([]byte(nil), r.Body...),
+		FromCache:  r.FromCache,
+	}
 }
 
 // RequestError type remains the same
@@ -507,6 +1266,7 @@ type RequestError struct {
 	URL        string
 	Method     string
 	Response   []byte
+	Attempts   int
 	Err        error
 }
 
@@ -519,40 +1279,82 @@ func (e *RequestError) Unwrap() error {
 	return e.Err
 }
 
+// RequestTooLargeError is returned when a request body exceeds Config.MaxRequestBytes.
+// For streamed bodies Size is a lower bound since reading stops past the limit.
+type RequestTooLargeError struct {
+	Limit int64
+	Size  int64
+}
+
+func (e *RequestTooLargeError) Error() string {
+	return fmt.Sprintf("request body too large: size=%d, limit=%d", e.Size, e.Limit)
+}
+
 func (r *request) execute() {
 	if r.executed {
 		return
 	}
 
-	startTime := time.Now()
+	r.client.stats.begin()
+	defer r.client.stats.end(r)
+
+	startTime := r.client.clock.Now()
+	if r.client.deadlineWarn != nil {
+		defer r.checkDeadline(startTime, r.timeBudget())
+	}
+	if r.client.slo != nil {
+		defer r.observeSLO(startTime)
+	}
 
 	// Prepare URL with query parameters
-	resolvedURL, err := r.client.resolveURL(r.endpoint)
+	path, err := expandPath(r.endpoint, r.pathParams)
+	if err == nil {
+		err = r.queryErr
+	}
+	if err == nil && r.proxy != "" {
+		_, err = parseProxyURL(r.proxy)
+	}
 	if err != nil {
-		r.err = fmt.Errorf("failed to resolve URL: %w", err)
+		r.err = err
 		r.executed = true
 		return
 	}
-
-	parsedURL, err := url.Parse(resolvedURL)
+	dest := r.client.acquireDestination()
+	defer dest.release()
+	parsedURL, err := r.client.resolveURL(dest.baseURL, path)
 	if err != nil {
-		r.err = fmt.Errorf("invalid URL: %w", err)
+		r.err = err
 		r.executed = true
 		return
 	}
 
-	if len(r.queryParams) > 0 {
+	if len(r.client.globalQuery) > 0 || len(r.queryParams) > 0 {
 		q := parsedURL.Query()
-		for k, v := range r.queryParams {
+		for k, v := range r.client.globalQuery {
 			q.Set(k, v)
 		}
+		for k, vs := range r.queryParams {
+			q[k] = vs
+		}
 		parsedURL.RawQuery = q.Encode()
 	}
 
 	// Prepare body
+	if r.form != nil {
+		if err := r.applyForm(); err != nil {
+			r.err = fmt.Errorf("failed to prepare multipart body: %w", err)
+			r.executed = true
+			return
+		}
+	}
 	var bodyReader io.Reader
-	if r.body != nil {
-		bodyBytes, err := r.prepareBody()
+	var bodyBytes []byte
+	if r.stream {
+		if body, ok := r.body.(io.Reader); ok && body != nil {
+			bodyReader = r.streamReader(body)
+		}
+	} else if r.body != nil {
+		bodyBytes, err = r.prepareBody()
 		if err != nil {
 			r.err = fmt.Errorf("failed to prepare request body: %w", err)
 			r.executed = true
@@ -562,90 +1364,231 @@ func (r *request) execute() {
 	}
 
 	// Create request
-	req, err := http.NewRequestWithContext(r.ctx, r.method, parsedURL.String(), bodyReader)
+	ctx := contextWithRequestInfo(r.ctx, RequestInfo{
+		ID:     r.client.newID(),
+		Method: r.method,
+		Route:  r.endpoint,
+		URL:    parsedURL.String(),
+		Meta:   r.meta,
+
+		RoutingKey: r.routingKey,
+		LocalAddr:  r.localAddr,
+		Proxy:      r.proxy,
+	})
+	req, err := http.NewRequestWithContext(ctx, r.method, parsedURL.String(), bodyReader)
 	if err != nil {
 		r.err = fmt.Errorf("failed to create request: %w", err)
 		r.executed = true
 		return
 	}
+	if r.stream && bodyReader != nil {
+		req.GetBody = r.getBody()
+	}
 
 	// Add headers
 	r.addHeaders(req)
+	r.client.acceptEncoding(req)
+
+	// Add body integrity headers
+	if alg := r.digestAlgorithm(); alg != DigestNone && bodyReader != nil {
+		if err := r.digestBody(alg, bodyBytes, req.Header); err != nil {
+			r.err = fmt.Errorf("failed to compute body digest: %w", err)
+			r.executed = true
+			return
+		}
+	}
 
 	// Add authentication headers
-	if r.client.bearerToken != "" {
-		req.Header.Set("Authorization", "Bearer "+r.client.bearerToken)
+	if err := r.authenticate(req); err != nil {
+		r.err = err
+		r.executed = true
+		return
 	}
-	if r.client.basicAuth.Username != "" && r.client.basicAuth.Password != "" {
-		req.SetBasicAuth(r.client.basicAuth.Username, r.client.basicAuth.Password)
+
+	// Serve from cache when possible. The key covers the credentials and the
+	// entry the Vary'd headers, so both must be in place by now.
+	cacheKey := ""
+	var stale *CacheEntry
+	if isCacheableMethod(r.method) && r.client.cache.enabled() {
+		cacheKey = r.client.cache.key(req)
+	}
+	if cacheKey != "" && !r.skipCache {
+		switch entry, state := r.client.cache.lookup(cacheKey, req.Header); state {
+		case cacheStale:
+			r.refreshInBackground(cacheKey)
+			fallthrough
+		case cacheFresh:
+			r.response = entry.Response.clone()
+			r.response.FromCache = true
+			r.executed = true
+			return
+		case cacheRevalidate:
+			stale = entry
+		}
+	}
+	conditional := r.conditionalEntry(stale)
+	if conditional != nil {
+		conditional.addValidators(req.Header)
+	}
+
+	if err := r.client.runBeforeRequest(req); err != nil {
+		r.err = err
+		r.executed = true
+		return
 	}
 
 	// Log request details if debug is enabled
-	if r.client.debugEnabled && r.client.logger != nil {
-		r.logRequest(req, bodyReader)
+	if logger := r.client.debugLogger(); logger != nil {
+		r.logRequest(logger, req, bodyReader)
+	}
+
+	if !r.bypassLimit {
+		if err := r.client.limiter.wait(r.ctx); err != nil {
+			r.err = err
+			r.executed = true
+			return
+		}
 	}
 
-	// Execute request
-	resp, err := r.client.httpClient.Do(req)
+	// Record mutating requests before they leave the process
+	journalID := ""
+	if r.client.journal != nil && isInvalidatingMethod(r.method) && !r.stream {
+		journalID = r.journalID
+		if journalID == "" {
+			journalID = r.client.newID()
+		}
+		if err := r.client.journal.begin(journalID, r.method, req.URL.String(), req.Header, bodyBytes); err != nil {
+			r.err = fmt.Errorf("failed to journal request: %w", err)
+			r.executed = true
+			return
+		}
+	}
+
+	// Execute request, retrying failed attempts according to the retry policy
+	var resp *http.Response
+	var body []byte
+	var attempts int
+	if r.coalescible() {
+		resp, body, attempts, err = r.client.coalescer.do(r.ctx, coalesceKey(req), r.sendShared(req))
+	} else {
+		resp, body, attempts, err = r.sendWithRetry(req)
+	}
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		retry, authErr := r.reauthenticate(req)
+		if authErr != nil {
+			r.err = authErr
+			r.executed = true
+			return
+		}
+		if retry {
+			var more int
+			resp, body, more, err = r.sendWithRetry(req)
+			attempts += more
+		}
+	}
+	if err == nil && journalID != "" {
+		if err := r.client.journal.complete(journalID); err != nil {
+			r.err = fmt.Errorf("failed to journal response: %w", err)
+			r.executed = true
+			return
+		}
+	}
 	if err != nil {
 		if r.ctx.Err() != nil {
 			r.err = fmt.Errorf("request canceled or timed out: %w", r.ctx.Err())
 		} else {
-			r.err = fmt.Errorf("request failed: %w", err)
+			r.err = r.queueInOutbox(req, bodyBytes, nil, err)
 		}
 		r.executed = true
 		return
 	}
-	defer func() {
-		if resp.Body != nil {
-			_, _ = io.Copy(io.Discard, resp.Body)
-			resp.Body.Close()
+
+	r.checkDeprecation(req.URL.String(), resp.Header)
+
+	// A cached response confirmed by the server is served again
+	var revalidated *Response
+	if conditional != nil && resp.StatusCode == http.StatusNotModified {
+		revalidated = conditional.revalidated(resp.Header)
+		body = revalidated.Body
+	}
+
+	if r.tee != nil {
+		if _, err := r.tee.Write(body); err != nil {
+			r.err = fmt.Errorf("failed to tee response body: %w", err)
+			r.executed = true
+			return
 		}
-	}()
+	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		r.err = fmt.Errorf("error reading response body: %w", err)
+	response := &Response{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header,
+		Body:       body,
+	}
+	if revalidated != nil {
+		response = revalidated
+	}
+	if err := r.client.runAfterResponse(response); err != nil {
+		r.err = err
 		r.executed = true
 		return
 	}
+	body = response.Body
 
-	if resp.StatusCode >= 400 {
+	// Log response details if debug is enabled
+	if logger := r.client.debugLogger(); logger != nil {
+		r.logResponse(logger, resp, body, r.client.clock.Now().Sub(startTime))
+	}
+
+	if resp.StatusCode >= 400 && !r.client.disableStatusError {
 		reqErr := &RequestError{
 			StatusCode: resp.StatusCode,
 			URL:        req.URL.String(),
 			Method:     req.Method,
 			Response:   body,
+			Attempts:   attempts,
 			Err:        fmt.Errorf("request failed with status code %d", resp.StatusCode),
 		}
-
-		// Try to unmarshal error response if error type is set
+		// Try to unmarshal error response if error type is set, otherwise
+		// let the client-wide error decoder map it to a domain error and
+		// finally fall back to RFC 7807 problem details
 		if r.errorType != nil {
-			if err := json.Unmarshal(body, r.errorType); err == nil {
+			if err := r.client.jsonCodec().unmarshal(body, r.errorType); err == nil {
 				reqErr.Err = fmt.Errorf("request failed with status code %d: %+v", resp.StatusCode, r.errorType)
 			}
+		} else if err := r.client.decodeError(resp, body); err != nil {
+			reqErr.Err = err
 		}
+		r.reportAttempts(reqErr)
 
-		r.err = reqErr
+		r.err = r.queueInOutbox(req, bodyBytes, response, reqErr)
 		r.executed = true
 		return
 	}
 
-	r.response = &Response{
-		StatusCode: resp.StatusCode,
-		Headers:    resp.Header,
-		Body:       body,
+	r.response = response
+	r.applyCaptures(resp.Header)
+
+	// Keep the response cache coherent
+	if resp.StatusCode < 400 {
+		if isCacheableMethod(r.method) && r.consume == nil {
+			if cacheKey != "" {
+				r.client.cache.set(cacheKey, req.Header, r.response)
+			}
+		} else if isInvalidatingMethod(r.method) {
+			r.client.cache.invalidateResource(parsedURL)
+		}
 	}
 
-	// Log response details if debug is enabled
-	if r.client.debugEnabled && r.client.logger != nil {
-		duration := time.Since(startTime)
-		r.logResponse(resp, duration)
+	if r.consumeErr != nil {
+		r.err = fmt.Errorf("failed to unmarshal response: %w", r.consumeErr)
+		r.executed = true
+		return
 	}
 
 	// Try to unmarshal success response if result type is set
 	if r.result != nil {
-		if err := json.Unmarshal(body, r.result); err != nil {
+		if err := r.client.decode(response.Headers.Get("Content-Type"), body, r.result, r.jsonDecodeOptions()); err != nil {
 			r.err = fmt.Errorf("failed to unmarshal response: %w", err)
 			r.executed = true
 			return
@@ -660,16 +1603,32 @@ func (r *request) prepareBody() ([]byte, error) {
 		return nil, nil
 	}
 
+	var data []byte
+	var err error
+	limit := r.client.maxRequestBytes
+
 	switch body := r.body.(type) {
 	case []byte:
-		return body, nil
+		data = body
 	case string:
-		return []byte(body), nil
+		data = []byte(body)
 	case io.Reader:
-		return io.ReadAll(body)
+		if limit > 0 {
+			// Never buffer more than one byte past the limit
+			body = io.LimitReader(body, limit+1)
+		}
+		data, err = io.ReadAll(body)
 	default:
-		return json.Marshal(body)
+		data, err = r.client.codecFor(r.headers.Get("Content-Type")).marshal(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, &RequestTooLargeError{Limit: limit, Size: int64(len(data))}
 	}
+	return data, nil
 }
 
 func (r *request) addHeaders(req *http.Request) {
@@ -677,200 +1636,218 @@ func (r *request) addHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
+	// Conditional defaults only lose to headers that were set explicitly
+	r.client.headersMu.RLock()
+	for key, value := range r.client.defaultHeaders {
+		req.Header.Set(key, value)
+	}
+	for key, value := range r.defaultHeaders {
+		req.Header.Set(key, value)
+	}
+
 	// Add global headers
 	for key, value := range r.client.globalHeaders {
 		req.Header.Set(key, value)
 	}
+	r.client.headersMu.RUnlock()
 
 	// Add request-specific headers
-	for key, value := range r.headers {
-		req.Header.Set(key, value)
+	for key, values := range r.headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	for key, values := range r.addedHeaders {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
 	}
 }
 
-func (r *request) logRequest(req *http.Request, bodyReader io.Reader) {
-	fields := map[string]interface{}{
-		"method": req.Method,
-		"url":    req.URL.String(),
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
 	}
-
-	// Log headers
-	if len(req.Header) > 0 {
-		headers := make(map[string]string)
-		for k, v := range req.Header {
-			if k == "Authorization" {
-				headers[k] = "[REDACTED]" // Hide sensitive auth info
-			} else {
-				headers[k] = strings.Join(v, ", ")
-			}
-		}
-		fields["headers"] = headers
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
 	}
+	return out
+}
 
-	// Log query parameters
-	if len(req.URL.Query()) > 0 {
-		fields["query_params"] = req.URL.Query()
+// resolveURL joins endpoint to the base URL and parses the result
+func (h *client) resolveURL(baseURL, endpoint string) (*url.URL, error) {
+	if h.split != nil {
+		baseURL = h.split.pick(h.rand, baseURL)
 	}
 
-	// Log request body if present
-	if bodyReader != nil {
-		if bodyBytes, ok := r.body.([]byte); ok && len(bodyBytes) > 0 {
-			bodyStr := string(bodyBytes)
-			if len(bodyStr) > 1000 {
-				bodyStr = bodyStr[:1000] + "... [truncated]"
+	// Absolute URLs, e.g. from Link headers, bypass the base URL. Paths
+	// cannot carry a scheme, which saves parsing the common case twice.
+	if baseURL == "" || !strings.HasPrefix(endpoint, "/") {
+		if u, err := url.Parse(endpoint); baseURL == "" || (err == nil && u.IsAbs()) {
+			if err != nil {
+				return nil, fmt.Errorf("invalid URL: %w", err)
 			}
-			fields["body"] = bodyStr
-		} else if r.body != nil {
-			fields["body"] = "[non-string body]"
+			return u, nil
 		}
 	}
 
-	r.client.logger.Log(LogLevelInfo, "HTTP Request", fields)
-}
-
-func (r *request) logResponse(resp *http.Response, duration time.Duration) {
-	fields := map[string]interface{}{
-		"status_code": resp.StatusCode,
-		"status":      resp.Status,
-		"duration_ms": duration.Milliseconds(),
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve URL: %w", err)
 	}
-
-	// Log response headers
-	if len(resp.Header) > 0 {
-		headers := make(map[string]string)
-		for k, v := range resp.Header {
-			headers[k] = strings.Join(v, ", ")
-		}
-		fields["response_headers"] = headers
+	// JoinPath would escape a query string into the path
+	endpoint, query, hasQuery := strings.Cut(endpoint, "?")
+	u := base.JoinPath(endpoint)
+	if hasQuery {
+		u.RawQuery = query
 	}
-
-	// Log response body if available and not too large
-	if r.response != nil && len(r.response.Body) > 0 {
-		bodyStr := string(r.response.Body)
-		if len(bodyStr) > 1000 {
-			bodyStr = bodyStr[:1000] + "... [truncated]"
+	// JoinPath leaves the path relative when the base URL has none
+	if u.Host != "" && !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+		if u.RawPath != "" {
+			u.RawPath = "/" + u.RawPath
 		}
-		fields["response_body"] = bodyStr
-	}
-
-	// Log content length
-	if resp.ContentLength >= 0 {
-		fields["content_length"] = resp.ContentLength
 	}
+	return u, nil
+}
 
-	logLevel := LogLevelInfo
-	if resp.StatusCode >= 400 {
-		logLevel = LogLevelError
-	}
+// defaultRef holds the client used by package-level functions
+var defaultRef atomic.Pointer[Client]
 
-	r.client.logger.Log(logLevel, "HTTP Response", fields)
+func init() {
+	setDefaultClient(New())
 }
 
-func (h *client) resolveURL(endpoint string) (string, error) {
-	if h.baseURL == "" {
-		return endpoint, nil
-	}
-
-	resolvedURL, err := url.JoinPath(h.baseURL, endpoint)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve URL: %w", err)
-	}
-	return resolvedURL, nil
+// defaultClient returns the client used by package-level functions
+func defaultClient() Client {
+	return *defaultRef.Load()
 }
 
-var defaultClient = New()
+func setDefaultClient(c Client) Client {
+	defaultRef.Store(&c)
+	return c
+}
 
 // Get performs a GET request using the default client
 func Get(endpoint string) RequestBuilder {
-	return defaultClient.Get(endpoint)
+	return defaultClient().Get(endpoint)
 }
 
 // GetWithContext performs a GET request with context using the default client
 func GetWithContext(ctx context.Context, endpoint string) RequestBuilder {
-	return defaultClient.GetWithContext(ctx, endpoint)
+	return defaultClient().GetWithContext(ctx, endpoint)
 }
 
 // Post performs a POST request using the default client
 func Post(endpoint string) RequestBuilder {
-	return defaultClient.Post(endpoint)
+	return defaultClient().Post(endpoint)
 }
 
 // PostWithContext performs a POST request with context using the default client
 func PostWithContext(ctx context.Context, endpoint string) RequestBuilder {
-	return defaultClient.PostWithContext(ctx, endpoint)
+	return defaultClient().PostWithContext(ctx, endpoint)
 }
 
 // Put performs a PUT request using the default client
 func Put(endpoint string) RequestBuilder {
-	return defaultClient.Put(endpoint)
+	return defaultClient().Put(endpoint)
 }
 
 // PutWithContext performs a PUT request with context using the default client
 func PutWithContext(ctx context.Context, endpoint string) RequestBuilder {
-	return defaultClient.PutWithContext(ctx, endpoint)
+	return defaultClient().PutWithContext(ctx, endpoint)
 }
 
 // Patch performs a PATCH request using the default client
 func Patch(endpoint string) RequestBuilder {
-	return defaultClient.Patch(endpoint)
+	return defaultClient().Patch(endpoint)
 }
 
 // PatchWithContext performs a PATCH request with context using the default client
 func PatchWithContext(ctx context.Context, endpoint string) RequestBuilder {
-	return defaultClient.PatchWithContext(ctx, endpoint)
+	return defaultClient().PatchWithContext(ctx, endpoint)
 }
 
 // Delete performs a DELETE request using the default client
 func Delete(endpoint string) RequestBuilder {
-	return defaultClient.Delete(endpoint)
+	return defaultClient().Delete(endpoint)
 }
 
 // DeleteWithContext performs a DELETE request with context using the default client
 func DeleteWithContext(ctx context.Context, endpoint string) RequestBuilder {
-	return defaultClient.DeleteWithContext(ctx, endpoint)
+	return defaultClient().DeleteWithContext(ctx, endpoint)
+}
+
+// Head performs a HEAD request using the default client
+func Head(endpoint string) RequestBuilder {
+	return defaultClient().Head(endpoint)
+}
+
+// Options performs an OPTIONS request using the default client
+func Options(endpoint string) RequestBuilder {
+	return defaultClient().Options(endpoint)
+}
+
+// Request performs a request with any method using the default client
+func Request(method, endpoint string) RequestBuilder {
+	return defaultClient().Request(method, endpoint)
 }
 
 // SetBearerToken sets the bearer token for the default client
 func SetBearerToken(token string) Client {
-	defaultClient = defaultClient.SetBearerToken(token)
-	return defaultClient
+	return setDefaultClient(defaultClient().SetBearerToken(token))
 }
 
 // WithBasicAuth sets basic auth credentials for the default client
 func WithBasicAuth(username, password string) Client {
-	defaultClient = defaultClient.WithBasicAuth(username, password)
-	return defaultClient
+	return setDefaultClient(defaultClient().WithBasicAuth(username, password))
+}
+
+// WithOAuth2ClientCredentials enables the OAuth2 client credentials flow for the default client
+func WithOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) Client {
+	return setDefaultClient(defaultClient().WithOAuth2ClientCredentials(tokenURL, clientID, clientSecret, scopes...))
 }
 
 // Batch creates a new batch request using the default client
 func Batch() BatchRequest {
-	return defaultClient.Batch()
+	return defaultClient().Batch()
 }
 
 // Pool creates a new request pool using the default client
 func Pool(workers int) RequestPool {
-	return defaultClient.Pool(workers)
+	return defaultClient().Pool(workers)
+}
+
+// PoolWithContext creates a new request pool bound to ctx using the default client
+func PoolWithContext(ctx context.Context, workers int) RequestPool {
+	return defaultClient().PoolWithContext(ctx, workers)
+}
+
+// Poll returns a Poller for endpoint using the default client
+func Poll(endpoint string) *Poller {
+	return defaultClient().Poll(endpoint)
 }
 
 // SetDefaultClient allows users to configure the default client used by package-level functions
 func SetDefaultClient(config Config) {
-	defaultClient = New(config)
+	setDefaultClient(New(config))
 }
 
 // EnableDebug enables debug logging for the default client
 func EnableDebug() Client {
-	defaultClient = defaultClient.EnableDebug()
-	return defaultClient
+	return defaultClient().EnableDebug()
 }
 
 // DisableDebug disables debug logging for the default client
 func DisableDebug() Client {
-	defaultClient = defaultClient.DisableDebug()
-	return defaultClient
+	return defaultClient().DisableDebug()
 }
 
 // SetLogger sets a custom logger for the default client
 func SetLogger(logger Logger) Client {
-	defaultClient = defaultClient.SetLogger(logger)
-	return defaultClient
+	return defaultClient().SetLogger(logger)
+}
+
+// SetLoggingOptions sets what debug logging records for the default client
+func SetLoggingOptions(opts LoggingOptions) Client {
+	return defaultClient().SetLoggingOptions(opts)
 }
@@ -1,12 +1,36 @@
 package goclient
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -589,8 +613,9 @@ func TestClient_Handlers(t *testing.T) {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
-	// Note: Success handlers may not be implemented yet, so we'll just check the basic functionality
-	t.Logf("Success handler called: %v", successCalled)
+	if !successCalled {
+		t.Error("Expected success handler to be called")
+	}
 
 	// Test error handler
 	errorCalled := false
@@ -608,7 +633,9 @@ func TestClient_Handlers(t *testing.T) {
 		t.Fatal("Expected error for 404 response, got nil")
 	}
 
-	t.Logf("Error handler called: %v", errorCalled)
+	if !errorCalled {
+		t.Error("Expected error handler to be called")
+	}
 }
 
 // Test error response unmarshaling
@@ -638,8 +665,8 @@ func TestClient_ErrorUnmarshaling(t *testing.T) {
 	}
 }
 
-// Benchmark tests
-func BenchmarkClient_Get(b *testing.B) {
+// Test per-request transport and middleware overrides
+func TestClient_PerRequestTransport(t *testing.T) {
 	server := setupTestServer()
 	defer server.Close()
 
@@ -648,17 +675,303 @@ func BenchmarkClient_Get(b *testing.B) {
 		Timeout: 5 * time.Second,
 	})
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		var post TestPost
-		err := client.Get("/posts/1").Into(&post)
-		if err != nil {
-			b.Fatalf("Request failed: %v", err)
+	stubbed := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(http.StatusTeapot)
+		return rec.Result(), nil
+	})
+
+	_, err := client.Get("/posts/1").WithTransport(stubbed).Result()
+	reqErr, ok := err.(*RequestError)
+	if !ok || reqErr.StatusCode != http.StatusTeapot {
+		t.Fatalf("Expected stubbed 418 error, got %v", err)
+	}
+
+	var seen []string
+	tag := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				seen = append(seen, name)
+				return next.RoundTrip(req)
+			})
 		}
 	}
+
+	var post TestPost
+	err = client.Get("/posts/1").WithMiddleware(tag("outer"), tag("inner")).Into(&post)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Join(seen, ",") != "outer,inner" {
+		t.Errorf("Expected middleware order outer,inner, got %v", seen)
+	}
+
+	// The override must not leak into subsequent requests
+	seen = nil
+	if err := client.Get("/posts/1").Into(&post); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(seen) != 0 {
+		t.Errorf("Expected no middleware on plain request, got %v", seen)
+	}
 }
 
-func BenchmarkClient_Batch(b *testing.B) {
+// Test body digest header generation
+func TestClient_BodyDigest(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:    server.URL,
+		Timeout:    5 * time.Second,
+		BodyDigest: DigestContentMD5,
+	})
+
+	if _, err := client.Post("/upload").SetBody("hello").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := headers.Get("Content-MD5"); got != "XUFAKrxLKna5cZ2REBfFkg==" {
+		t.Errorf("Expected Content-MD5 of body, got %q", got)
+	}
+
+	_, err := client.Post("/upload").SetBody("hello").SetBodyDigest(ContentDigestSHA256).Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := headers.Get("Content-Digest"); got != "sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:" {
+		t.Errorf("Expected Content-Digest of body, got %q", got)
+	}
+	if headers.Get("Content-MD5") != "" {
+		t.Error("Expected per-request digest to replace the client default")
+	}
+
+	// The reader rewound to digest a stream is closed afterwards
+	var closed int32
+	_, err = client.Post("/upload").SetBodyStream(strings.NewReader("hello"), func() (io.Reader, error) {
+		return closeCounter{strings.NewReader("hello"), &closed}, nil
+	}).Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := headers.Get("Content-MD5"); got != "XUFAKrxLKna5cZ2REBfFkg==" || atomic.LoadInt32(&closed) != 1 {
+		t.Errorf("Expected the stream digested and its rewound reader closed, got %q and %d closes", got, closed)
+	}
+}
+
+// closeCounter counts the Close calls on a reader
+type closeCounter struct {
+	io.Reader
+	closed *int32
+}
+
+func (c closeCounter) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return nil
+}
+
+// Test response caching and invalidation
+func TestClient_CacheInvalidation(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			hits++
+		}
+		json.NewEncoder(w).Encode(map[string]int{"hits": hits})
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:  server.URL,
+		Timeout:  5 * time.Second,
+		CacheTTL: time.Minute,
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get("/users/1").Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("Expected 1 upstream hit, got %d", hits)
+	}
+
+	if n := client.Cache().Invalidate("/users/*"); n != 1 {
+		t.Errorf("Expected 1 entry invalidated, got %d", n)
+	}
+	client.Get("/users/1").Result()
+	if hits != 2 {
+		t.Fatalf("Expected refetch after invalidation, got %d hits", hits)
+	}
+
+	client.Get("/users").Result()
+	client.Get("/posts/1").Result()
+	if client.Cache().Len() != 3 {
+		t.Fatalf("Expected 3 cached entries, got %d", client.Cache().Len())
+	}
+
+	// A write to a resource drops it and its collection but nothing else
+	if _, err := client.Put("/users/1").SetBody(map[string]string{"name": "x"}).Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if client.Cache().Len() != 1 {
+		t.Errorf("Expected only /posts/1 to remain cached, got %d entries", client.Cache().Len())
+	}
+}
+
+// Test repeated headers
+func TestClient_AddHeader(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		GlobalHeaders: map[string]string{
+			"Forwarded": "for=192.0.2.60",
+		},
+	})
+
+	_, err := client.Get("/").
+		AddHeader("Forwarded", "for=198.51.100.17").
+		AddHeader("X-Tag", "a").
+		AddHeader("X-Tag", "b").
+		Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := headers.Values("Forwarded"); len(got) != 2 || got[0] != "for=192.0.2.60" {
+		t.Errorf("Expected global and request Forwarded values, got %v", got)
+	}
+	if got := headers.Values("X-Tag"); len(got) != 2 {
+		t.Errorf("Expected 2 X-Tag values, got %v", got)
+	}
+
+	_, err = client.Get("/").AddHeader("X-Tag", "a").SetHeader("X-Tag", "c").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := headers.Values("X-Tag"); len(got) != 1 || got[0] != "c" {
+		t.Errorf("Expected SetHeader to replace added values, got %v", got)
+	}
+}
+
+// Test global query parameters
+func TestClient_GlobalQueryParams(t *testing.T) {
+	var query map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		GlobalQueryParams: map[string]string{
+			"appid":   "secret",
+			"version": "1",
+		},
+	})
+
+	if _, err := client.Get("/weather").SetQueryParam("version", "2").SetQueryParam("q", "paris").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if query["appid"][0] != "secret" {
+		t.Errorf("Expected global appid, got %v", query["appid"])
+	}
+	if query["version"][0] != "2" {
+		t.Errorf("Expected request param to override global, got %v", query["version"])
+	}
+	if query["q"][0] != "paris" {
+		t.Errorf("Expected request param q, got %v", query["q"])
+	}
+}
+
+// Test request body size guardrail
+func TestClient_MaxRequestBytes(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:         server.URL,
+		Timeout:         5 * time.Second,
+		MaxRequestBytes: 16,
+	})
+
+	_, err := client.Post("/upload").SetBody(strings.NewReader(strings.Repeat("x", 1024))).Result()
+	var tooLarge *RequestTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Expected RequestTooLargeError, got %v", err)
+	}
+	if tooLarge.Limit != 16 {
+		t.Errorf("Expected limit 16, got %d", tooLarge.Limit)
+	}
+	if calls != 0 {
+		t.Errorf("Expected oversized request not to be sent, got %d calls", calls)
+	}
+
+	if _, err := client.Post("/upload").SetBody("small").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+// Test route templates are exposed to middleware
+func TestClient_RouteTemplate(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	var info RequestInfo
+	capture := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			info, _ = RequestInfoFromContext(req.Context())
+			return next.RoundTrip(req)
+		})
+	}
+
+	_, err := client.Get("/users/{id}/posts").
+		SetPathParam("id", "a b").
+		WithMiddleware(capture).
+		Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if path != "/users/a%20b/posts" {
+		t.Errorf("Expected escaped path, got %s", path)
+	}
+	if info.Route != "/users/{id}/posts" {
+		t.Errorf("Expected route template, got %q", info.Route)
+	}
+	if info.Method != http.MethodGet {
+		t.Errorf("Expected method GET, got %q", info.Method)
+	}
+}
+
+// Test request metadata is visible to middleware
+func TestClient_SetMeta(t *testing.T) {
 	server := setupTestServer()
 	defer server.Close()
 
@@ -667,12 +980,5093 @@ func BenchmarkClient_Batch(b *testing.B) {
 		Timeout: 5 * time.Second,
 	})
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		batch := client.Batch()
-		for j := 0; j < 5; j++ {
-			batch.Add(client.Get("/posts/1"))
+	var operation interface{}
+	var sent http.Header
+	capture := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			operation, _ = MetaFromContext(req.Context(), "operation")
+			sent = req.Header.Clone()
+			return next.RoundTrip(req)
+		})
+	}
+
+	_, err := client.Get("/posts/1").SetMeta("operation", "GetPost").WithMiddleware(capture).Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if operation != "GetPost" {
+		t.Errorf("Expected operation metadata, got %v", operation)
+	}
+	for k := range sent {
+		if strings.Contains(strings.ToLower(k), "operation") {
+			t.Errorf("Expected metadata not to be sent, found header %s", k)
+		}
+	}
+}
+
+// Test credentials never leak through fmt
+func TestClient_RedactedString(t *testing.T) {
+	client := New(Config{
+		BaseURL:           "https://api.example.com",
+		GlobalQueryParams: map[string]string{"api_key": "query-secret"},
+	}).SetBearerToken("token-secret")
+
+	rb := client.Get("/users").SetHeader("Authorization", "Bearer header-secret").SetQueryParam("page", "2")
+
+	outputs := []string{
+		fmt.Sprintf("%v", client),
+		fmt.Sprintf("%+v", client),
+		fmt.Sprintf("%#v", client),
+		client.DebugString(),
+		fmt.Sprintf("%+v", rb),
+		rb.DebugString(),
+	}
+	for _, out := range outputs {
+		for _, secret := range []string{"token-secret", "header-secret", "query-secret"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("Expected %q to be redacted in %q", secret, out)
+			}
+		}
+	}
+
+	if !strings.Contains(rb.DebugString(), "page=2") {
+		t.Errorf("Expected non-sensitive query params in debug string, got %q", rb.DebugString())
+	}
+}
+
+// Test global headers can be rotated while requests are in flight
+func TestClient_SetGlobalHeader(t *testing.T) {
+	var mu sync.Mutex
+	var versions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		versions = append(versions, r.Header.Get("X-Build"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:       server.URL,
+		Timeout:       5 * time.Second,
+		GlobalHeaders: map[string]string{"X-Build": "v1"},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.Get("/").Result()
+		}()
+		go func(i int) {
+			defer wg.Done()
+			client.SetGlobalHeader("X-Build", fmt.Sprintf("v%d", i))
+		}(i)
+	}
+	wg.Wait()
+
+	client.RemoveGlobalHeader("X-Build")
+	if _, err := client.Get("/").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if last := versions[len(versions)-1]; last != "" {
+		t.Errorf("Expected removed header to be absent, got %q", last)
+	}
+}
+
+// Test opting out of status errors
+func TestClient_DisableStatusError(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:            server.URL,
+		Timeout:            5 * time.Second,
+		DisableStatusError: true,
+	})
+
+	resp, err := client.Get("/posts/404").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(resp.Body), "Post not found") {
+		t.Errorf("Expected error body to be returned, got %s", resp.Body)
+	}
+}
+
+// Test capability probing
+func TestClient_Probe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodOptions && r.URL.Path == "/users":
+			w.Header().Set("Allow", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodOptions:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.Method == http.MethodHead:
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Server", "test")
+		}
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	result, err := client.Probe("/users")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Method != http.MethodOptions || !result.Allows("post") || result.Allows("DELETE") {
+		t.Errorf("Unexpected allowed methods: %+v", result)
+	}
+	if result.CORS.AllowOrigin != "*" || len(result.CORS.AllowMethods) != 2 || result.CORS.MaxAge != 600 {
+		t.Errorf("Unexpected CORS info: %+v", result.CORS)
+	}
+
+	result, err = client.Probe("/files")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Method != http.MethodHead || result.AcceptRanges != "bytes" || result.Server != "test" {
+		t.Errorf("Expected HEAD fallback result, got %+v", result)
+	}
+}
+
+// Test body replay for resending middleware
+func TestClient_BodyReplay(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(data))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	var rewindErr error
+	sendTwice := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body.Close()
+			if rewindErr = RewindBody(req); rewindErr != nil {
+				return resp, nil
+			}
+			return next.RoundTrip(req)
+		})
+	}
+
+	tests := []struct {
+		name    string
+		builder func() RequestBuilder
+		want    int
+		wantErr error
+	}{
+		{"buffered", func() RequestBuilder {
+			return client.Post("/").SetBody(strings.NewReader("payload"))
+		}, 2, nil},
+		{"stream with rewind", func() RequestBuilder {
+			return client.Post("/").SetBodyStream(strings.NewReader("payload"), func() (io.Reader, error) {
+				return strings.NewReader("payload"), nil
+			})
+		}, 2, nil},
+		{"one-shot stream", func() RequestBuilder {
+			return client.Post("/").SetBodyStream(strings.NewReader("payload"), nil)
+		}, 1, ErrBodyNotReplayable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies = nil
+			if _, err := tt.builder().WithMiddleware(sendTwice).Result(); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(bodies) != tt.want {
+				t.Fatalf("Expected %d sends, got %d", tt.want, len(bodies))
+			}
+			for _, b := range bodies {
+				if b != "payload" {
+					t.Errorf("Expected full body on every send, got %q", b)
+				}
+			}
+			if !errors.Is(rewindErr, tt.wantErr) {
+				t.Errorf("Expected rewind error %v, got %v", tt.wantErr, rewindErr)
+			}
+		})
+	}
+}
+
+// Test decode hooks unwrap response envelopes
+func TestClient_OnDecode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"id": 7, "title": "wrapped"}, "meta": {"page": 1}}`))
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	}).OnDecode(UnwrapField("data"))
+
+	var post TestPost
+	if err := client.Get("/posts/7").Into(&post); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if post.ID != 7 || post.Title != "wrapped" {
+		t.Errorf("Expected unwrapped post, got %+v", post)
+	}
+
+	client.OnDecode(func(body []byte) ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	if err := client.Get("/posts/7").Into(&post); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected decode hook error, got %v", err)
+	}
+}
+
+type testDomainError struct {
+	Code   string
+	Status int
+}
+
+func (e *testDomainError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Code, e.Status)
+}
+
+// Test client-wide error decoding into domain errors
+func TestClient_ErrorDecoder(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		ErrorDecoder: func(status int, body []byte) error {
+			var payload TestError
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return nil
+			}
+			return &testDomainError{Code: payload.Error, Status: status}
+		},
+	})
+
+	_, err := client.Get("/posts/404").Result()
+	var domainErr *testDomainError
+	if !errors.As(err, &domainErr) {
+		t.Fatalf("Expected domain error, got %v", err)
+	}
+	if domainErr.Code != "Not Found" || domainErr.Status != http.StatusNotFound {
+		t.Errorf("Unexpected domain error: %+v", domainErr)
+	}
+
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected RequestError to still be available, got %v", err)
+	}
+}
+
+// Test RFC 7807 problem details decoding
+func TestClient_ProblemDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"type": "https://example.com/probs/out-of-credit", "title": "You do not have enough credit.", "status": 403, "detail": "Your current balance is 30, but that costs 50.", "balance": 30}`))
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	_, err := client.Post("/purchase").Result()
+	var problem *ProblemDetails
+	if !errors.As(err, &problem) {
+		t.Fatalf("Expected ProblemDetails, got %v", err)
+	}
+	if problem.Type != "https://example.com/probs/out-of-credit" || problem.Status != 403 {
+		t.Errorf("Unexpected problem: %+v", problem)
+	}
+	if problem.Extensions["balance"] != float64(30) {
+		t.Errorf("Expected balance extension, got %v", problem.Extensions)
+	}
+	if _, ok := problem.Extensions["title"]; ok {
+		t.Error("Expected standard members to be excluded from extensions")
+	}
+}
+
+// Test conditional default headers
+func TestClient_SetHeaderIfAbsent(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:       server.URL,
+		Timeout:       5 * time.Second,
+		GlobalHeaders: map[string]string{"User-Agent": "app/1.0"},
+	})
+	client.SetHeaderIfAbsent("User-Agent", "lib/0.1").SetHeaderIfAbsent("Accept", "application/vnd.lib+json")
+
+	if _, err := client.Get("/").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := headers.Get("User-Agent"); got != "app/1.0" {
+		t.Errorf("Expected global header to win over default, got %q", got)
+	}
+	if got := headers.Get("Accept"); got != "application/vnd.lib+json" {
+		t.Errorf("Expected client default Accept, got %q", got)
+	}
+
+	_, err := client.Get("/").
+		SetHeader("Accept", "text/plain").
+		SetHeaderIfAbsent("Accept", "application/xml").
+		SetHeaderIfAbsent("X-Trace", "on").
+		Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := headers.Get("Accept"); got != "text/plain" {
+		t.Errorf("Expected explicit header to win, got %q", got)
+	}
+	if got := headers.Get("X-Trace"); got != "on" {
+		t.Errorf("Expected request default to apply, got %q", got)
+	}
+}
+
+// Test response header capture
+func TestClient_IntoWithHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total-Count", "42")
+		w.Header().Set("X-Request-ID", "abc")
+		w.Header().Set("Retry-After", "30")
+		w.Header().Add("Link", "<a>")
+		w.Header().Add("Link", "<b>")
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		json.NewEncoder(w).Encode([]TestPost{{ID: 1}})
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	var posts []TestPost
+	var meta struct {
+		Total        int           `header:"X-Total-Count"`
+		RequestID    string        `header:"X-Request-ID"`
+		RetryAfter   time.Duration `header:"Retry-After"`
+		Links        []string      `header:"Link"`
+		LastModified time.Time     `header:"Last-Modified"`
+		Missing      string        `header:"X-Missing"`
+	}
+
+	if err := client.Get("/posts").IntoWithHeaders(&posts, &meta); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("Expected body to be decoded, got %v", posts)
+	}
+	if meta.Total != 42 || meta.RequestID != "abc" || meta.RetryAfter != 30*time.Second {
+		t.Errorf("Unexpected header values: %+v", meta)
+	}
+	if len(meta.Links) != 2 || meta.LastModified.Year() != 2015 {
+		t.Errorf("Unexpected header values: %+v", meta)
+	}
+}
+
+// Test cache expiry follows the injected clock
+func TestClient_ManualClock(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	client := New(Config{
+		BaseURL:  server.URL,
+		Timeout:  5 * time.Second,
+		CacheTTL: time.Hour,
+		Clock:    clock,
+	})
+
+	client.Get("/").Result()
+	clock.Advance(59 * time.Minute)
+	client.Get("/").Result()
+	if hits != 1 {
+		t.Fatalf("Expected cached response before TTL, got %d hits", hits)
+	}
+
+	if err := clock.Sleep(context.Background(), 2*time.Minute); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	client.Get("/").Result()
+	if hits != 2 {
+		t.Errorf("Expected refetch after TTL, got %d hits", hits)
+	}
+}
+
+// Test deterministic request IDs
+func TestClient_IDGenerator(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:     server.URL,
+		Timeout:     5 * time.Second,
+		IDGenerator: NewSequentialIDGenerator("req"),
+	})
+
+	var ids []string
+	capture := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			info, _ := RequestInfoFromContext(req.Context())
+			ids = append(ids, info.ID)
+			return next.RoundTrip(req)
+		})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get("/posts/1").WithMiddleware(capture).Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if strings.Join(ids, ",") != "req-1,req-2" {
+		t.Errorf("Expected sequential IDs, got %v", ids)
+	}
+}
+
+// Test decompression limits
+func TestClient_DecompressionLimits(t *testing.T) {
+	gzipped := func(data []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		return buf.Bytes()
+	}
+	post := gzipped([]byte(`{"id": 1, "title": "compressed"}`))
+	bomb := gzipped(make([]byte, 8<<20))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.URL.Path == "/bomb" {
+			w.Write(bomb)
+			return
+		}
+		w.Write(post)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	var result TestPost
+	if err := client.Get("/post").Into(&result); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Title != "compressed" {
+		t.Errorf("Expected decompressed body, got %+v", result)
+	}
+
+	_, err := client.Get("/bomb").Result()
+	var bombErr *DecompressionBombError
+	if !errors.As(err, &bombErr) {
+		t.Fatalf("Expected DecompressionBombError, got %v", err)
+	}
+
+	limited := New(Config{
+		BaseURL:               server.URL,
+		Timeout:               5 * time.Second,
+		MaxResponseBytes:      1024,
+		MaxDecompressionRatio: -1,
+	})
+	_, err = limited.Get("/bomb").Result()
+	var tooLarge *ResponseTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 1024 {
+		t.Fatalf("Expected ResponseTooLargeError, got %v", err)
+	}
+}
+
+// Test multipart response parsing
+func TestResponse_Parts(t *testing.T) {
+	body := "--XYZ\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Range: bytes 0-4/20\r\n\r\n" +
+		"hello\r\n" +
+		"--XYZ\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Range: bytes 15-19/*\r\n\r\n" +
+		"world\r\n" +
+		"--XYZ--\r\n"
+
+	resp := &Response{
+		StatusCode: http.StatusPartialContent,
+		Headers:    http.Header{"Content-Type": {"multipart/byteranges; boundary=XYZ"}},
+		Body:       []byte(body),
+	}
+
+	parts, err := resp.Parts()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var got []string
+	for {
+		part, err := parts.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		data, _ := io.ReadAll(part.Body)
+		start, end, total, err := part.ContentRange()
+		if err != nil {
+			t.Fatalf("Expected valid Content-Range, got %v", err)
+		}
+		got = append(got, fmt.Sprintf("%s:%d-%d/%d", data, start, end, total))
+	}
+
+	if strings.Join(got, ",") != "hello:0-4/20,world:15-19/-1" {
+		t.Errorf("Unexpected parts: %v", got)
+	}
+
+	if _, err := (&Response{Headers: http.Header{"Content-Type": {"application/json"}}}).Parts(); !errors.Is(err, ErrNotMultipart) {
+		t.Errorf("Expected ErrNotMultipart, got %v", err)
+	}
+}
+
+// Test transport-level batching against a Graph-style $batch endpoint
+func TestBatcher_Graph(t *testing.T) {
+	var batchCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.0/$batch" {
+			t.Errorf("Expected batch endpoint, got %s", r.URL.Path)
+		}
+		atomic.AddInt32(&batchCalls, 1)
+
+		var payload struct {
+			Requests []struct {
+				ID     string `json:"id"`
+				Method string `json:"method"`
+				URL    string `json:"url"`
+			} `json:"requests"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		var responses []map[string]interface{}
+		for _, req := range payload.Requests {
+			responses = append(responses, map[string]interface{}{
+				"id":      req.ID,
+				"status":  200,
+				"headers": map[string]string{"Content-Type": "application/json"},
+				"body":    map[string]string{"url": req.URL, "auth": r.Header.Get("Authorization")},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"responses": responses})
+	}))
+	defer server.Close()
+
+	batcher, err := NewBatcher(BatcherConfig{
+		Endpoint: server.URL + "/v1.0/$batch",
+		Window:   50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	client := New(Config{
+		BaseURL:     server.URL + "/v1.0",
+		Timeout:     5 * time.Second,
+		Interceptor: batcher,
+	}).SetBearerToken("token")
+
+	paths := []string{"/me", "/users/1", "/groups?$top=1"}
+	results := make([]map[string]string, len(paths))
+	var wg sync.WaitGroup
+	for i, p := range paths {
+		wg.Add(1)
+		go func(i int, p string) {
+			defer wg.Done()
+			if err := client.Get(p).Into(&results[i]); err != nil {
+				t.Errorf("Expected no error for %s, got %v", p, err)
+			}
+		}(i, p)
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&batchCalls); n != 1 {
+		t.Errorf("Expected a single batch call, got %d", n)
+	}
+	for i, p := range paths {
+		if results[i]["url"] != p {
+			t.Errorf("Expected response for %s, got %v", p, results[i])
+		}
+		if results[i]["auth"] != "Bearer token" {
+			t.Errorf("Expected Authorization to be forwarded, got %q", results[i]["auth"])
+		}
+	}
+
+	// Requests with different credentials go out in separate batches
+	atomic.StoreInt32(&batchCalls, 0)
+	tokens := []string{"alice", "bob"}
+	auths := make([]map[string]string, len(tokens))
+	for i, token := range tokens {
+		wg.Add(1)
+		go func(i int, token string) {
+			defer wg.Done()
+			if err := client.Get("/me").SetAuth(BearerAuth(StaticToken(token))).Into(&auths[i]); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}(i, token)
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&batchCalls); n != 2 {
+		t.Errorf("Expected a batch call per credential, got %d", n)
+	}
+	for i, token := range tokens {
+		if auths[i]["auth"] != "Bearer "+token {
+			t.Errorf("Expected %s's credentials, got %q", token, auths[i]["auth"])
+		}
+	}
+}
+
+// Test crawling paginated endpoints
+func TestCrawler_Run(t *testing.T) {
+	var requests int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "3600")
+		}
+		if page < 3 {
+			// Relative and absolute links to the same page must be deduplicated
+			w.Header().Add("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, page+1))
+			w.Header().Add("Link", fmt.Sprintf(`<%s/items?page=%d>; rel="next last"`, server.URL, page+1))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []int{page * 10, page*10 + 1},
+		})
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	clock := NewManualClock(start)
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		Clock:   clock,
+	})
+
+	crawler := NewCrawler(client, CrawlerConfig{ItemsField: "data", Workers: 2})
+	var items []int
+	err := crawler.Run(context.Background(), server.URL+"/items?page=0", func(item json.RawMessage) error {
+		var n int
+		json.Unmarshal(item, &n)
+		items = append(items, n)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	sort.Ints(items)
+	if fmt.Sprint(items) != "[0 1 10 11 20 21 30 31]" {
+		t.Errorf("Unexpected items: %v", items)
+	}
+	if n := atomic.LoadInt32(&requests); n != 4 {
+		t.Errorf("Expected 4 page requests, got %d", n)
+	}
+	if waited := clock.Now().Sub(start); waited < time.Hour {
+		t.Errorf("Expected the exhausted host to pause on the client clock, waited %v", waited)
+	}
+
+	stop := errors.New("stop")
+	err = crawler.Run(context.Background(), server.URL+"/items?page=0", func(item json.RawMessage) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected callback error to stop the crawl, got %v", err)
+	}
+}
+
+// Test snapshot diffing
+func TestDiffJSON(t *testing.T) {
+	decode := func(s string) interface{} {
+		doc, err := normalizeSnapshot([]byte(s), []string{"meta.generatedAt"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return doc
+	}
+
+	old := decode(`{"flags": [{"name": "a", "on": true}], "limit": 1, "meta": {"generatedAt": 1}}`)
+	new := decode(`{"flags": [{"name": "a", "on": false}, {"name": "b"}], "region": "eu", "meta": {"generatedAt": 2}}`)
+
+	var got []string
+	for _, c := range DiffJSON(old, new) {
+		got = append(got, c.Kind.String()+" "+c.Path)
+	}
+	want := "modified flags.0.on,added flags.1,removed limit,added region"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, ","))
+	}
+
+	if changes := DiffJSON(decode(`{"meta": {"generatedAt": 1}}`), decode(`{"meta": {"generatedAt": 2}}`)); len(changes) != 0 {
+		t.Errorf("Expected ignored fields not to produce changes, got %v", changes)
+	}
+}
+
+// Test watching an endpoint for changes
+func TestWatch(t *testing.T) {
+	var version int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := atomic.AddInt32(&version, 1)
+		fmt.Fprintf(w, `{"version": %d, "ts": %d}`, v/2, v)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	clock := NewManualClock(start)
+	changes := make(chan []Change, 10)
+	go Watch(ctx, func() RequestBuilder { return client.Get("/config") },
+		WatchConfig{IgnoreFields: []string{"ts"}, Clock: clock},
+		func(c []Change) {
+			select {
+			case changes <- c:
+			default:
+			}
+		})
+
+	select {
+	case c := <-changes:
+		if len(c) != 1 || c[0].Path != "version" || c[0].Kind != ChangeModified {
+			t.Errorf("Unexpected changes: %v", c)
+		}
+		if waited := clock.Now().Sub(start); waited < 30*time.Second {
+			t.Errorf("Expected polls to wait out the default interval on the clock, waited %v", waited)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change notification")
+	}
+}
+
+// Test CSV response decoding
+func TestClient_IntoCSV(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("\ufeffDate,Campaign,Clicks,Cost\n" +
+			"2024-01-01T00:00:00Z,spring,10,1.5\n" +
+			"2024-01-02T00:00:00Z,\"summer, sale\",,2.25\n"))
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	type row struct {
+		Date     time.Time `csv:"date"`
+		Campaign string
+		Clicks   int     `csv:"clicks"`
+		Cost     float64 `csv:"cost"`
+		Ignored  string  `csv:"-"`
+	}
+
+	var rows []row
+	if err := client.Get("/report.csv").IntoCSV(&rows); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].Clicks != 10 || rows[0].Date.Day() != 1 || rows[1].Campaign != "summer, sale" || rows[1].Cost != 2.25 {
+		t.Errorf("Unexpected rows: %+v", rows)
+	}
+
+	resp, err := client.Get("/report.csv").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	iter, err := resp.CSVRows()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var campaigns []string
+	for {
+		r, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		campaigns = append(campaigns, r.Get("campaign"))
+	}
+	if strings.Join(campaigns, "|") != "spring|summer, sale" {
+		t.Errorf("Unexpected campaigns: %v", campaigns)
+	}
+}
+
+// Test adaptive pacing reacts to latency
+func TestPacer(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	p := newPacer(PacingConfig{
+		TargetLatency: 100 * time.Millisecond,
+		MaxInterval:   time.Second,
+	}, clock)
+
+	if wait := p.reserve(); wait != 0 {
+		t.Fatalf("Expected no wait initially, got %v", wait)
+	}
+
+	for i := 0; i < 10; i++ {
+		p.observe(500 * time.Millisecond)
+	}
+	if got := p.currentInterval(); got != time.Second {
+		t.Errorf("Expected interval to back off to the maximum, got %v", got)
+	}
+
+	p.reserve()
+	if wait := p.reserve(); wait != time.Second {
+		t.Errorf("Expected next slot one interval away, got %v", wait)
+	}
+
+	for i := 0; i < 30; i++ {
+		p.observe(10 * time.Millisecond)
+	}
+	if got := p.currentInterval(); got != 0 {
+		t.Errorf("Expected interval to recover, got %v", got)
+	}
+}
+
+// Test built-in retries with exponential backoff
+func TestClient_Retry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"id":1,"title":"","body":"","userId":0}` {
+			t.Errorf("Expected body to be replayed, got %q", body)
+		}
+		if r.URL.Path == "/flaky" && n >= 3 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	clock := NewManualClock(time.Now())
+	client := New(Config{
+		BaseURL:      server.URL,
+		Timeout:      5 * time.Second,
+		MaxRetries:   3,
+		RetryWaitMin: 100 * time.Millisecond,
+		RetryWaitMax: time.Second,
+		Clock:        clock,
+	})
+
+	// A POST is only retried when the server can deduplicate it
+	if _, err := client.Post("/flaky").SetBody(TestPost{ID: 1}).Result(); err == nil {
+		t.Fatal("Expected a POST without an Idempotency-Key to fail")
+	}
+	if n := atomic.SwapInt32(&calls, 0); n != 1 {
+		t.Errorf("Expected a POST without an Idempotency-Key to be sent once, got %d", n)
+	}
+
+	start := clock.Now()
+	if _, err := client.Post("/flaky").SetHeader("Idempotency-Key", "k1").SetBody(TestPost{ID: 1}).Result(); err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+	if waited := clock.Now().Sub(start); waited < 150*time.Millisecond || waited > 300*time.Millisecond {
+		t.Errorf("Expected exponential backoff between 150ms and 300ms, got %v", waited)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	_, err := client.Post("/down").SetHeader("Idempotency-Key", "k2").SetBody(TestPost{ID: 1}).Result()
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("Expected RequestError, got %v", err)
+	}
+	if reqErr.Attempts != 4 || atomic.LoadInt32(&calls) != 4 {
+		t.Errorf("Expected 4 attempts, got %d (%d calls)", reqErr.Attempts, calls)
+	}
+
+	// Retrying every method is opt-in
+	cfg := Config{BaseURL: server.URL, Timeout: 5 * time.Second, MaxRetries: 1, Clock: clock}
+	WithRetryAllMethods()(&cfg)
+	atomic.StoreInt32(&calls, 0)
+	New(cfg).Patch("/down").SetBody(TestPost{ID: 1}).Result()
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected the PATCH to be retried, got %d calls", n)
+	}
+}
+
+// Test Retry-After overrides the computed backoff
+func TestClient_RetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", r.URL.Query().Get("after"))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	client := New(Config{
+		BaseURL:       server.URL,
+		Timeout:       5 * time.Second,
+		MaxRetries:    1,
+		RetryWaitMin:  time.Millisecond,
+		MaxRetryAfter: time.Minute,
+		Clock:         clock,
+	})
+
+	tests := []struct {
+		after string
+		want  time.Duration
+	}{
+		{clock.Now().Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{"2", 2 * time.Second},
+		{"3600", time.Minute},
+	}
+
+	for _, tt := range tests {
+		atomic.StoreInt32(&calls, 0)
+		start := clock.Now()
+		if _, err := client.Get("/").SetQueryParam("after", tt.after).Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if waited := clock.Now().Sub(start); waited != tt.want {
+			t.Errorf("Retry-After %q: expected wait %v, got %v", tt.after, tt.want, waited)
+		}
+	}
+}
+
+// Test aggregated failures from batches and pools
+func TestMultiError(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	responses, err := client.Batch().
+		Add(client.Get("/posts/1")).
+		Add(client.Get("/posts/404")).
+		Add(client.Get("/auth/bearer")).
+		ExecuteAll(context.Background())
+
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+		t.Fatalf("Expected MultiError with 2 failures, got %v", err)
+	}
+	if responses[0] == nil || responses[0].StatusCode != http.StatusOK || responses[1] != nil {
+		t.Errorf("Expected positional responses, got %v", responses)
+	}
+
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected errors.As to find the first RequestError, got %v", reqErr)
+	}
+
+	pool := client.Pool(2)
+	defer pool.Wait()
+	pool.Submit(client.Get("/posts/1"))
+	pool.Submit(client.Get("/posts/404"))
+	if err := pool.Drain(); !errors.As(err, &multi) || len(multi.Errors) != 1 {
+		t.Errorf("Expected one pool failure, got %v", err)
+	}
+	if err := pool.Drain(); err != nil {
+		t.Errorf("Expected failures to be reported once, got %v", err)
+	}
+}
+
+// Test per-request retry overrides
+func TestRequestRetryOverrides(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:      server.URL,
+		Timeout:      5 * time.Second,
+		MaxRetries:   3,
+		RetryWaitMin: time.Millisecond,
+	})
+
+	// 409 is not retryable by default
+	client.Get("/").Result()
+	if n := atomic.SwapInt32(&calls, 0); n != 1 {
+		t.Errorf("Expected 1 call, got %d", n)
+	}
+
+	_, err := client.Get("/").
+		SetRetry(2, time.Millisecond).
+		SetRetryCondition(func(resp *Response, err error) bool {
+			return resp != nil && resp.StatusCode == http.StatusConflict
+		}).
+		Result()
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %v", err)
+	}
+	if n := atomic.SwapInt32(&calls, 0); n != 3 {
+		t.Errorf("Expected 3 calls, got %d", n)
+	}
+
+	client.Get("/").SetRetry(0, 0).SetRetryCondition(func(*Response, error) bool { return true }).Result()
+	if n := atomic.SwapInt32(&calls, 0); n != 1 {
+		t.Errorf("Expected retries to be disabled, got %d calls", n)
+	}
+}
+
+// Test round-robin proxy rotation
+func TestProxyRotation(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	proxy := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			seen = append(seen, name)
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	a, b := proxy("a"), proxy("b")
+	defer a.Close()
+	defer b.Close()
+
+	// The third proxy refuses connections and is taken out of rotation
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	provider, err := NewRoundRobinProxyProvider(time.Minute, a.URL, dead.URL, b.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	client := New(Config{
+		BaseURL:       "http://upstream.invalid",
+		Timeout:       5 * time.Second,
+		ProxyProvider: provider,
+	})
+
+	for i := 0; i < 5; i++ {
+		client.Get("/").Result()
+	}
+
+	if got := strings.Join(seen, ","); got != "a,b,a,b" {
+		t.Errorf("Expected proxies a,b,a,b, got %s", got)
+	}
+}
+
+// Test warming connections before the first request
+func TestClient_Preconnect(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:     server.URL,
+		Timeout:     5 * time.Second,
+		Interceptor: server.Client().Transport,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := client.Preconnect(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var reused bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+	}
+	if _, err := client.GetWithContext(httptrace.WithClientTrace(ctx, trace), "/").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reused {
+		t.Error("Expected the first request to reuse the warm connection")
+	}
+
+	if err := client.Preconnect(ctx, "127.0.0.1:1"); err == nil {
+		t.Error("Expected an error for an unreachable host")
+	}
+
+	// Connections are only refreshed in the background until stopped
+	var heads int32
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt32(&heads, 1)
+		}
+	}))
+	defer counting.Close()
+	clock := gatedClock{NewManualClock(time.Now()), make(chan struct{})}
+	client = New(Config{BaseURL: counting.URL, Timeout: 5 * time.Second, Clock: clock})
+	if err := client.Preconnect(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	stop := client.KeepWarm(ctx)
+	clock.release <- struct{}{}
+	clock.release <- struct{}{}
+	stop()
+	time.Sleep(20 * time.Millisecond)
+	n := atomic.LoadInt32(&heads)
+	if n < 3 || n > 4 {
+		t.Errorf("Expected a warm-up and refreshes until stopped, got %d HEAD requests", n)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case clock.release <- struct{}{}:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if atomic.LoadInt32(&heads) != n {
+		t.Error("Expected no refreshes after stop")
+	}
+}
+
+// fixedRand is a RandSource that always returns the same value
+type fixedRand float64
+
+func (r fixedRand) Float64() float64 {
+	return float64(r)
+}
+
+// Test client middleware chains and their ordering
+func TestClient_Use(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	var seen []string
+	tag := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				seen = append(seen, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	client := New(Config{
+		BaseURL:     server.URL,
+		Timeout:     5 * time.Second,
+		Middlewares: []Middleware{tag("config")},
+	}).Use(tag("first"), tag("second"))
+
+	if _, err := client.Get("/posts/1").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := strings.Join(seen, ","); got != "config,first,second" {
+		t.Errorf("Expected order config,first,second, got %s", got)
+	}
+
+	seen = nil
+	if _, err := client.Get("/posts/1").UseMiddleware(tag("request")).Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := strings.Join(seen, ","); got != "config,first,second,request" {
+		t.Errorf("Expected request middleware last, got %s", got)
+	}
+}
+
+// Test request and response lifecycle hooks
+func TestClient_LifecycleHooks(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	var statuses []int
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	}).OnBeforeRequest(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer valid-token")
+		return nil
+	}).OnBeforeRequest(func(req *http.Request) error {
+		if req.Method == http.MethodDelete {
+			return errors.New("deletes are not allowed")
+		}
+		return nil
+	}).OnAfterResponse(func(resp *Response) error {
+		statuses = append(statuses, resp.StatusCode)
+		return nil
+	})
+
+	if _, err := client.Get("/auth/bearer").Result(); err != nil {
+		t.Fatalf("Expected hook to add auth header, got %v", err)
+	}
+	client.Get("/posts/404").Result()
+	if len(statuses) != 2 || statuses[0] != http.StatusOK || statuses[1] != http.StatusNotFound {
+		t.Errorf("Expected statuses [200 404], got %v", statuses)
+	}
+
+	if _, err := client.Delete("/posts/1").Result(); err == nil || !strings.Contains(err.Error(), "deletes are not allowed") {
+		t.Errorf("Expected hook to abort the request, got %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Errorf("Expected aborted request to skip response hooks, got %v", statuses)
+	}
+
+	client.OnAfterResponse(func(resp *Response) error {
+		return errors.New("rejected")
+	})
+	if _, err := client.Get("/posts/1").Result(); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("Expected response hook error, got %v", err)
+	}
+}
+
+// Test copying the response body to a secondary writer
+func TestRequest_TeeBody(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	var archive bytes.Buffer
+	var post TestPost
+	if err := client.Get("/posts/1").TeeBody(&archive).Into(&post); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var archived TestPost
+	if err := json.Unmarshal(archive.Bytes(), &archived); err != nil {
+		t.Fatalf("Expected archived body to be JSON, got %v", err)
+	}
+	if post.ID != 1 || archived != post {
+		t.Errorf("Expected archived %+v to match decoded %+v", archived, post)
+	}
+}
+
+// Test the per-host circuit breaker
+func TestClient_CircuitBreaker(t *testing.T) {
+	var healthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var transitions []string
+	clock := NewManualClock(time.Now())
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		Clock:   clock,
+		CircuitBreaker: &CircuitBreakerConfig{
+			FailureThreshold: 2,
+			OpenDuration:     time.Minute,
+			OnStateChange: func(host string, from, to CircuitState) {
+				transitions = append(transitions, from.String()+"->"+to.String())
+			},
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		client.Get("/").Result()
+	}
+	if _, err := client.Get("/").Result(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	clock.Advance(time.Minute)
+	if _, err := client.Get("/").Result(); err != nil {
+		t.Fatalf("Expected half-open probe to succeed, got %v", err)
+	}
+
+	want := "closed->open,open->half-open,half-open->closed"
+	if got := strings.Join(transitions, ","); got != want {
+		t.Errorf("Expected transitions %s, got %s", want, got)
+	}
+
+	// Requests canceled by their caller do not count as failures
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 2; i++ {
+		client.GetWithContext(ctx, "/").Result()
+	}
+	if _, err := client.Get("/").Result(); err != nil {
+		t.Errorf("Expected canceled requests to leave the circuit closed, got %v", err)
+	}
+}
+
+// Test typed declarative endpoints
+func TestEndpoint(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+	ctx := context.Background()
+
+	getPost := NewEndpoint[Empty, TestPost](client, http.MethodGet, "/posts/{id}")
+	post, err := getPost.Call(ctx, map[string]string{"id": "1"}, Empty{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if post.ID != 1 {
+		t.Errorf("Expected post ID 1, got %d", post.ID)
+	}
+
+	createPost := NewEndpoint[TestPost, TestPost](client, http.MethodPost, "/posts").Expect(http.StatusCreated)
+	created, err := createPost.Call(ctx, nil, TestPost{Title: "Typed"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if created.ID != 101 || created.Title != "Typed" {
+		t.Errorf("Expected created post, got %+v", created)
+	}
+
+	if _, err := createPost.Expect(http.StatusOK).Call(ctx, nil, TestPost{}); err == nil {
+		t.Error("Expected an error for an unexpected status")
+	}
+
+	// Cached responses keep their status
+	cached := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second, CacheTTL: time.Minute})
+	getCached := NewEndpoint[Empty, TestPost](cached, http.MethodGet, "/posts/{id}").Expect(http.StatusOK)
+	for i := 0; i < 2; i++ {
+		if _, err := getCached.Call(ctx, map[string]string{"id": "1"}, Empty{}); err != nil {
+			t.Fatalf("Call %d: expected no error, got %v", i+1, err)
+		}
+	}
+}
+
+// Test the client-side token bucket rate limit
+func TestClient_RateLimit(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	clock := NewManualClock(time.Now())
+	client := New(Config{
+		BaseURL:   server.URL,
+		Timeout:   5 * time.Second,
+		Clock:     clock,
+		RateLimit: &RateLimit{RequestsPerSecond: 10, Burst: 2},
+	})
+
+	start := clock.Now()
+	responses, errs := client.Batch().
+		Add(client.Get("/posts/1")).
+		Add(client.Get("/posts/1")).
+		Add(client.Get("/posts/1")).
+		Add(client.Get("/posts/1")).
+		Execute(context.Background())
+	for i, err := range errs {
+		if err != nil || responses[i].StatusCode != http.StatusOK {
+			t.Fatalf("Expected batch request %d to succeed, got %v", i, err)
+		}
+	}
+	if waited := clock.Now().Sub(start); waited < 100*time.Millisecond {
+		t.Errorf("Expected requests beyond the burst to wait, waited %v", waited)
+	}
+
+	before := clock.Now()
+	if _, err := client.Get("/posts/1").SetRateLimitBypass().Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if clock.Now() != before {
+		t.Error("Expected bypassed request not to wait")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.Get("/posts/1").Result()
+	if _, err := client.GetWithContext(ctx, "/posts/1").Result(); err == nil {
+		t.Error("Expected canceled context to abort the wait")
+	}
+}
+
+// Test capturing response values into headers of later requests
+func TestRequest_Capture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("X-Session-Token", "tok-123")
+			http.SetCookie(w, &http.Cookie{Name: "upload", Value: "up-9"})
+		case "/me":
+			if r.Header.Get("X-Auth") != "tok-123" || r.Header.Get("X-Upload-Id") != "up-9" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	if _, err := client.Get("/me").Result(); err == nil {
+		t.Fatal("Expected request without captured values to fail")
+	}
+
+	_, err := client.Post("/login").
+		CaptureHeader("X-Session-Token", "X-Auth").
+		CaptureCookie("upload", "X-Upload-Id").
+		Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := client.Get("/me").Result(); err != nil {
+		t.Errorf("Expected captured values to be sent, got %v", err)
+	}
+}
+
+// Test adaptive throttling after 429 responses
+func TestClient_Throttle(t *testing.T) {
+	var limited int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&limited) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	clock := NewManualClock(time.Now())
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		Clock:   clock,
+		Throttle: &ThrottleConfig{
+			InitialRate:  4,
+			IncreaseStep: 2,
+			Cooldown:     time.Second,
+		},
+	})
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	client.Get("/").Result()
+	client.Get("/").Result()
+	state := client.ThrottleStats()[host]
+	if !state.Throttled || state.Rate != 2 || state.TooManyRequests != 2 {
+		t.Fatalf("Expected rate halved to 2 after two 429s, got %+v", state)
+	}
+
+	atomic.StoreInt32(&limited, 0)
+	client.Get("/").Result()
+	start := clock.Now()
+	client.Get("/").Result()
+	if waited := clock.Now().Sub(start); waited != 500*time.Millisecond {
+		t.Errorf("Expected requests spaced 500ms apart, waited %v", waited)
+	}
+
+	for i := 0; i < 2; i++ {
+		clock.Advance(time.Second)
+		client.Get("/").Result()
+	}
+	if state := client.ThrottleStats()[host]; state.Throttled {
+		t.Errorf("Expected throttle to lift after recovery, got %+v", state)
+	}
+}
+
+// Test size-based rotation and compression of recording files
+func TestRollingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "debug.log")
+	f, err := NewRollingFile(RollingFileConfig{
+		Path:       path,
+		MaxSize:    10,
+		MaxBackups: 2,
+		Compress:   true,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "fourth\n" {
+		t.Errorf("Expected current file to hold the last write, got %q", data)
+	}
+	if _, err := os.Stat(path + ".3.gz"); !os.IsNotExist(err) {
+		t.Error("Expected backups beyond MaxBackups to be removed")
+	}
+
+	gz, err := os.Open(path + ".1.gz")
+	if err != nil {
+		t.Fatalf("Expected compressed backup, got %v", err)
+	}
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatalf("Expected gzip backup, got %v", err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != "third\n" {
+		t.Errorf("Expected newest backup to hold %q, got %q", "third\n", data)
+	}
+}
+
+// Test the RFC 7234 cache honours Cache-Control and revalidates with ETags
+// Test that clients sharing a cache never see each other's responses
+func TestClient_CacheCredentials(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Authorization") + " " + r.Header.Get("Accept-Language")))
+	}))
+	defer server.Close()
+
+	base := New(Config{
+		BaseURL:  server.URL,
+		Timeout:  5 * time.Second,
+		CacheTTL: time.Minute,
+	})
+	alice := base.WithBearerToken("alice")
+	bob := base.WithBearerToken("bob")
+
+	get := func(c Client, lang string) string {
+		resp, err := c.Get("/me").SetHeader("Accept-Language", lang).Result()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return string(resp.Body)
+	}
+
+	if body := get(alice, "en"); body != "Bearer alice en" {
+		t.Fatalf("Expected alice's response, got %q", body)
+	}
+	if body := get(bob, "en"); body != "Bearer bob en" {
+		t.Errorf("Expected bob's own response, got %q", body)
+	}
+	if body := get(alice, "en"); body != "Bearer alice en" || atomic.LoadInt32(&hits) != 2 {
+		t.Errorf("Expected alice's cached response, got %q after %d hits", body, hits)
+	}
+	if body := get(alice, "de"); body != "Bearer alice de" {
+		t.Errorf("Expected a different Accept-Language to miss the cache, got %q", body)
+	}
+}
+
+func TestClient_HTTPCache(t *testing.T) {
+	var hits, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/private":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer server.Close()
+
+	clock := NewManualClock(time.Now())
+	client := New(Config{
+		BaseURL:   server.URL,
+		Timeout:   5 * time.Second,
+		Clock:     clock,
+		HTTPCache: true,
+	})
+
+	get := func(path string) *Response {
+		resp, err := client.Get(path).Result()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	get("/fresh")
+	if resp := get("/fresh"); !resp.FromCache || atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("Expected fresh response from cache, got FromCache=%v after %d hits", resp.FromCache, hits)
+	}
+	clock.Advance(time.Minute)
+	if resp := get("/fresh"); resp.FromCache {
+		t.Error("Expected expired response to be fetched again")
+	}
+
+	get("/etag")
+	resp := get("/etag")
+	if !resp.FromCache || string(resp.Body) != `{"path":"/etag"}` || atomic.LoadInt32(&notModified) != 1 {
+		t.Errorf("Expected 304 to serve the cached body, got %q (FromCache=%v)", resp.Body, resp.FromCache)
+	}
+
+	get("/private")
+	if resp := get("/private"); resp.FromCache {
+		t.Error("Expected no-store response not to be cached")
+	}
+}
+
+// Test Kubernetes service resolution through the Endpoints API
+func TestKubernetesResolver(t *testing.T) {
+	pod := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer pod.Close()
+	podIP, podPort, _ := net.SplitHostPort(strings.TrimPrefix(pod.URL, "http://"))
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/shop/endpoints/orders" || r.Header.Get("Authorization") != "Bearer sa-token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"subsets":[{"addresses":[{"ip":%q}],"notReadyAddresses":[{"ip":"10.0.0.9"}],
+			"ports":[{"name":"metrics","port":9090},{"name":"http","port":%s}]}]}`, podIP, podPort)
+	}))
+	defer apiServer.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("sa-token\n"), 0o600)
+
+	resolver, err := NewKubernetesResolver(KubernetesResolverConfig{
+		UseEndpoints: true,
+		PortName:     "http",
+		APIServer:    apiServer.URL,
+		TokenFile:    tokenFile,
+		CAFile:       filepath.Join(t.TempDir(), "missing.crt"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	addrs, err := resolver.Resolve(context.Background(), "orders.shop.svc.cluster.local")
+	if err != nil || len(addrs) != 1 || addrs[0] != net.JoinHostPort(podIP, podPort) {
+		t.Fatalf("Expected only the ready pod address, got %v (%v)", addrs, err)
+	}
+	if addrs, _ := resolver.Resolve(context.Background(), "api.example.com"); addrs != nil {
+		t.Errorf("Expected hosts outside the cluster to be ignored, got %v", addrs)
+	}
+
+	client := New(Config{
+		BaseURL:  "http://orders.shop.svc",
+		Timeout:  5 * time.Second,
+		Resolver: resolver,
+	})
+	resp, err := client.Get("/").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(resp.Body) != "orders.shop.svc" {
+		t.Errorf("Expected Host header to keep the service name, got %q", resp.Body)
+	}
+
+	dns, _ := NewKubernetesResolver(KubernetesResolverConfig{})
+	if addrs, _ := dns.Resolve(context.Background(), "orders.shop.svc:8080"); len(addrs) != 1 || addrs[0] != "orders.shop.svc.cluster.local:8080" {
+		t.Errorf("Expected DNS-qualified service name, got %v", addrs)
+	}
+}
+
+// Test conditional request helpers
+func TestRequest_Conditional(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"id":1,"title":"cached"}`))
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	resp, err := client.Get("/").SetIfNoneMatch(`"v1"`).Result()
+	if err != nil || resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("Expected 304, got %v (%v)", resp, err)
+	}
+	resp, err = client.Get("/").SetIfModifiedSince(modified.Add(time.Hour)).Result()
+	if err != nil || resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("Expected 304, got %v (%v)", resp, err)
+	}
+
+	first, err := client.Get("/").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var post TestPost
+	if err := client.Get("/").Revalidate(first).Into(&post); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if post.Title != "cached" {
+		t.Errorf("Expected the previous body to be decoded on 304, got %+v", post)
+	}
+}
+
+// Test Consul and etcd backed resolvers
+func TestDiscoveryResolvers(t *testing.T) {
+	var consulHits int32
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&consulHits, 1)
+		if r.URL.Path != "/v1/health/service/orders" || r.URL.Query().Get("passing") != "true" ||
+			r.URL.Query().Get("tag") != "v2" || r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[{"Node":{"Address":"10.0.0.1"},"Service":{"Address":"","Port":8080}},
+			{"Node":{"Address":"10.0.0.2"},"Service":{"Address":"10.1.0.2","Port":8081}}]`))
+	}))
+	defer consul.Close()
+
+	clock := NewManualClock(time.Now())
+	cr := NewConsulResolver(ConsulResolverConfig{Address: consul.URL, Token: "secret", Clock: clock})
+	addrs, err := cr.Resolve(context.Background(), "v2.orders.service.consul")
+	if err != nil || strings.Join(addrs, ",") != "10.0.0.1:8080,10.1.0.2:8081" {
+		t.Errorf("Expected healthy Consul instances, got %v (%v)", addrs, err)
+	}
+
+	// Instances are cached for the refresh interval of the configured clock
+	cr.Resolve(context.Background(), "v2.orders.service.consul")
+	if n := atomic.LoadInt32(&consulHits); n != 1 {
+		t.Errorf("Expected cached instances, got %d lookups", n)
+	}
+	clock.Advance(10 * time.Second)
+	cr.Resolve(context.Background(), "v2.orders.service.consul")
+	if n := atomic.LoadInt32(&consulHits); n != 2 {
+		t.Errorf("Expected a refresh after the interval, got %d lookups", n)
+	}
+	if addrs, _ := cr.Resolve(context.Background(), "api.example.com"); addrs != nil {
+		t.Errorf("Expected non-Consul hosts to be ignored, got %v", addrs)
+	}
+
+	b64 := base64.StdEncoding.EncodeToString
+	etcd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/v3/kv/range" || req["key"] != b64([]byte("/services/orders/")) || req["range_end"] != b64([]byte("/services/orders0")) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"kvs": []map[string]string{
+				{"value": b64([]byte("10.0.0.5:9000"))},
+				{"value": b64([]byte(`{"Addr":"10.0.0.6:9000"}`))},
+			},
+		})
+	}))
+	defer etcd.Close()
+
+	er := NewEtcdResolver(EtcdResolverConfig{Endpoint: etcd.URL})
+	addrs, err = er.Resolve(context.Background(), "orders.etcd")
+	if err != nil || strings.Join(addrs, ",") != "10.0.0.5:9000,10.0.0.6:9000" {
+		t.Errorf("Expected etcd instances, got %v (%v)", addrs, err)
+	}
+}
+
+// Test outlier detection ejects and gradually restores failing addresses
+func TestClient_OutlierDetection(t *testing.T) {
+	var healthyHits, brokenHits int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&healthyHits, 1)
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&brokenHits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	addrs := []string{
+		strings.TrimPrefix(healthy.URL, "http://"),
+		strings.TrimPrefix(broken.URL, "http://"),
+	}
+
+	var events []OutlierEvent
+	clock := NewManualClock(time.Now())
+	client := New(Config{
+		BaseURL: "http://backend.internal",
+		Timeout: 5 * time.Second,
+		Clock:   clock,
+		Resolver: ResolverFunc(func(ctx context.Context, host string) ([]string, error) {
+			return addrs, nil
+		}),
+		OutlierDetection: &OutlierDetectionConfig{
+			ConsecutiveFailures: 2,
+			BaseEjectionTime:    time.Minute,
+			RampUp:              time.Minute,
+			OnEvent:             func(e OutlierEvent) { events = append(events, e) },
+		},
+	})
+
+	for i := 0; i < 10; i++ {
+		client.Get("/").Result()
+	}
+	if n := atomic.LoadInt32(&brokenHits); n != 2 {
+		t.Errorf("Expected broken backend to be ejected after 2 failures, got %d hits", n)
+	}
+	if len(events) != 1 || !events[0].Ejected || events[0].Addr != addrs[1] {
+		t.Fatalf("Expected one ejection event, got %+v", events)
+	}
+
+	clock.Advance(2 * time.Minute)
+	client.Get("/").Result()
+	if len(events) != 2 || events[1].Ejected {
+		t.Errorf("Expected a restore event after the ejection ended, got %+v", events)
+	}
+}
+
+// Test stale responses are served while being refreshed in the background
+func TestClient_StaleWhileRevalidate(t *testing.T) {
+	var version int32
+	refreshed := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The refresh must repeat the request, not just its URL
+		if user, _, _ := r.BasicAuth(); user != "u" || r.URL.Path != "/items/1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "v%d", atomic.AddInt32(&version, 1))
+		if atomic.LoadInt32(&version) > 1 {
+			select {
+			case refreshed <- struct{}{}:
+			default:
+			}
+		}
+	}))
+	defer server.Close()
+
+	clock := NewManualClock(time.Now())
+	client := New(Config{
+		BaseURL:              server.URL,
+		Timeout:              5 * time.Second,
+		Clock:                clock,
+		CacheTTL:             time.Minute,
+		StaleWhileRevalidate: time.Hour,
+	})
+
+	get := func() *Response {
+		resp, err := client.Get("/items/{id}").SetPathParam("id", "1").SetAuth(BasicAuth("u", "p")).Result()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return resp
+	}
+
+	get()
+	clock.Advance(2 * time.Minute)
+	if resp := get(); string(resp.Body) != "v1" || !resp.FromCache {
+		t.Fatalf("Expected the stale response to be served, got %q", resp.Body)
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a background refresh")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for string(get().Body) != "v2" {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the refreshed response to replace the stale one")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Test the OAuth2 client credentials flow caches and refreshes tokens
+func TestClient_OAuth2ClientCredentials(t *testing.T) {
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if id != "app" || secret != "s3cret" || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "read write" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintf(w, `{"access_token":"valid-token","token_type":"Bearer","expires_in":%d}`, 3600+n)
+	}))
+	defer tokenServer.Close()
+
+	server := setupTestServer()
+	defer server.Close()
+
+	clock := NewManualClock(time.Now())
+	base := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		Clock:   clock,
+	})
+	client := base.WithOAuth2ClientCredentials(tokenServer.URL, "app", "s3cret", "read", "write")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Get("/auth/bearer").Result(); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&issued); n != 1 {
+		t.Errorf("Expected concurrent requests to share one token, got %d token requests", n)
+	}
+
+	clock.Advance(time.Hour)
+	if _, err := client.Get("/auth/bearer").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n := atomic.LoadInt32(&issued); n != 2 {
+		t.Errorf("Expected the token to be refreshed before expiry, got %d token requests", n)
+	}
+	if _, err := base.Get("/auth/bearer").Result(); err == nil {
+		t.Error("Expected the original client to stay unauthenticated")
+	}
+}
+
+// Test sticky routing sends a key to the same backend
+func TestClient_StickyRouting(t *testing.T) {
+	var addrs []string
+	for i := 0; i < 4; i++ {
+		name := strconv.Itoa(i)
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+		defer backend.Close()
+		addrs = append(addrs, strings.TrimPrefix(backend.URL, "http://"))
+	}
+
+	client := New(Config{
+		BaseURL: "http://backend.internal",
+		Timeout: 5 * time.Second,
+		Resolver: ResolverFunc(func(ctx context.Context, host string) ([]string, error) {
+			return addrs, nil
+		}),
+	})
+
+	route := func(key string) string {
+		resp, err := client.Get("/").SetRoutingKey(key).Result()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return string(resp.Body)
+	}
+
+	backends := make(map[string]bool)
+	for tenant := 0; tenant < 20; tenant++ {
+		key := "tenant-" + strconv.Itoa(tenant)
+		first := route(key)
+		for i := 0; i < 3; i++ {
+			if got := route(key); got != first {
+				t.Fatalf("Expected %s to stick to backend %s, got %s", key, first, got)
+			}
+		}
+		backends[first] = true
+	}
+	if len(backends) < 2 {
+		t.Errorf("Expected keys to spread across backends, got %v", backends)
+	}
+}
+
+// Test resolved HTTPS requests still verify the logical host name
+func TestClient_ResolverTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.TLS.ServerName))
+	}))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	client := New(Config{
+		BaseURL: "https://example.com",
+		Timeout: 5 * time.Second,
+		TLS:     &TLSConfig{RootCAs: roots},
+		Resolver: ResolverFunc(func(ctx context.Context, host string) ([]string, error) {
+			return []string{strings.TrimPrefix(server.URL, "https://")}, nil
+		}),
+	})
+
+	resp, err := client.Get("/").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(resp.Body) != "example.com example.com" {
+		t.Errorf("Expected Host and SNI to keep the logical name, got %q", resp.Body)
+	}
+}
+
+// Test weighted traffic splitting across base URLs
+func TestClient_TrafficSplit(t *testing.T) {
+	var stableHits, canaryHits int32
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&stableHits, 1)
+	}))
+	defer stable.Close()
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&canaryHits, 1)
+	}))
+	defer canary.Close()
+
+	split, err := NewTrafficSplit(
+		SplitTarget{BaseURL: stable.URL, Weight: 80},
+		SplitTarget{BaseURL: canary.URL, Weight: 20},
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	client := New(Config{
+		Timeout:      5 * time.Second,
+		TrafficSplit: split,
+	})
+
+	for i := 0; i < 200; i++ {
+		client.Get("/").Result()
+	}
+	if c := atomic.LoadInt32(&canaryHits); c < 15 || c > 70 {
+		t.Errorf("Expected roughly 20%% canary traffic, got %d of 200", c)
+	}
+
+	// Targets are drawn from the client's random source
+	atomic.StoreInt32(&canaryHits, 0)
+	pinned := New(Config{Timeout: 5 * time.Second, TrafficSplit: split, Rand: fixedRand(0.9)})
+	for i := 0; i < 10; i++ {
+		pinned.Get("/").Result()
+	}
+	if c := atomic.LoadInt32(&canaryHits); c != 10 {
+		t.Errorf("Expected every request on the canary, got %d of 10", c)
+	}
+
+	if err := split.SetWeight(stable.URL, 0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	atomic.StoreInt32(&stableHits, 0)
+	for i := 0; i < 20; i++ {
+		client.Get("/").Result()
+	}
+	if n := atomic.LoadInt32(&stableHits); n != 0 {
+		t.Errorf("Expected all traffic on the canary, stable got %d", n)
+	}
+
+	if err := split.SetWeight(canary.URL, 0); err == nil {
+		t.Error("Expected an error when every weight is zero")
+	}
+	// A split without targets leaves the base URL in use
+	atomic.StoreInt32(&stableHits, 0)
+	empty := New(Config{BaseURL: stable.URL, Timeout: 5 * time.Second, TrafficSplit: &TrafficSplit{}})
+	if _, err := empty.Get("/").Result(); err != nil || atomic.LoadInt32(&stableHits) != 1 {
+		t.Errorf("Expected the request to reach the base URL, got %v", err)
+	}
+}
+
+// Test pluggable bearer token providers
+func TestClient_TokenProvider(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	var calls int32
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	}).SetTokenProvider(TokenProviderFunc(func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "valid-token", nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get("/auth/bearer").Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected the provider to be asked per request, got %d calls", n)
+	}
+
+	if _, err := client.Get("/auth/bearer").SetTokenProvider(StaticToken("other")).Result(); err == nil {
+		t.Error("Expected the per-request provider to override the client one")
+	}
+
+	failing := TokenProviderFunc(func(ctx context.Context) (string, error) {
+		return "", errors.New("vault sealed")
+	})
+	if _, err := client.Get("/auth/bearer").SetTokenProvider(failing).Result(); err == nil || !strings.Contains(err.Error(), "vault sealed") {
+		t.Errorf("Expected provider error, got %v", err)
+	}
+}
+
+// Test re-authentication and a single retry after 401
+func TestClient_ReauthOnUnauthorized(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	}).SetBearerToken("expired-token")
+
+	var refreshes int32
+	client.OnUnauthorized(func(ctx context.Context) error {
+		atomic.AddInt32(&refreshes, 1)
+		client.SetBearerToken("valid-token")
+		return nil
+	})
+
+	var result map[string]interface{}
+	if err := client.Get("/auth/bearer").Into(&result); err != nil {
+		t.Fatalf("Expected the retried request to succeed, got %v", err)
+	}
+	if result["token"] != "valid-token" || atomic.LoadInt32(&refreshes) != 1 {
+		t.Errorf("Expected one refresh and the new token, got %v after %d refreshes", result, refreshes)
+	}
+
+	// A request still rejected after the refresh is not retried again
+	_, err := client.Get("/auth/bearer").SetTokenProvider(StaticToken("bad")).Result()
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusUnauthorized || reqErr.Attempts != 2 {
+		t.Errorf("Expected 401 after a single retry, got %v", err)
+	}
+}
+
+// Test journaled requests without a response are replayed after a restart
+func TestClient_Journal(t *testing.T) {
+	var received []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, r.Method+" "+r.URL.Path+" "+string(body)+" "+r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	path := filepath.Join(t.TempDir(), "requests.journal")
+	journal, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	client := New(Config{
+		BaseURL: down.URL,
+		Timeout: 5 * time.Second,
+		Journal: journal,
+	}).SetBearerToken("old-token")
+
+	if _, err := client.Post("/events").SetBody(map[string]int{"n": 1}).Result(); err == nil {
+		t.Fatal("Expected the request to fail while the server is down")
+	}
+	client.Get("/events").Result()
+	journal.Close()
+
+	// Restart: the pending POST is resent to the same URL
+	journal, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer journal.Close()
+	pending, _ := journal.Pending()
+	if len(pending) != 1 || pending[0].Header.Get("Authorization") != "" {
+		t.Fatalf("Expected one pending POST without credentials, got %+v", pending)
+	}
+	pending[0].URL = server.URL + "/events"
+	journal.write(pending[0])
+
+	client = New(Config{
+		Timeout: 5 * time.Second,
+		Journal: journal,
+	}).SetBearerToken("new-token")
+
+	n, err := client.ReplayJournal(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("Expected one replayed request, got %d (%v)", n, err)
+	}
+	if len(received) != 1 || received[0] != `POST /events {"n":1} Bearer new-token` {
+		t.Errorf("Expected the original POST with current auth, got %v", received)
+	}
+	if pending, _ := journal.Pending(); len(pending) != 0 {
+		t.Errorf("Expected the journal to be empty after replay, got %+v", pending)
+	}
+
+	// A failed compaction leaves the journal intact and writable
+	journal.write(pending[0])
+	if err := os.MkdirAll(filepath.Join(path+".tmp", "busy"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Compact(); err == nil {
+		t.Error("Expected compaction to fail")
+	}
+	entry := pending[0]
+	entry.ID = "second"
+	journal.write(entry)
+	if pending, _ := journal.Pending(); len(pending) != 2 {
+		t.Errorf("Expected both entries to survive, got %+v", pending)
+	}
+}
+
+// Test durable outbox delivering failed requests in the background
+func TestClient_Outbox(t *testing.T) {
+	var failures int32 = 2
+	delivered := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		delivered <- r.Method + " " + r.URL.Path + " " + string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	outbox, err := OpenOutbox(OutboxConfig{Dir: dir, RetryWaitMin: 10 * time.Millisecond, RetryWaitMax: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer outbox.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second, Outbox: outbox})
+
+	if _, err := client.Post("/readings").SetBody(map[string]int{"temp": 21}).Result(); err == nil {
+		t.Fatal("Expected the first attempt to fail")
+	}
+	// Reads are never queued
+	client.Get("/readings").Result()
+
+	select {
+	case got := <-delivered:
+		if got != `POST /readings {"temp":21}` {
+			t.Errorf("Expected the queued POST, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the outbox to deliver the request")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := outbox.Len(); n != 0 {
+		t.Errorf("Expected an empty outbox, got %d", n)
+	}
+	select {
+	case got := <-delivered:
+		t.Errorf("Expected a single delivery, got %q", got)
+	default:
+	}
+}
+
+// Test outbox entries surviving a restart and expiring after the TTL
+func TestClient_OutboxRestart(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	dir := t.TempDir()
+	outbox, err := OpenOutbox(OutboxConfig{Dir: dir, RetryWaitMin: time.Hour})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	client := New(Config{BaseURL: down.URL, Timeout: 5 * time.Second, Outbox: outbox}).SetBearerToken("secret")
+	client.Post("/a").SetBody("first").Result()
+	client.Put("/b").SetBody("second").Result()
+	outbox.Close()
+
+	outbox, err = OpenOutbox(OutboxConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	entries, _ := outbox.entries()
+	if len(entries) != 2 || entries[0].Header.Get("Authorization") != "" {
+		t.Fatalf("Expected two queued requests without credentials, got %+v", entries)
+	}
+
+	var dropped []string
+	var mu sync.Mutex
+	clock := NewManualClock(time.Now().Add(48 * time.Hour))
+	outbox.cfg.OnDrop = func(entry OutboxEntry) {
+		mu.Lock()
+		dropped = append(dropped, entry.Method)
+		mu.Unlock()
+	}
+	New(Config{Timeout: 5 * time.Second, Outbox: outbox, Clock: clock})
+	defer outbox.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for outbox.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dropped) != 2 || dropped[0] != "POST" {
+		t.Errorf("Expected both requests to expire, got %v", dropped)
+	}
+}
+
+// Test HMAC request signing
+func TestClient_HMACSigning(t *testing.T) {
+	secret := "s3cret"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		digest := hex.EncodeToString(sum[:])
+		mac := hmac.New(sha256.New, []byte(secret))
+		io.WriteString(mac, r.Method+"\n"+r.URL.RequestURI()+"\n"+r.Header.Get("X-Timestamp")+"\n"+digest)
+
+		if r.Header.Get("X-Key-Id") != "key-1" || r.Header.Get("X-Timestamp") != "1700000000" ||
+			r.Header.Get("X-Content-SHA256") != digest ||
+			!hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	client.Use(HMACSigning("key-1", secret, SignerOptions{
+		DigestHeader: "X-Content-SHA256",
+		Clock:        NewManualClock(time.Unix(1700000000, 0)),
+	}))
+
+	if _, err := client.Post("/orders").SetQueryParam("dry", "1").SetBody(map[string]int{"qty": 2}).Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := client.Get("/orders").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	wrong := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second}).Use(HMACSigning("key-1", "other", SignerOptions{}))
+	if _, err := wrong.Get("/orders").Result(); err == nil {
+		t.Error("Expected a wrong secret to be rejected")
+	}
+}
+
+// Test custom Authenticator with per-request override
+func TestClient_Authenticator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Api-Key")))
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		Authenticator: AuthenticatorFunc(func(ctx context.Context, req *http.Request) error {
+			req.Header.Set("X-Api-Key", "k1")
+			return nil
+		}),
+	})
+
+	tests := []struct {
+		name string
+		req  RequestBuilder
+		want string
+	}{
+		{"client", client.Get("/"), "|k1"},
+		{"bearer override", client.Get("/").SetAuth(BearerAuth(StaticToken("t1"))), "Bearer t1|"},
+		{"basic override", client.Get("/").SetAuth(BasicAuth("u", "p")), "Basic dTpw|"},
+	}
+	for _, tt := range tests {
+		resp, err := tt.req.Result()
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.name, err)
+		}
+		if string(resp.Body) != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, resp.Body)
+		}
+	}
+
+	failing := AuthenticatorFunc(func(context.Context, *http.Request) error { return errors.New("no credentials") })
+	if _, err := client.Get("/").SetAuth(failing).Result(); err == nil || !strings.Contains(err.Error(), "no credentials") {
+		t.Errorf("Expected the authenticator error, got %v", err)
+	}
+}
+
+// Test Accept-Language q-values and charset decoding of text responses
+func TestClient_AcceptLanguageAndCharset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lang":
+			w.Write([]byte(r.Header.Get("Accept-Language")))
+		case "/latin1":
+			w.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+			w.Write([]byte{'c', 'a', 'f', 0xE9})
+		case "/cp1252":
+			w.Header().Set("Content-Type", "text/plain; charset=windows-1252")
+			w.Write([]byte{0x80, '5'})
+		case "/utf16":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte{0xFF, 0xFE, 'h', 0, 'i', 0})
+		case "/bom":
+			w.Write([]byte("\xEF\xBB\xBFok"))
+		case "/sjis":
+			w.Header().Set("Content-Type", "text/plain; charset=Shift_JIS")
+			w.Write([]byte{0x82, 0xA0})
+		}
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	resp, err := client.Get("/lang").SetAcceptLanguage("fr-CH", "fr", "en;q=0.5", "de").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := string(resp.Body); got != "fr-CH, fr;q=0.9, en;q=0.5, de;q=0.7" {
+		t.Errorf("Unexpected Accept-Language %q", got)
+	}
+
+	for path, want := range map[string]string{"/latin1": "café", "/cp1252": "€5", "/utf16": "hi", "/bom": "ok"} {
+		resp, err := client.Get(path).Result()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if text, err := resp.Text(); err != nil || text != want {
+			t.Errorf("%s: expected %q, got %q (%v)", path, want, text, err)
+		}
+	}
+
+	resp, _ = client.Get("/sjis").Result()
+	if _, err := resp.Text(); !errors.Is(err, ErrUnsupportedCharset) {
+		t.Errorf("Expected ErrUnsupportedCharset, got %v", err)
+	}
+	RegisterCharset("shift_jis", func(b []byte) ([]byte, error) {
+		if bytes.Equal(b, []byte{0x82, 0xA0}) {
+			return []byte("あ"), nil
+		}
+		return nil, errors.New("unexpected input")
+	})
+	defer func() {
+		charsets.Lock()
+		delete(charsets.decoders, "shift_jis")
+		charsets.Unlock()
+	}()
+	if text, err := resp.Text(); err != nil || text != "あ" {
+		t.Errorf("Expected the registered decoder to be used, got %q (%v)", text, err)
+	}
+}
+
+// Test priming the cache for several endpoints
+func TestClient_Prime(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second, CacheTTL: time.Minute})
+
+	err := client.Prime(context.Background(), "/a", "/b", "/missing")
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || !strings.Contains(err.Error(), "prime /missing") {
+		t.Fatalf("Expected one failure for /missing, got %v", err)
+	}
+
+	before := atomic.LoadInt32(&hits)
+	resp, err := client.Get("/a").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp.FromCache || atomic.LoadInt32(&hits) != before {
+		t.Error("Expected the primed response to be served from the cache")
+	}
+}
+
+// Test deriving per-tenant clients without mutating the shared client
+func TestClient_WithBearerTokenClone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Tenant")))
+	}))
+	defer server.Close()
+
+	base := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tenant := strconv.Itoa(i)
+			client := base.WithBearerToken("token-"+tenant).SetGlobalHeader("X-Tenant", tenant)
+			resp, err := client.Get("/").Result()
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+			if want := "Bearer token-" + tenant + "|" + tenant; string(resp.Body) != want {
+				t.Errorf("Expected %q, got %q", want, resp.Body)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	basic := base.WithBasicAuth("u", "p")
+	for _, tc := range []struct {
+		client Client
+		want   string
+	}{{base, "|"}, {basic, "Basic dTpw|"}} {
+		resp, err := tc.client.Get("/").Result()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if string(resp.Body) != tc.want {
+			t.Errorf("Expected %q, got %q", tc.want, resp.Body)
+		}
+	}
+
+	clone := base.Clone().Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Tenant", "mw")
+			return next.RoundTrip(req)
+		})
+	})
+	if resp, _ := clone.Get("/").Result(); string(resp.Body) != "|mw" {
+		t.Errorf("Expected the clone to use its middleware, got %q", resp.Body)
+	}
+	if resp, _ := base.Get("/").Result(); string(resp.Body) != "|" {
+		t.Errorf("Expected the original client to be unaffected, got %q", resp.Body)
+	}
+}
+
+// Test streaming multipart/form-data uploads
+func TestClient_MultipartUpload(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && atomic.AddInt32(&attempts, 1) == 1 {
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var out []string
+		for _, field := range []string{"title", "tags"} {
+			out = append(out, field+"="+r.FormValue(field))
+		}
+		for _, field := range []string{"doc", "notes"} {
+			f, header, err := r.FormFile(field)
+			if err != nil {
+				continue
+			}
+			data, _ := io.ReadAll(f)
+			out = append(out, field+":"+header.Filename+"="+string(data))
+		}
+		w.Write([]byte(strings.Join(out, ";")))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("quarterly numbers"), 0o600); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	resp, err := client.Post("/upload").
+		SetMultipartFields(map[string]string{"title": "Q3", "tags": "finance"}).
+		SetFile("doc", path).
+		SetFileReader("notes", "notes.md", strings.NewReader("# draft")).
+		Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := "title=Q3;tags=finance;doc:report.txt=quarterly numbers;notes:notes.md=# draft"; string(resp.Body) != want {
+		t.Errorf("Expected %q, got %q", want, resp.Body)
+	}
+
+	// File parts are reopened when the request is retried
+	resp, err = client.Post("/flaky").SetFile("doc", path).SetRetry(1, time.Millisecond).Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := "title=;tags=;doc:report.txt=quarterly numbers"; string(resp.Body) != want {
+		t.Errorf("Expected %q, got %q", want, resp.Body)
+	}
+
+	if _, err := client.Post("/upload").SetFile("doc", filepath.Join(t.TempDir(), "missing")).Result(); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	// Closing a body abandoned part way closes its open files
+	body, err := formBody([]formPart{{field: "doc", filename: "report.txt", path: path}}, "boundary")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	files := body.(readCloser).Closer.(formFiles)
+	for files[0].f == nil {
+		if _, err := body.Read(make([]byte, 8)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	f := files[0].f
+	body.Close()
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected the file to be closed, got %v", err)
+	}
+}
+
+// gatedClock is a ManualClock whose Sleep blocks until released
+type gatedClock struct {
+	*ManualClock
+	release chan struct{}
+}
+
+func (c gatedClock) Sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return c.ManualClock.Sleep(ctx, d)
+}
+
+// Test parking requests during a 503 maintenance window
+func TestClient_MaintenanceWindow(t *testing.T) {
+	var maintenance int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&maintenance) == 1 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	clock := gatedClock{NewManualClock(time.Now()), make(chan struct{})}
+	client := New(Config{
+		BaseURL:     server.URL,
+		Timeout:     100 * time.Millisecond,
+		Clock:       clock,
+		Maintenance: &MaintenanceConfig{QueueSize: 1},
+	})
+
+	if _, err := client.Get("/").Result(); err == nil {
+		t.Fatal("Expected the maintenance response to fail")
+	}
+	atomic.StoreInt32(&maintenance, 0)
+
+	parked := make(chan error, 1)
+	go func() {
+		resp, err := client.Get("/").Result()
+		if err == nil && string(resp.Body) != "ok" {
+			err = fmt.Errorf("unexpected body %q", resp.Body)
+		}
+		parked <- err
+	}()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	deadline := time.Now().Add(2 * time.Second)
+	for client.MaintenanceStats()[host].Queued != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := client.Get("/").Result(); !errors.Is(err, ErrMaintenanceQueueFull) {
+		t.Errorf("Expected ErrMaintenanceQueueFull, got %v", err)
+	}
+
+	// Parking outlasts the client timeout without failing the request
+	time.Sleep(300 * time.Millisecond)
+	close(clock.release)
+	if err := <-parked; err != nil {
+		t.Fatalf("Expected the parked request to succeed, got %v", err)
+	}
+
+	state := client.MaintenanceStats()[host]
+	if state.Windows != 1 || state.Parked != 1 || state.Shed != 1 || state.Queued != 0 || !state.Until.IsZero() {
+		t.Errorf("Unexpected maintenance state %+v", state)
+	}
+}
+
+// Test the debug handler snapshot
+func TestClient_DebugHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL:        server.URL,
+		Timeout:        5 * time.Second,
+		CacheTTL:       time.Minute,
+		CircuitBreaker: &CircuitBreakerConfig{FailureThreshold: 1},
+		GlobalHeaders:  map[string]string{"X-Api-Key": "secret", "User-Agent": "test"},
+	})
+
+	client.Get("/a").Result()
+	client.Get("/a").Result()
+	client.Get("/fail").Result()
+	pool := client.Pool(2)
+	<-pool.Submit(client.Get("/a"))
+
+	rec := httptest.NewRecorder()
+	client.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/goclient", nil))
+	pool.Wait()
+
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("Expected credentials to be redacted, got %s", rec.Body.String())
+	}
+
+	var snapshot struct {
+		Config struct {
+			GlobalHeaders map[string]string `json:"global_headers"`
+		} `json:"config"`
+		Requests struct {
+			Total  int64 `json:"total"`
+			Failed int64 `json:"failed"`
+		} `json:"requests"`
+		Pools struct {
+			Active int64 `json:"active"`
+		} `json:"pools"`
+		Cache struct {
+			HitRate float64 `json:"hit_rate"`
+		} `json:"cache"`
+		Circuits     map[string]string `json:"circuits"`
+		RecentErrors []struct {
+			Endpoint string `json:"endpoint"`
+		} `json:"recent_errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &snapshot); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	if snapshot.Config.GlobalHeaders["User-Agent"] != "test" || snapshot.Requests.Total != 4 || snapshot.Requests.Failed != 1 ||
+		snapshot.Pools.Active != 1 || snapshot.Cache.HitRate != 0.5 || snapshot.Circuits[host] != "open" ||
+		len(snapshot.RecentErrors) != 1 || snapshot.RecentErrors[0].Endpoint != "/fail" {
+		t.Errorf("Unexpected snapshot %s", rec.Body.String())
+	}
+
+	// Errors quote the request URL, which must not leak query credentials
+	refused := New(Config{
+		BaseURL:           "http://127.0.0.1:1",
+		Timeout:           5 * time.Second,
+		GlobalQueryParams: map[string]string{"api_key": "SECRET123"},
+	})
+	if _, err := refused.Get("/a").Result(); err == nil {
+		t.Fatal("Expected connection error")
+	}
+	rec = httptest.NewRecorder()
+	refused.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/goclient", nil))
+	if body := rec.Body.String(); strings.Contains(body, "SECRET123") || !strings.Contains(body, "127.0.0.1:1") {
+		t.Errorf("Expected the error URL to be redacted, got %s", body)
+	}
+}
+
+// Test pprof labels and expvar publishing
+func TestClient_ProfilingIntegration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second, ProfilerLabels: true})
+
+	var operation, host string
+	client.OnBeforeRequest(func(req *http.Request) error {
+		operation, _ = pprof.Label(req.Context(), "goclient.operation")
+		host, _ = pprof.Label(req.Context(), "goclient.host")
+		return nil
+	})
+	if _, err := client.Get("/users/{id}").SetPathParam("id", "7").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if operation != "GET /users/{id}" || host != strings.TrimPrefix(server.URL, "http://") {
+		t.Errorf("Unexpected labels operation=%q host=%q", operation, host)
+	}
+
+	name := "goclient_test_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := client.PublishExpvar(name); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := client.PublishExpvar(name); err == nil {
+		t.Error("Expected publishing the same name twice to fail")
+	}
+	var vars struct {
+		Requests struct {
+			Total int64 `json:"total"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &vars); err != nil || vars.Requests.Total != 1 {
+		t.Errorf("Unexpected expvar %s (%v)", expvar.Get(name).String(), err)
+	}
+}
+
+// Allocation budgets for the request hot path, measured against an
+// in-memory transport so only the client's own work is counted. Raise them
+// only with a benchmark showing why.
+const (
+	getAllocBudget  = 60
+	postAllocBudget = 64
+)
+
+// stubTransport answers every request with a JSON body without touching the network
+func stubTransport(body string) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Body != nil {
+			io.Copy(io.Discard, req.Body)
+		}
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	})
+}
+
+const stubPost = `{"id":1,"title":"Test Post","body":"This is a test post","userId":1}`
+
+// Test that the request hot path stays within its allocation budget
+func TestClient_AllocationBudget(t *testing.T) {
+	if testing.Short() || raceEnabled {
+		t.Skip("allocation counts are only stable in a full, uninstrumented run")
+	}
+	client := New(Config{BaseURL: "http://api.test", Timeout: 5 * time.Second, Interceptor: stubTransport(stubPost)})
+
+	get := testing.AllocsPerRun(100, func() {
+		var post TestPost
+		if err := client.Get("/posts/1").Into(&post); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+	if get > getAllocBudget {
+		t.Errorf("GET allocates %.0f objects per request, budget is %d", get, getAllocBudget)
+	}
+
+	post := testing.AllocsPerRun(100, func() {
+		if _, err := client.Post("/posts").SetBody(TestPost{ID: 1, Title: "Test Post"}).Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+	if post > postAllocBudget {
+		t.Errorf("POST allocates %.0f objects per request, budget is %d", post, postAllocBudget)
+	}
+}
+
+// Test encoding and decoding bodies with a registered codec
+func TestClient_RegisterCodec(t *testing.T) {
+	// A toy key=value codec for map[string]string
+	marshal := func(v interface{}) ([]byte, error) {
+		m := v.(map[string]string)
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		for _, k := range keys {
+			b.WriteString(k + "=" + m[k] + "\n")
+		}
+		return []byte(b.String()), nil
+	}
+	unmarshal := func(data []byte, v interface{}) error {
+		m := v.(*map[string]string)
+		*m = make(map[string]string)
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			k, val, _ := strings.Cut(line, "=")
+			(*m)[k] = val
+		}
+		return nil
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") == "application/x-kv" {
+			w.Header().Set("Content-Type", "application/x-kv; charset=utf-8")
+			w.Write(append(body, "echo=true\n"...))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"format":"json"}`))
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second}).
+		RegisterCodec("application/x-kv", marshal, unmarshal)
+
+	var kv map[string]string
+	err := client.Post("/").
+		SetHeader("Content-Type", "application/x-kv").
+		SetBody(map[string]string{"b": "2", "a": "1"}).
+		Into(&kv)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(kv) != 3 || kv["a"] != "1" || kv["b"] != "2" || kv["echo"] != "true" {
+		t.Errorf("Unexpected decoded body %v", kv)
+	}
+
+	var js map[string]string
+	if err := client.Post("/").SetBody(map[string]string{"a": "1"}).Into(&js); err != nil || js["format"] != "json" {
+		t.Errorf("Expected JSON to remain the default, got %v (%v)", js, err)
+	}
+}
+
+// Test decoding responses straight from the stream
+func TestClient_IntoStream(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/posts":
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			json.NewEncoder(gz).Encode([]TestPost{{ID: 1, Title: "one"}, {ID: 2, Title: "two"}})
+			gz.Close()
+		case "/broken":
+			w.Write([]byte(`[{"id":`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second, CacheTTL: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		var posts []TestPost
+		if err := client.Get("/posts").IntoStream(&posts); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(posts) != 2 || posts[1].Title != "two" {
+			t.Errorf("Unexpected posts %+v", posts)
+		}
+	}
+	if hits != 2 {
+		t.Errorf("Expected streamed responses not to be cached, got %d hits", hits)
+	}
+
+	var posts []TestPost
+	if err := client.Get("/broken").IntoStream(&posts); err == nil || !strings.Contains(err.Error(), "failed to unmarshal") {
+		t.Errorf("Expected a decode error, got %v", err)
+	}
+
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	err := client.Get("/missing").SetError(&apiErr).IntoStream(&posts)
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusNotFound || apiErr.Message != "not found" {
+		t.Errorf("Expected a decoded RequestError, got %v (%+v)", err, apiErr)
+	}
+}
+
+// Test coalescing identical GET requests
+func TestClient_Coalescing(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	clock := NewManualClock(time.Now())
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second, CoalesceWindow: 10 * time.Millisecond, Clock: clock})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get("/slow").Result()
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+			resp.Headers.Set("X-Path", "mutated")
+			bodies[i] = string(resp.Body)
+		}(i)
+	}
+	for atomic.LoadInt32(&hits) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Give the other callers time to join the in-flight request
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if hits != 1 {
+		t.Errorf("Expected concurrent identical requests to be merged, got %d hits", hits)
+	}
+	for _, body := range bodies {
+		if body != "/slow " {
+			t.Errorf("Unexpected body %q", body)
+		}
+	}
+
+	// Served within the window, refetched after it
+	resp, _ := client.Get("/slow").Result()
+	if hits != 1 || resp.Headers.Get("X-Path") != "/slow" {
+		t.Errorf("Expected the completed result to be reused unmodified, got %d hits", hits)
+	}
+	clock.Advance(10 * time.Millisecond)
+	client.Get("/slow").Result()
+	if hits != 2 {
+		t.Errorf("Expected a new request after the window, got %d hits", hits)
+	}
+
+	// Different credentials are never merged
+	client.Get("/other").Result()
+	client.Get("/other").SetAuth(BearerAuth(StaticToken("t"))).Result()
+	if hits != 4 {
+		t.Errorf("Expected requests with different credentials to be sent separately, got %d hits", hits)
+	}
+	client.Get("/other").SetHeader("Cookie", "session=a").Result()
+	client.Get("/other").SetHeader("X-Tenant", "b").Result()
+	if hits != 6 {
+		t.Errorf("Expected requests with different headers to be sent separately, got %d hits", hits)
+	}
+
+	// The caller that started a request may give up without failing the others
+	atomic.StoreInt32(&hits, 0)
+	release = make(chan struct{})
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte("late"))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := client.GetWithContext(ctx, "/late").Result()
+		leader <- err
+	}()
+	for atomic.LoadInt32(&hits) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	waiter := make(chan *Response, 1)
+	go func() {
+		resp, _ := client.Get("/late").Result()
+		waiter <- resp
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the canceled caller to fail, got %v", err)
+	}
+	close(release)
+	if resp := <-waiter; resp == nil || string(resp.Body) != "late" {
+		t.Errorf("Expected the waiting caller to get the response, got %+v", resp)
+	}
+	if hits != 1 {
+		t.Errorf("Expected a single request, got %d hits", hits)
+	}
+}
+
+// Test injecting a custom JSON engine
+func TestClient_CustomJSONEngine(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"bad"}`))
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+
+	var marshals, unmarshals int32
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		JSONMarshal: func(v interface{}) ([]byte, error) {
+			atomic.AddInt32(&marshals, 1)
+			return json.Marshal(v)
+		},
+		JSONUnmarshal: func(data []byte, v interface{}) error {
+			atomic.AddInt32(&unmarshals, 1)
+			return json.Unmarshal(data, v)
+		},
+	})
+
+	var post TestPost
+	if err := client.Post("/echo").SetBody(TestPost{ID: 3, Title: "engine"}).Into(&post); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	client.Get("/error").SetError(&apiErr).Into(&post)
+
+	if post.Title != "engine" || apiErr.Message != "bad" {
+		t.Errorf("Unexpected decoded values %+v %+v", post, apiErr)
+	}
+	if marshals != 1 || unmarshals < 2 {
+		t.Errorf("Expected the custom engine to be used, got %d marshals and %d unmarshals", marshals, unmarshals)
+	}
+}
+
+// Test per-request and per-client source addresses
+func TestClient_LocalAddr(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		w.Write([]byte(host))
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second, LocalAddr: "127.0.0.1"})
+
+	resp, err := client.Get("/").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(resp.Body) != "127.0.0.1" {
+		t.Errorf("Expected source 127.0.0.1, got %s", resp.Body)
+	}
+
+	resp, err = client.Get("/").SetLocalAddr("127.0.0.1").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(resp.Body) != "127.0.0.1" {
+		t.Errorf("Expected source 127.0.0.1, got %s", resp.Body)
+	}
+
+	if _, err := client.Get("/").SetLocalAddr("not-an-ip").Result(); err == nil {
+		t.Error("Expected error for an invalid local address")
+	}
+
+	// A source address that is not local cannot be bound
+	if _, err := client.Get("/").SetLocalAddr("192.0.2.1").Result(); err == nil {
+		t.Error("Expected error for a non-local source address")
+	}
+}
+
+// startSOCKS5 runs a minimal SOCKS5 proxy requiring username/password
+// authentication and supporting CONNECT and UDP ASSOCIATE
+func startSOCKS5(t *testing.T, user, pass string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		buf := make([]byte, 512)
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+		io.ReadFull(conn, buf[:buf[1]])
+		conn.Write([]byte{5, 2})
+
+		io.ReadFull(conn, buf[:2])
+		u := make([]byte, buf[1])
+		io.ReadFull(conn, u)
+		io.ReadFull(conn, buf[:1])
+		p := make([]byte, buf[0])
+		io.ReadFull(conn, p)
+		if string(u) != user || string(p) != pass {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+
+		io.ReadFull(conn, buf[:3])
+		cmd := buf[1]
+		dst, domain, err := readSocksAddr(conn)
+		if err != nil {
+			return
+		}
+		target := domain
+		if dst != nil {
+			target = dst.String()
+		}
+
+		switch cmd {
+		case 1:
+			upstream, err := net.Dial("tcp", target)
+			if err != nil {
+				conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+				return
+			}
+			defer upstream.Close()
+			conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+			go io.Copy(upstream, conn)
+			io.Copy(conn, upstream)
+		case 3:
+			relay, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				return
+			}
+			defer relay.Close()
+			bound, _ := socksAddr(relay.LocalAddr().String())
+			conn.Write(append([]byte{5, 0, 0}, bound...))
+
+			go func() {
+				var client *net.UDPAddr
+				pkt := make([]byte, 2048)
+				for {
+					n, from, err := relay.ReadFromUDP(pkt)
+					if err != nil {
+						return
+					}
+					if client == nil || from.String() == client.String() {
+						client = from
+						r := bytes.NewReader(pkt[3:n])
+						to, _, err := readSocksAddr(r)
+						if err != nil {
+							continue
+						}
+						relay.WriteToUDP(pkt[n-r.Len():n], to)
+						continue
+					}
+					header, _ := socksAddr(from.String())
+					relay.WriteToUDP(append(append([]byte{0, 0, 0}, header...), pkt[:n]...), client)
+				}
+			}()
+			io.Copy(io.Discard, conn)
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// Test requests through an authenticated SOCKS5 proxy
+func TestClient_SOCKS5(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("via socks"))
+	}))
+	defer server.Close()
+
+	addr := startSOCKS5(t, "alice", "secret")
+
+	cfg := Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithSOCKS5(addr, "alice", "secret")(&cfg)
+	client := New(cfg)
+	resp, err := client.Get("/").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(resp.Body) != "via socks" {
+		t.Errorf("Expected body 'via socks', got %s", resp.Body)
+	}
+
+	cfg = Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithSOCKS5(addr, "alice", "wrong")(&cfg)
+	client = New(cfg)
+	if _, err := client.Get("/").Result(); err == nil {
+		t.Error("Expected error with wrong proxy credentials")
+	}
+
+	if _, err := NewRoundRobinProxyProvider(0, "ftp://proxy.example.com"); err == nil {
+		t.Error("Expected error for an unsupported proxy scheme")
+	}
+}
+
+// Test UDP association through a SOCKS5 proxy
+func TestDialSOCKS5UDP(t *testing.T) {
+	echo, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, from, err := echo.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			echo.WriteToUDP(buf[:n], from)
+		}
+	}()
+
+	addr := startSOCKS5(t, "alice", "secret")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := DialSOCKS5UDP(ctx, addr, "alice", "wrong"); err == nil {
+		t.Error("Expected error with wrong proxy credentials")
+	}
+
+	conn, err := DialSOCKS5UDP(ctx, addr, "alice", "secret")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.WriteTo([]byte("ping"), echo.LocalAddr()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	buf := make([]byte, 64)
+	n, from, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(buf[:n]) != "ping" {
+		t.Errorf("Expected 'ping', got %q", buf[:n])
+	}
+	if from.String() != echo.LocalAddr().String() {
+		t.Errorf("Expected reply from %s, got %s", echo.LocalAddr(), from)
+	}
+}
+
+// Test strict JSON decoding options
+func TestClient_JSONDecodeOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 9007199254740993, "name": "a", "extra": true}`))
+	}))
+	defer server.Close()
+
+	type item struct {
+		ID   interface{} `json:"id"`
+		Name string      `json:"name"`
+	}
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	var loose item
+	if err := client.Get("/").Into(&loose); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := loose.ID.(float64); !ok {
+		t.Errorf("Expected float64 ID by default, got %T", loose.ID)
+	}
+
+	var strict item
+	err := client.Get("/").SetJSONDecodeOptions(JSONDecodeOptions{DisallowUnknownFields: true}).Into(&strict)
+	if err == nil || !strings.Contains(err.Error(), "extra") {
+		t.Errorf("Expected unknown field error, got %v", err)
+	}
+
+	client = New(Config{
+		BaseURL:    server.URL,
+		Timeout:    5 * time.Second,
+		JSONDecode: JSONDecodeOptions{UseNumber: true},
+	})
+	var precise item
+	if err := client.Get("/").Into(&precise); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n, ok := precise.ID.(json.Number); !ok || n.String() != "9007199254740993" {
+		t.Errorf("Expected json.Number 9007199254740993, got %v (%T)", precise.ID, precise.ID)
+	}
+
+	var streamed item
+	err = client.Get("/").SetJSONDecodeOptions(JSONDecodeOptions{DisallowUnknownFields: true}).IntoStream(&streamed)
+	if err == nil {
+		t.Error("Expected unknown field error from IntoStream")
+	}
+}
+
+// Test charset conversion and BOM stripping before JSON decoding
+func TestClient_IntoCharset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bom":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(append([]byte{0xEF, 0xBB, 0xBF}, `{"name":"café"}`...))
+		case "/latin1":
+			w.Header().Set("Content-Type", "application/json; charset=ISO-8859-1")
+			w.Write([]byte("{\"name\":\"caf\xe9\"}"))
+		case "/utf16":
+			w.Header().Set("Content-Type", "application/json")
+			body := []byte{0xFF, 0xFE}
+			for _, c := range `{"name":"café"}` {
+				body = append(body, byte(c), 0)
+			}
+			w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	for _, path := range []string{"/bom", "/latin1", "/utf16"} {
+		var v struct{ Name string }
+		if err := client.Get(path).Into(&v); err != nil {
+			t.Fatalf("%s: Expected no error, got %v", path, err)
+		}
+		if v.Name != "café" {
+			t.Errorf("%s: Expected café, got %q", path, v.Name)
+		}
+	}
+
+	// A detector can override a mislabelled charset
+	client = New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		CharsetDetector: func(contentType string, body []byte) ([]byte, error) {
+			return DetectCharset("application/json; charset=windows-1252", body)
+		},
+	})
+	var v struct{ Name string }
+	if err := client.Get("/latin1").Into(&v); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if v.Name != "café" {
+		t.Errorf("Expected café, got %q", v.Name)
+	}
+}
+
+// Test path parameter hardening
+func TestClient_PathParamSafety(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	_, err := client.Get("/users/{id}/posts/{postId}").
+		SetPathParams(map[string]string{"id": "a/b", "postId": "7"}).
+		Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := client.Get("/users/{id}/posts").SetPathParam("id", "..").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := client.Get("/users/{id}/posts/{postId}").SetPathParam("id", "1").Result(); !errors.Is(err, ErrMissingPathParam) {
+		t.Errorf("Expected ErrMissingPathParam, got %v", err)
+	}
+	if _, err := client.Get("/users/{id}").SetPathParam("id", "").Result(); !errors.Is(err, ErrMissingPathParam) {
+		t.Errorf("Expected ErrMissingPathParam for an empty value, got %v", err)
+	}
+
+	want := []string{"/users/a%2Fb/posts/7", "/users/%2E%2E/posts"}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected paths %v, got %v", want, paths)
+	}
+}
+
+// Test query parameters built from a tagged struct
+func TestClient_QueryParamsFromStruct(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	type Paging struct {
+		Page int `url:"page,omitempty"`
+	}
+	type ListOptions struct {
+		Paging
+		Status string    `url:"status,omitempty"`
+		Owner  *string   `url:"owner"`
+		IDs    []int     `url:"id"`
+		Tags   []string  `url:"tags,comma"`
+		Since  time.Time `url:"since" layout:"2006-01-02"`
+		Until  time.Time `url:"until,unix"`
+		Limit  float64
+		Debug  bool `url:"-"`
+	}
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	opts := ListOptions{
+		Paging: Paging{Page: 2},
+		IDs:    []int{1, 2},
+		Tags:   []string{"a", "b"},
+		Since:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Until:  time.Unix(1700000000, 0),
+		Limit:  2.5,
+		Debug:  true,
+	}
+	_, err := client.Get("/items").
+		SetQueryParam("status", "open").
+		SetQueryParamsFromStruct(&opts).
+		Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := "Limit=2.5&id=1&id=2&page=2&since=2024-03-01&status=open&tags=a%2Cb&until=1700000000"
+	if query != want {
+		t.Errorf("Expected query %s, got %s", want, query)
+	}
+
+	_, err = client.Get("/items").SetQueryParamsFromStruct(struct{ C chan int }{}).Result()
+	if err == nil {
+		t.Error("Expected error for an unsupported field type")
+	}
+}
+
+// Test repeated query parameters
+func TestClient_AddQueryParam(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	_, err := client.Get("/items?sort=name").
+		AddQueryParam("id", "1").
+		AddQueryParam("id", "2").
+		AddQueryParam("id", "3").
+		SetQueryParamsFromValues(url.Values{"tag": {"a", "b"}}).
+		Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := strings.Join(query["id"], ","); got != "1,2,3" {
+		t.Errorf("Expected ids 1,2,3, got %s", got)
+	}
+	if got := strings.Join(query["tag"], ","); got != "a,b" {
+		t.Errorf("Expected tags a,b, got %s", got)
+	}
+	if query.Get("sort") != "name" {
+		t.Errorf("Expected sort from the endpoint to be kept, got %q", query.Get("sort"))
+	}
+}
+
+// Test extracting response fields by JSONPath
+func TestClient_Extract(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}], "next": "c2"}}`))
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	var ids []int
+	if err := client.Get("/").Extract("$.data.items[*].id", &ids); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("Expected ids [1 2], got %v", ids)
+	}
+
+	var name string
+	if err := client.Get("/").Extract("$.data.items[-1]['name']", &name); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if name != "b" {
+		t.Errorf("Expected name b, got %q", name)
+	}
+
+	var missing string
+	if err := client.Get("/").Extract("$.data.cursor", &missing); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("Expected ErrNoMatch, got %v", err)
+	}
+	if err := client.Get("/").Extract("data.items", &missing); err == nil {
+		t.Error("Expected error for a path without $")
+	}
+}
+
+// Test that canceling the Execute context aborts outstanding batch requests
+func TestBatch_ContextCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 10 * time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	batch := client.Batch()
+	for i := 0; i < 3; i++ {
+		batch.Add(client.Get("/slow"))
+	}
+
+	start := time.Now()
+	_, errs := batch.Execute(ctx)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected batch to stop on cancel, took %v", elapsed)
+	}
+	for i, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Request %d: expected context.Canceled, got %v", i, err)
+		}
+	}
+
+	// A batch executed with a done context sends nothing
+	_, errs = client.Batch().Add(client.Get("/slow")).Execute(ctx)
+	if !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", errs[0])
+	}
+}
+
+// Test that canceling a pool's context aborts its requests
+func TestPool_ContextCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 10 * time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := client.PoolWithContext(ctx, 2)
+	defer pool.Wait()
+
+	results := []<-chan Result{pool.Submit(client.Get("/slow")), pool.Submit(client.Get("/slow"))}
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := pool.Drain(); err == nil {
+		t.Fatal("Expected failures after cancellation")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected pool to stop on cancel, took %v", elapsed)
+	}
+	for i, ch := range results {
+		if res := <-ch; !errors.Is(res.Error, context.Canceled) {
+			t.Errorf("Request %d: expected context.Canceled, got %v", i, res.Error)
+		}
+	}
+}
+
+// Test HEAD, OPTIONS and custom methods
+func TestClient_Methods(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	for _, rb := range []RequestBuilder{
+		client.Head("/file"),
+		client.Options("/file"),
+		client.Request("PROPFIND", "/file"),
+		client.RequestWithContext(context.Background(), "PURGE", "/file"),
+	} {
+		resp, err := rb.Result()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		}
+	}
+
+	if got := strings.Join(methods, ","); got != "HEAD,OPTIONS,PROPFIND,PURGE" {
+		t.Errorf("Expected methods HEAD,OPTIONS,PROPFIND,PURGE, got %s", got)
+	}
+}
+
+// Test handlers in batches, panic recovery and async dispatch
+func TestClient_HandlerDispatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var successes, failures atomic.Int32
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	onSuccess := func(*Response) { successes.Add(1) }
+	onError := func(*RequestError) { failures.Add(1) }
+
+	_, errs := client.Batch().
+		Add(client.Get("/ok").OnSuccess(onSuccess)).
+		Add(client.Get("/fail").OnError(onError)).
+		Add(client.Get("/ok").OnSuccess(func(*Response) { panic("boom") })).
+		Execute(context.Background())
+	if errs[0] != nil || errs[2] != nil {
+		t.Fatalf("Expected no error, got %v", errs)
+	}
+	if successes.Load() != 1 || failures.Load() != 1 {
+		t.Errorf("Expected 1 success and 1 failure, got %d and %d", successes.Load(), failures.Load())
+	}
+
+	// Transport failures reach OnError too
+	var transportErr *RequestError
+	dead := New(Config{BaseURL: "http://127.0.0.1:1", Timeout: time.Second})
+	dead.Get("/").OnError(func(e *RequestError) { transportErr = e }).Result()
+	if transportErr == nil || transportErr.Err == nil {
+		t.Errorf("Expected OnError for a transport failure, got %v", transportErr)
+	}
+
+	async := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second, AsyncHandlers: true})
+	done := make(chan struct{})
+	release := make(chan struct{})
+	async.Get("/ok").OnSuccess(func(*Response) {
+		<-release
+		close(done)
+	}).Result()
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected async handler to run")
+	}
+}
+
+// Test streaming response bodies without buffering them
+func TestClient_IntoWriterAndStream(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 100000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+			return
+		}
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	var buf bytes.Buffer
+	if err := client.Get("/artifact").IntoWriter(&buf); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), payload) {
+		t.Errorf("Expected %d bytes, got %d", len(payload), buf.Len())
+	}
+
+	body, err := client.Get("/artifact").Stream()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("Expected %d bytes, got %d", len(payload), len(got))
+	}
+
+	// Closing early abandons the transfer
+	body, err = client.Get("/artifact").Stream()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	io.ReadFull(body, make([]byte, 10))
+	body.Close()
+
+	if _, err := client.Get("/missing").Stream(); err == nil {
+		t.Error("Expected error for a 404 response")
+	}
+	if err := client.Get("/missing").IntoWriter(io.Discard); err == nil {
+		t.Error("Expected error for a 404 response")
+	}
+}
+
+// Test downloading to disk with resumption and checksum verification
+func TestClient_DownloadFile(t *testing.T) {
+	payload := bytes.Repeat([]byte("goclient"), 10000)
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "artifact.bin", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact.bin")
+	sum := sha256.Sum256(payload)
+
+	// Resume from a partial download left by an earlier attempt
+	if err := os.WriteFile(path+".part", payload[:1000], 0o644); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := client.Get("/artifact").DownloadFile(path, WithSHA256(hex.EncodeToString(sum[:]))); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("Expected %d bytes, got %d", len(payload), len(got))
+	}
+	if ranges[0] != "bytes=1000-" {
+		t.Errorf("Expected a resumed range request, got %q", ranges[0])
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("Expected .part file to be removed, got %v", err)
+	}
+
+	// Servers that compress whatever the client accepts must not encode a range
+	gzipping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			w = gzipResponseWriter{w, gz}
+		}
+		http.ServeContent(w, r, "artifact.bin", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer gzipping.Close()
+	resumed := filepath.Join(dir, "resumed.bin")
+	if err := os.WriteFile(resumed+".part", payload[:5000], 0o644); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	gzClient := New(Config{BaseURL: gzipping.URL, Timeout: 5 * time.Second})
+	if err := gzClient.Get("/artifact").DownloadFile(resumed, WithSHA256(hex.EncodeToString(sum[:]))); err != nil {
+		t.Fatalf("Expected the resumed download to match, got %v", err)
+	}
+
+	err = client.Get("/artifact").DownloadFile(filepath.Join(dir, "bad.bin"), WithSHA256(strings.Repeat("0", 64)))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad.bin")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file after a checksum mismatch, got %v", err)
+	}
+}
+
+// gzipResponseWriter compresses the body written through it
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w gzipResponseWriter) Write(p []byte) (int, error) {
+	return w.gz.Write(p)
+}
+
+// Test warnings for requests close to their deadline
+func TestClient_DeadlineWarning(t *testing.T) {
+	// Slow responses take their time on the client's clock
+	clock := NewManualClock(time.Now())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			clock.Advance(120 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var warnings []DeadlineWarning
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+		Clock:   clock,
+		DeadlineWarning: &DeadlineWarningConfig{
+			Threshold: 0.5,
+			OnWarning: func(w DeadlineWarning) { warnings = append(warnings, w) },
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetWithContext(ctx, "/slow").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := client.Get("/fast").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d", len(warnings))
+	}
+	if w := warnings[0]; w.Endpoint != "/slow" || w.Budget > 200*time.Millisecond || w.Elapsed < 100*time.Millisecond {
+		t.Errorf("Unexpected warning %+v", w)
+	}
+}
+
+// Test segmented downloads with a failing segment being retried
+func TestClient_DownloadSegments(t *testing.T) {
+	payload := make([]byte, 4<<20)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	var mu sync.Mutex
+	var ranges []string
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		mu.Lock()
+		ranges = append(ranges, r.Method+" "+rng)
+		// Cut the first attempt at the second segment short
+		fail := strings.HasPrefix(rng, "bytes=1048576-") && !failed
+		if fail {
+			failed = true
+		}
+		mu.Unlock()
+
+		if fail {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 1048576-2097151/%d", len(payload)))
+			w.Header().Set("Content-Length", "1048576")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(payload[1048576 : 1048576+1000])
+			return
+		}
+		http.ServeContent(w, r, "artifact.bin", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	path := filepath.Join(t.TempDir(), "artifact.bin")
+	sum := sha256.Sum256(payload)
+	err := client.Get("/artifact").DownloadFile(path, WithSegments(4), WithSHA256(hex.EncodeToString(sum[:])))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Error("Expected downloaded file to match the payload")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// One HEAD, four segments and one resumed segment
+	if len(ranges) != 6 {
+		t.Errorf("Expected 6 requests, got %d: %v", len(ranges), ranges)
+	}
+	if !slices.Contains(ranges, "GET bytes=1049576-2097151") {
+		t.Errorf("Expected the failed segment to resume from byte 1049576, got %v", ranges)
+	}
+}
+
+// Test paging with resume tokens across pager instances
+func TestPager_Resume(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Write([]byte(`{"data": [{"id": 1}, {"id": 2}], "next": "c2"}`))
+		case "c2":
+			w.Write([]byte(`{"data": [{"id": 3}], "next": 42}`))
+		case "42":
+			w.Write([]byte(`{"data": [{"id": 4}], "next": null}`))
+		}
+	}))
+	defer server.Close()
+
+	type item struct {
+		ID int `json:"id"`
+	}
+	cfg := PagerConfig{ItemsField: "data", CursorField: "next", CursorParam: "cursor"}
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	pager := NewPager[item](client, "/items", cfg)
+	first, err := pager.Next(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(first) != 2 || first[1].ID != 2 {
+		t.Errorf("Expected ids 1 and 2, got %v", first)
+	}
+
+	// Checkpoint through JSON, as a crawl saving its progress would
+	saved, err := json.Marshal(map[string]ResumeToken{"token": pager.Token()})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var restored map[string]ResumeToken
+	json.Unmarshal(saved, &restored)
+
+	resumed, err := ResumePager[item](client, restored["token"], cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var ids []int
+	for resumed.More() {
+		items, err := resumed.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, it := range items {
+			ids = append(ids, it.ID)
+		}
+	}
+	if fmt.Sprint(ids) != "[3 4]" {
+		t.Errorf("Expected ids [3 4] after resuming, got %v", ids)
+	}
+	if resumed.Pages() != 3 {
+		t.Errorf("Expected 3 pages in total, got %d", resumed.Pages())
+	}
+	if _, err := resumed.Next(context.Background()); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	if _, err := ResumePager[item](client, "not a token", cfg); err == nil {
+		t.Error("Expected error for an invalid token")
+	}
+}
+
+// Test middlewares applied only to matching hosts and paths
+func TestClient_ConditionalMiddleware(t *testing.T) {
+	var signed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signed") != "" {
+			signed = append(signed, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sign := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("X-Signed", "yes")
+			return next.RoundTrip(req)
+		})
+	}
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	client.Use(When(sign, ForHost("127.0.0.1", "*.example.com"), ForPathPrefix("/v2/")))
+	other := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second}).
+		Use(When(sign, ForHost("api.github.com")))
+
+	for _, path := range []string{"/v1/users", "/v2/users"} {
+		if _, err := client.Get(path).Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if _, err := other.Get("/v2/users").Result(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if strings.Join(signed, ",") != "/v2/users" {
+		t.Errorf("Expected only /v2/users on the matching host to be signed, got %v", signed)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://API.Example.com:8443/", nil)
+	if !ForHost("*.example.com")(req) || ForHost("example.org")(req) || !ForMethod("get")(req) {
+		t.Error("Unexpected matcher result")
+	}
+}
+
+// Test SLO breach and recovery callbacks
+func TestClient_SLO(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var events []SLOBreach
+	cfg := Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithSLO(SLOConfig{
+		Objectives: []SLO{
+			{Operation: "GET /users/{id}", MaxErrorRate: 0.5, MinRequests: 4},
+			{Operation: "GET /other", MaxErrorRate: 0.01, MinRequests: 1},
+		},
+		OnBreach: func(b SLOBreach) {
+			mu.Lock()
+			events = append(events, b)
+			mu.Unlock()
+		},
+	})(&cfg)
+	client := New(cfg)
+
+	for i := 0; i < 4; i++ {
+		client.Get("/users/{id}").SetPathParam("id", strconv.Itoa(i)).Result()
+	}
+	mu.Lock()
+	if len(events) != 1 || events[0].Recovered || events[0].Operation != "GET /users/{id}" {
+		t.Fatalf("Expected one breach for the route, got %+v", events)
+	}
+	if events[0].ErrorRate != 1 || events[0].Requests != 4 {
+		t.Errorf("Expected error rate 1 over 4 requests, got %+v", events[0])
+	}
+	mu.Unlock()
+
+	failing.Store(false)
+	for i := 0; i < 6; i++ {
+		if _, err := client.Get("/users/{id}").SetPathParam("id", "1").Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	mu.Lock()
+	if len(events) != 2 || !events[1].Recovered {
+		t.Fatalf("Expected a recovery event, got %+v", events)
+	}
+	mu.Unlock()
+
+	// Latency is measured with the client's clock
+	clock := NewManualClock(time.Now())
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(300 * time.Millisecond)
+	}))
+	defer slow.Close()
+	var breaches []SLOBreach
+	cfg = Config{BaseURL: slow.URL, Timeout: 5 * time.Second, Clock: clock}
+	WithSLO(SLOConfig{
+		Objectives: []SLO{{Latency: 250 * time.Millisecond, MinRequests: 1}},
+		OnBreach:   func(b SLOBreach) { breaches = append(breaches, b) },
+	})(&cfg)
+	New(cfg).Get("/").Result()
+	if len(breaches) != 1 || breaches[0].Latency != 300*time.Millisecond {
+		t.Errorf("Expected a latency breach of 300ms, got %+v", breaches)
+	}
+}
+
+// Test encrypted journal and outbox entries are not stored in plaintext
+func TestClient_EncryptedState(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := NewCipher([]byte("short")); err == nil {
+		t.Error("Expected an error for an invalid key size")
+	}
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "requests.journal")
+	journal, err := OpenEncryptedJournal(path, c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	outbox, err := OpenOutbox(OutboxConfig{Dir: filepath.Join(dir, "outbox"), Cipher: c, RetryWaitMin: time.Hour})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer outbox.Close()
+
+	client := New(Config{BaseURL: down.URL, Timeout: 5 * time.Second, Journal: journal, Outbox: outbox})
+	client.Post("/patients").SetBody(map[string]string{"ssn": "123-45-6789"}).Result()
+	journal.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "outbox", "*"))
+	files = append(files, path)
+	for _, f := range files {
+		data, _ := os.ReadFile(f)
+		if bytes.Contains(data, []byte("123-45-6789")) || bytes.Contains(data, []byte("/patients")) {
+			t.Errorf("Expected %s to be encrypted, got %q", f, data)
+		}
+	}
+	if outbox.Len() != 1 {
+		t.Errorf("Expected one queued request, got %d", outbox.Len())
+	}
+
+	journal, _ = OpenEncryptedJournal(path, c)
+	pending, err := journal.Pending()
+	journal.Close()
+	if err != nil || len(pending) != 1 || string(pending[0].Body) != `{"ssn":"123-45-6789"}` {
+		t.Fatalf("Expected the pending request to decrypt, got %+v (%v)", pending, err)
+	}
+
+	// Appending a record makes the undecryptable line an error rather than a torn write
+	other, _ := NewCipher(bytes.Repeat([]byte{8}, 32))
+	journal, _ = OpenEncryptedJournal(path, other)
+	defer journal.Close()
+	journal.complete("x")
+	if _, err := journal.Pending(); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt with the wrong key, got %v", err)
+	}
+}
+
+// Test HMAC signing correcting for a skewed local clock
+func TestClient_HMACSigningSkew(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get("X-Timestamp"), 10, 64)
+		if time.Since(time.Unix(ts, 0)).Abs() > time.Minute || string(body) != `{"qty":2}` {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	client.Use(HMACSigning("key-1", "s3cret", SignerOptions{
+		Clock: NewManualClock(time.Now().Add(-time.Hour)),
+	}))
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Post("/orders").SetBody(map[string]int{"qty": 2}).Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("Expected one re-signed attempt then a corrected signature, got %d requests", n)
+	}
+
+	disabled := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second}).Use(HMACSigning("key-1", "s3cret", SignerOptions{
+		Clock:                 NewManualClock(time.Now().Add(-time.Hour)),
+		DisableSkewCorrection: true,
+	}))
+	if _, err := disabled.Post("/orders").SetBody(map[string]int{"qty": 2}).Result(); err == nil {
+		t.Error("Expected the skewed signature to be rejected without correction")
+	}
+}
+
+// Test polling an endpoint until a condition holds
+func TestClient_Poll(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch n := atomic.AddInt32(&hits, 1); {
+		case n == 2:
+			w.WriteHeader(http.StatusBadGateway)
+		case n < 4:
+			w.Write([]byte(`{"status":"running"}`))
+		default:
+			w.Write([]byte(`{"status":"done"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	resp, err := client.Poll("/jobs/1").Every(5 * time.Millisecond).Backoff(20 * time.Millisecond).Until(func(resp *Response) bool {
+		return strings.Contains(string(resp.Body), "done")
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(resp.Body) != `{"status":"done"}` || atomic.LoadInt32(&hits) != 4 {
+		t.Errorf("Expected the done status after 4 polls, got %q after %d", resp.Body, hits)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err = client.Poll("/jobs/1").Every(5 * time.Millisecond).Until(func(*Response) bool { return false }).Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+	// A zero interval must not poll in a tight loop
+	before := atomic.LoadInt32(&hits)
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	client.Poll("/jobs/1").Every(0).Until(func(*Response) bool { return false }).Run(ctx)
+	if n := atomic.LoadInt32(&hits) - before; n != 1 {
+		t.Errorf("Expected Every(0) to keep the default interval, got %d polls", n)
+	}
+}
+
+// Test deprecation headers reported once per route
+func TestClient_DeprecationWarnings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/") {
+			w.Header().Set("Deprecation", "@1688169599")
+			w.Header().Set("Sunset", "Wed, 11 Nov 2026 23:59:59 GMT")
+			w.Header().Set("Link", `<https://example.com/migrate>; rel="deprecation"`)
+			w.Header().Add("Warning", `299 api "v1 is deprecated, use v2"`)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var notices []DeprecationNotice
+	cfg := Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithDeprecationWarnings(DeprecationConfig{
+		OnDeprecation: func(n DeprecationNotice) { notices = append(notices, n) },
+	})(&cfg)
+	client := New(cfg)
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := client.Get("/v1/users/{id}").SetPathParam("id", id).Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	client.Get("/v2/users").Result()
+
+	if len(notices) != 1 {
+		t.Fatalf("Expected one notice, got %+v", notices)
+	}
+	n := notices[0]
+	if n.Route != "/v1/users/{id}" || n.Deprecated.Unix() != 1688169599 || n.Sunset.Year() != 2026 {
+		t.Errorf("Unexpected notice %+v", n)
+	}
+	if len(n.Links) != 1 || len(n.Warnings) != 1 || n.Warnings[0] != "v1 is deprecated, use v2" {
+		t.Errorf("Expected link and warning text, got %+v", n)
+	}
+	rec := httptest.NewRecorder()
+	client.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/goclient", nil))
+	if !strings.Contains(rec.Body.String(), `"deprecated": 2`) {
+		t.Errorf("Expected two deprecated responses in debug output, got %s", rec.Body.String())
+	}
+}
+
+// Test switching the base URL while a request to the old one is in flight
+func TestClient_SwitchBaseURL(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		w.Write([]byte("old"))
+	}))
+	defer old.Close()
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new"))
+	}))
+	defer next.Close()
+
+	client := New(Config{BaseURL: old.URL, Timeout: 5 * time.Second})
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := client.Get("/slow").Result()
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		slow <- string(resp.Body)
+	}()
+	<-started
+
+	switched := make(chan error, 1)
+	go func() { switched <- client.SwitchBaseURL(context.Background(), next.URL) }()
+
+	// New requests use the new base URL while the old one drains
+	time.Sleep(10 * time.Millisecond)
+	resp, err := client.Get("/fast").Result()
+	if err != nil || string(resp.Body) != "new" {
+		t.Fatalf("Expected the new base URL, got %v (%v)", resp, err)
+	}
+	select {
+	case err := <-switched:
+		t.Fatalf("Expected the switch to wait for the in-flight request, got %v", err)
+	default:
+	}
+
+	close(release)
+	if got := <-slow; got != "old" {
+		t.Errorf("Expected the in-flight request to complete, got %q", got)
+	}
+	if err := <-switched; err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.SwitchBaseURL(ctx, "not a url"); err == nil {
+		t.Error("Expected an invalid base URL to be rejected")
+	}
+}
+
+// Test GraphQL envelopes, partial data and typed errors
+func TestClient_GraphQL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(payload.Query, "invalid"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"Syntax Error","locations":[{"line":1,"column":3}]}]}`))
+		case payload.Variables["id"] == "2":
+			w.Write([]byte(`{"data":{"user":{"name":"Bob","email":null}},"errors":[{"message":"forbidden","path":["user","email"]}]}`))
+		default:
+			w.Write([]byte(`{"data":{"user":{"name":"Ann","email":"ann@example.com"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	query := `query($id: ID!) { user(id: $id) { name email } }`
+
+	var out struct {
+		User struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"user"`
+	}
+	if err := client.GraphQL("/graphql").Query(query).Variables(map[string]interface{}{"id": "1"}).Into(&out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if out.User.Name != "Ann" || out.User.Email != "ann@example.com" {
+		t.Errorf("Unexpected data %+v", out)
+	}
+
+	out.User.Name = ""
+	err := client.GraphQL("/graphql").Query(query).Variables(map[string]interface{}{"id": "2"}).Into(&out)
+	var gqlErrs GraphQLErrors
+	if !errors.As(err, &gqlErrs) || len(gqlErrs) != 1 || gqlErrs[0].Message != "forbidden" {
+		t.Fatalf("Expected GraphQL errors, got %v", err)
+	}
+	if out.User.Name != "Bob" {
+		t.Errorf("Expected partial data to be decoded, got %+v", out)
+	}
+
+	err = client.GraphQL("/graphql").Query("{ invalid").Into(&out)
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusBadRequest || !errors.As(err, &gqlErrs) || gqlErrs[0].Locations[0].Column != 3 {
+		t.Errorf("Expected a RequestError wrapping GraphQL errors, got %v", err)
+	}
+}
+
+// startConnectProxy runs a CONNECT proxy requiring the given Basic credentials
+func startConnectProxy(t *testing.T, credentials string) (string, *int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var tunnels int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				req, err := http.ReadRequest(br)
+				if err != nil {
+					return
+				}
+				want := "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+				if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != want {
+					conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+					return
+				}
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+					return
+				}
+				defer target.Close()
+				atomic.AddInt32(&tunnels, 1)
+				conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+				go io.Copy(target, br)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return "http://user:pass@" + ln.Addr().String(), &tunnels
+}
+
+// Test requests and raw connections through a CONNECT tunnel
+func TestClient_Tunnel(t *testing.T) {
+	proxy, tunnels := startConnectProxy(t, "user:pass")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("through " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	cfg := Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithTunnel(proxy)(&cfg)
+	client := New(cfg)
+	resp, err := client.Get("/hello").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(resp.Body) != "through /hello" || atomic.LoadInt32(tunnels) != 1 {
+		t.Errorf("Expected the request to use one tunnel, got %q after %d", resp.Body, atomic.LoadInt32(tunnels))
+	}
+
+	// Settings that would bypass the tunnel are rejected
+	if _, err := client.Get("/hello").SetLocalAddr("127.0.0.1").Result(); err == nil {
+		t.Error("Expected error for a local address with a tunnel")
+	}
+	if _, err := client.Get("/hello").SetProxy(proxy).Result(); err == nil {
+		t.Error("Expected error for a proxy with a tunnel")
+	}
+	cfg.LocalAddr = "127.0.0.1"
+	if _, err := New(cfg).Get("/hello").Result(); err == nil {
+		t.Error("Expected error for Config.LocalAddr with a tunnel")
+	}
+	if n := atomic.LoadInt32(tunnels); n != 1 {
+		t.Errorf("Expected rejected requests not to be sent, got %d tunnels", n)
+	}
+
+	// A non-HTTP service
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		conn, err := echo.Accept()
+		if err == nil {
+			io.Copy(conn, conn)
+			conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := DialTunnel(ctx, proxy, echo.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("Expected the echo through the tunnel, got %q (%v)", buf, err)
+	}
+
+	_, err = DialTunnel(ctx, strings.Replace(proxy, "pass", "wrong", 1), echo.Addr().String())
+	var tunnelErr *TunnelError
+	if !errors.As(err, &tunnelErr) || tunnelErr.StatusCode != http.StatusProxyAuthRequired {
+		t.Errorf("Expected a 407 TunnelError, got %v", err)
+	}
+	if strings.Contains(err.Error(), "wrong") {
+		t.Errorf("Expected the proxy password to be redacted, got %v", err)
+	}
+}
+
+// Test connection settings applied to the transport
+func TestClient_TransportTuning(t *testing.T) {
+	tr, _ := newTransport(Config{
+		MaxIdleConns:          7,
+		MaxIdleConnsPerHost:   3,
+		MaxConnsPerHost:       5,
+		IdleConnTimeout:       time.Minute,
+		TLSHandshakeTimeout:   2 * time.Second,
+		ResponseHeaderTimeout: 4 * time.Second,
+		DisableKeepAlives:     true,
+	})
+	if tr.MaxIdleConns != 7 || tr.MaxIdleConnsPerHost != 3 || tr.MaxConnsPerHost != 5 ||
+		tr.IdleConnTimeout != time.Minute || tr.TLSHandshakeTimeout != 2*time.Second ||
+		tr.ResponseHeaderTimeout != 4*time.Second || !tr.DisableKeepAlives {
+		t.Errorf("Expected the configured settings, got %+v", tr)
+	}
+	if def, _ := newTransport(Config{}); def.MaxIdleConns != http.DefaultTransport.(*http.Transport).MaxIdleConns {
+		t.Errorf("Expected zero values to keep the defaults, got %d", def.MaxIdleConns)
+	}
+
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second, DisableKeepAlives: true})
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get("/").Result(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 3 {
+		t.Errorf("Expected a connection per request without keep-alives, got %d", n)
+	}
+}
+
+// Test the attempt history reported after retries are exhausted
+func TestClient_AttemptsError(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	client := New(Config{
+		BaseURL:      server.URL,
+		Timeout:      5 * time.Second,
+		MaxRetries:   2,
+		RetryWaitMin: time.Millisecond,
+		RetryWaitMax: time.Millisecond,
+		Clock:        clock,
+	})
+
+	_, err := client.Get("/flaky").Result()
+	var reqErr *RequestError
+	var attemptsErr *AttemptsError
+	if !errors.As(err, &reqErr) || !errors.As(err, &attemptsErr) {
+		t.Fatalf("Expected a RequestError wrapping an AttemptsError, got %v", err)
+	}
+	if len(attemptsErr.Attempts) != 3 || reqErr.Attempts != 3 {
+		t.Fatalf("Expected 3 attempts, got %+v", attemptsErr.Attempts)
+	}
+	codes := []int{attemptsErr.Attempts[0].StatusCode, attemptsErr.Attempts[1].StatusCode, attemptsErr.Attempts[2].StatusCode}
+	if !slices.Equal(codes, []int{502, 503, 503}) {
+		t.Errorf("Expected each attempt's status, got %v", codes)
+	}
+	// Attempts are timed by the client's clock, which only the backoff advances
+	first := attemptsErr.Attempts[0]
+	if !first.Start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || first.Duration != 0 ||
+		!attemptsErr.Attempts[1].Start.After(first.Start) {
+		t.Errorf("Expected start times from the client clock, got %+v", attemptsErr.Attempts)
+	}
+
+	// Transport failures carry the error of every attempt
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	client = New(Config{BaseURL: down.URL, Timeout: 5 * time.Second, MaxRetries: 1, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond})
+	_, err = client.Get("/").Result()
+	if !errors.As(err, &attemptsErr) || len(attemptsErr.Attempts) != 2 || attemptsErr.Attempts[0].Err == nil {
+		t.Fatalf("Expected two failed attempts, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "(after 2 attempts)") {
+		t.Errorf("Expected the attempt count in the message, got %v", err)
+	}
+
+	// A single attempt is not wrapped
+	if _, err := New(Config{BaseURL: down.URL, Timeout: 5 * time.Second}).Get("/").Result(); errors.As(err, &attemptsErr) {
+		t.Errorf("Expected no AttemptsError without retries, got %v", err)
+	}
+}
+
+// Test TLS with a private CA and a client certificate
+func TestClient_TLSConfig(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.Organization[0]))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	// The server's own key pair doubles as the client certificate
+	dir := t.TempDir()
+	key, err := x509.MarshalPKCS8PrivateKey(server.TLS.Certificates[0].PrivateKey)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, caPEM, 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600)
+
+	cfg := Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithTLS(TLSConfig{CAPEM: caPEM, CertFile: certFile, KeyFile: keyFile})(&cfg)
+	resp, err := New(cfg).Get("/").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(resp.Body) == 0 {
+		t.Error("Expected the client certificate to be presented")
+	}
+
+	if _, err := New(Config{BaseURL: server.URL, Timeout: 5 * time.Second}).Get("/").Result(); err == nil {
+		t.Error("Expected the private CA to be untrusted by default")
+	}
+
+	cfg = Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithTLS(TLSConfig{CAPEM: caPEM, MinVersion: tls.VersionTLS13})(&cfg)
+	if _, err := New(cfg).Get("/").Result(); err == nil {
+		t.Error("Expected the request without a client certificate to be rejected")
+	}
+
+	cfg = Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithTLS(TLSConfig{CAFile: filepath.Join(dir, "missing.pem")})(&cfg)
+	if _, err := New(cfg).Get("/").Result(); err == nil || !strings.Contains(err.Error(), "CA file") {
+		t.Errorf("Expected the CA file error, got %v", err)
+	}
+}
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+	fields  []map[string]interface{}
+}
+
+func (l *recordingLogger) Log(level LogLevel, message string, fields map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, level.String()+" "+message)
+	l.fields = append(l.fields, fields)
+}
+
+// Test debug logging redaction, truncation and concurrent toggling
+func TestClient_DebugLogging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret-session"})
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(strings.Repeat("é", 20)))
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	cfg := Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithLogger(logger)(&cfg)
+	WithDebug()(&cfg)
+	WithLoggingOptions(LoggingOptions{LogHeaders: true, LogResponseBody: true, MaxBodySize: 5, RedactKeys: []string{"X-Tenant"}})(&cfg)
+	client := New(cfg)
+
+	client.Get("/items").SetQueryParam("token", "t0ken").SetQueryParam("page", "2").
+		SetHeader("Authorization", "Bearer abc").SetHeader("X-Tenant", "acme").SetBody([]byte("request-body")).Result()
+	client.Get("/missing").Result()
+
+	logger.mu.Lock()
+	out := fmt.Sprint(logger.entries, logger.fields)
+	logger.mu.Unlock()
+	for _, secret := range []string{"t0ken", "Bearer abc", "acme", "s3cret-session", "request-body"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %q to be kept out of the logs, got %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "page=2") || !strings.Contains(out, "éé... [truncated]") {
+		t.Errorf("Expected the query and a truncated body, got %s", out)
+	}
+	if len(logger.entries) != 4 || logger.entries[3] != "ERROR HTTP Response" {
+		t.Errorf("Expected the failed response to be logged as an error, got %v", logger.entries)
+	}
+
+	// Toggling while requests are in flight is safe
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.Get("/items").Result()
+		}()
+		go func() {
+			defer wg.Done()
+			client.DisableDebug()
+			client.SetLogger(&recordingLogger{})
+			client.EnableDebug()
+		}()
+	}
+	wg.Wait()
+
+	previous := defaultClient()
+	defer setDefaultClient(previous)
+	SetDefaultClient(Config{BaseURL: server.URL, Timeout: 5 * time.Second})
+	pkgLogger := &recordingLogger{}
+	SetLogger(pkgLogger)
+	EnableDebug()
+	Get("/items").Result()
+	DisableDebug()
+	Get("/items").Result()
+	if len(pkgLogger.entries) != 2 {
+		t.Errorf("Expected package-level debug logging for one request, got %v", pkgLogger.entries)
+	}
+}
+
+// Test decoding responses without a Content-Type
+func TestClient_ContentSniffing(t *testing.T) {
+	bodies := map[string][]byte{
+		"/json":   []byte(` {"name":"ann"}`),
+		"/xml":    []byte(`<?xml version="1.0"?><user><name>bob</name></user>`),
+		"/text":   []byte("plain words"),
+		"/binary": {0x00, 0x01, 0x02, 0xff},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = nil
+		w.Write(bodies[r.URL.Path])
+	}))
+	defer server.Close()
+
+	for body, want := range map[string]string{
+		`{"a":1}`: "application/json", "<a/>": "application/xml", "hello": "text/plain", "\x00\x01": "application/octet-stream",
+	} {
+		if got := SniffContentType([]byte(body)); got != want {
+			t.Errorf("SniffContentType(%q) = %q, want %q", body, got, want)
+		}
+	}
+
+	cfg := Config{BaseURL: server.URL, Timeout: 5 * time.Second}
+	WithContentSniffing("")(&cfg)
+	client := New(cfg)
+
+	var user struct {
+		Name string `json:"name" xml:"name"`
+	}
+	if err := client.Get("/json").Into(&user); err != nil || user.Name != "ann" {
+		t.Errorf("Expected JSON to decode, got %+v (%v)", user, err)
+	}
+	if err := client.Get("/xml").Into(&user); err != nil || user.Name != "bob" {
+		t.Errorf("Expected XML to decode, got %+v (%v)", user, err)
+	}
+	var text string
+	if err := client.Get("/text").Into(&text); err != nil || text != "plain words" {
+		t.Errorf("Expected text to decode into a string, got %q (%v)", text, err)
+	}
+	if err := client.Get("/binary").Into(&user); !errors.Is(err, ErrUnsupportedContentType) {
+		t.Errorf("Expected ErrUnsupportedContentType for binary data, got %v", err)
+	}
+	var raw []byte
+	if err := client.Get("/binary").Into(&raw); err != nil || !bytes.Equal(raw, bodies["/binary"]) {
+		t.Errorf("Expected binary data in a byte slice, got %v (%v)", raw, err)
+	}
+
+	// Without sniffing, the configured default applies
+	cfg = Config{BaseURL: server.URL, Timeout: 5 * time.Second, DefaultContentType: "text/plain"}
+	if err := New(cfg).Get("/json").Into(&text); err != nil || text != ` {"name":"ann"}` {
+		t.Errorf("Expected the default content type, got %q (%v)", text, err)
+	}
+}
+
+// Test a fixed proxy URL with per-request overrides
+func TestClient_ProxyURL(t *testing.T) {
+	proxy := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + " " + r.URL.Host))
+		}))
+	}
+	a, b := proxy("a"), proxy("b")
+	defer a.Close()
+	defer b.Close()
+
+	cfg := Config{BaseURL: "http://upstream.invalid", Timeout: 5 * time.Second}
+	WithProxyURL(a.URL)(&cfg)
+	client := New(cfg)
+
+	resp, err := client.Get("/").Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := string(resp.Body); got != "a upstream.invalid" {
+		t.Errorf("Expected request through proxy a, got %q", got)
+	}
+
+	resp, err = client.Get("/").SetProxy(b.URL).Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := string(resp.Body); got != "b upstream.invalid" {
+		t.Errorf("Expected request through proxy b, got %q", got)
+	}
+
+	if _, err := client.Get("/").SetProxy("ftp://proxy.example.com").Result(); err == nil {
+		t.Error("Expected error for an unsupported proxy scheme")
+	}
+
+	bad := New(Config{BaseURL: "http://upstream.invalid", ProxyURL: "ftp://proxy.example.com"})
+	if _, err := bad.Get("/").Result(); err == nil {
+		t.Error("Expected error for an invalid ProxyURL")
+	}
+
+	// Per-request proxies also work without a client-level proxy
+	plain := New(Config{BaseURL: "http://upstream.invalid", Timeout: 5 * time.Second, IgnoreEnvProxy: true})
+	resp, err = plain.Get("/").SetProxy(a.URL).Result()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := string(resp.Body); got != "a upstream.invalid" {
+		t.Errorf("Expected request through proxy a, got %q", got)
+	}
+}
+
+// Benchmark tests
+func BenchmarkClient_Get(b *testing.B) {
+	server := setupTestServer()
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var post TestPost
+		err := client.Get("/posts/1").Into(&post)
+		if err != nil {
+			b.Fatalf("Request failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkClient_Batch(b *testing.B) {
+	server := setupTestServer()
+	defer server.Close()
+
+	client := New(Config{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch := client.Batch()
+		for j := 0; j < 5; j++ {
+			batch.Add(client.Get("/posts/1"))
+		}
+		_, _ = batch.Execute(context.Background())
+	}
+}
+
+func BenchmarkClient_GetInMemory(b *testing.B) {
+	client := New(Config{BaseURL: "http://api.test", Timeout: 5 * time.Second, Interceptor: stubTransport(stubPost)})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var post TestPost
+		if err := client.Get("/posts/1").Into(&post); err != nil {
+			b.Fatalf("Request failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkClient_PostInMemory(b *testing.B) {
+	client := New(Config{BaseURL: "http://api.test", Timeout: 5 * time.Second, Interceptor: stubTransport(stubPost)})
+	body := TestPost{ID: 1, Title: "Test Post", Body: "This is a test post", UserID: 1}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Post("/posts").SetBody(body).Result(); err != nil {
+			b.Fatalf("Request failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkClient_Pool(b *testing.B) {
+	client := New(Config{BaseURL: "http://api.test", Timeout: 5 * time.Second, Interceptor: stubTransport(stubPost)})
+	pool := client.Pool(8)
+	defer pool.Wait()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.Submit(client.Get("/posts/1"))
+	}
+	if err := pool.Drain(); err != nil {
+		b.Fatalf("Request failed: %v", err)
+	}
+}
+
+func BenchmarkClient_LargeBodyDecode(b *testing.B) {
+	for _, stream := range []bool{false, true} {
+		name := "Into"
+		if stream {
+			name = "IntoStream"
+		}
+		b.Run(name, func(b *testing.B) { benchmarkLargeBodyDecode(b, stream) })
+	}
+}
+
+func benchmarkLargeBodyDecode(b *testing.B, stream bool) {
+	posts := make([]TestPost, 2000)
+	for i := range posts {
+		posts[i] = TestPost{ID: i, Title: "Test Post", Body: strings.Repeat("lorem ipsum ", 10), UserID: i % 10}
+	}
+	body, _ := json.Marshal(posts)
+	client := New(Config{BaseURL: "http://api.test", Timeout: 5 * time.Second, Interceptor: stubTransport(string(body))})
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		var out []TestPost
+		decode := client.Get("/posts").Into
+		if stream {
+			decode = client.Get("/posts").IntoStream
+		}
+		if err := decode(&out); err != nil {
+			b.Fatalf("Request failed: %v", err)
 		}
-		_, _ = batch.Execute(context.Background())
 	}
 }
@@ -0,0 +1,135 @@
+package goclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// GraphQLError is a single entry of a GraphQL response's errors list
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation points into the query document
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (e GraphQLError) Error() string {
+	return e.Message
+}
+
+// GraphQLErrors is returned when a GraphQL response carries errors. When
+// the HTTP status is not successful it is the wrapped error of a
+// RequestError, so it can be retrieved with errors.As either way.
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return "graphql: " + strings.Join(messages, "; ")
+}
+
+// GraphQLRequest builds a GraphQL operation sent as a JSON POST. Create one
+// with Client.GraphQL.
+type GraphQLRequest struct {
+	client    *client
+	ctx       context.Context
+	endpoint  string
+	query     string
+	operation string
+	variables map[string]interface{}
+	headers   map[string]string
+}
+
+type graphQLPayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+// GraphQL returns a GraphQL request to endpoint
+func (c *client) GraphQL(endpoint string) *GraphQLRequest {
+	return c.GraphQLWithContext(context.Background(), endpoint)
+}
+
+// GraphQLWithContext returns a GraphQL request to endpoint bound to ctx
+func (c *client) GraphQLWithContext(ctx context.Context, endpoint string) *GraphQLRequest {
+	return &GraphQLRequest{client: c, ctx: ctx, endpoint: endpoint}
+}
+
+// Query sets the query or mutation document
+func (g *GraphQLRequest) Query(query string) *GraphQLRequest {
+	g.query = query
+	return g
+}
+
+// OperationName selects the operation to run when the document has several
+func (g *GraphQLRequest) OperationName(name string) *GraphQLRequest {
+	g.operation = name
+	return g
+}
+
+// Variables sets the operation's variables
+func (g *GraphQLRequest) Variables(vars map[string]interface{}) *GraphQLRequest {
+	g.variables = vars
+	return g
+}
+
+// SetHeader sets a header on the HTTP request
+func (g *GraphQLRequest) SetHeader(key, value string) *GraphQLRequest {
+	if g.headers == nil {
+		g.headers = make(map[string]string)
+	}
+	g.headers[key] = value
+	return g
+}
+
+// Into sends the operation and decodes the response's data field into v.
+// When the response also carries errors, any partial data is decoded and
+// the errors are returned as GraphQLErrors.
+func (g *GraphQLRequest) Into(v interface{}) error {
+	rb := g.client.PostWithContext(g.ctx, g.endpoint).
+		SetHeader("Accept", "application/json").
+		SetBody(graphQLPayload{Query: g.query, OperationName: g.operation, Variables: g.variables})
+	for k, val := range g.headers {
+		rb.SetHeader(k, val)
+	}
+
+	resp, err := rb.Result()
+	if err != nil {
+		var reqErr *RequestError
+		var envelope graphQLResponse
+		if errors.As(err, &reqErr) && json.Unmarshal(reqErr.Response, &envelope) == nil && len(envelope.Errors) > 0 {
+			reqErr.Err = envelope.Errors
+		}
+		return err
+	}
+
+	var envelope graphQLResponse
+	if err := g.client.jsonCodec().unmarshal(resp.Body, &envelope); err != nil {
+		return err
+	}
+	if v != nil && len(envelope.Data) > 0 && string(envelope.Data) != "null" {
+		if err := g.client.jsonCodec().unmarshal(envelope.Data, v); err != nil {
+			return err
+		}
+	}
+	if len(envelope.Errors) > 0 {
+		return envelope.Errors
+	}
+	return nil
+}
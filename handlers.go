@@ -0,0 +1,51 @@
+package goclient
+
+import "errors"
+
+// notify runs the OnSuccess or OnError handler once the request has
+// finished. Transport failures reach OnError as a *RequestError without a
+// status code.
+func (r *request) notify() {
+	switch {
+	case r.err == nil && r.response != nil && r.successHandler != nil:
+		fn, resp := r.successHandler, r.response
+		r.runHandler(func() { fn(resp) })
+	case r.err != nil && r.errorHandler != nil:
+		fn, reqErr := r.errorHandler, r.requestError()
+		r.runHandler(func() { fn(reqErr) })
+	}
+}
+
+// requestError returns the request's failure as a *RequestError
+func (r *request) requestError() *RequestError {
+	var reqErr *RequestError
+	if errors.As(r.err, &reqErr) {
+		return reqErr
+	}
+	return &RequestError{Method: r.method, URL: r.endpoint, Err: r.err}
+}
+
+// runHandler calls a response handler, on its own goroutine when
+// Config.AsyncHandlers is set. A panicking handler is recovered and logged
+// so it cannot take down a batch or pool worker.
+func (r *request) runHandler(call func()) {
+	logger, method, endpoint := r.client.currentLogger(), r.method, r.endpoint
+	run := func() {
+		defer func() {
+			if p := recover(); p != nil && logger != nil {
+				logger.Log(LogLevelError, "Response handler panicked", map[string]interface{}{
+					"method": method,
+					"url":    endpoint,
+					"panic":  p,
+				})
+			}
+		}()
+		call()
+	}
+
+	if r.client.asyncNotify {
+		go run()
+		return
+	}
+	run()
+}
@@ -0,0 +1,123 @@
+package goclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// DecodeHeaders copies response headers into the fields of the struct pointed
+// to by v that carry a `header:"Name"` tag. Supported field types are string,
+// []string, bool, integers, floats, time.Time (HTTP date format) and
+// time.Duration (whole seconds). v may also be a *http.Header, which receives
+// a copy of all headers.
+func DecodeHeaders(h http.Header, v interface{}) error {
+	if dst, ok := v.(*http.Header); ok {
+		*dst = h.Clone()
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("header target must be a non-nil pointer to a struct")
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name := field.Tag.Get("header")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if err := setHeaderField(rv.Field(i), values); err != nil {
+			return fmt.Errorf("failed to decode header %s into %s: %w", name, field.Name, err)
+		}
+	}
+	return nil
+}
+
+func setHeaderField(f reflect.Value, values []string) error {
+	value := values[0]
+
+	if f.Type() == timeType {
+		t, err := http.ParseTime(value)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if f.Type() == reflect.TypeOf(time.Duration(0)) {
+		secs, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(time.Duration(secs) * time.Second))
+		return nil
+	}
+
+	if f.Kind() == reflect.Slice {
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", f.Type())
+		}
+		f.Set(reflect.ValueOf(append([]string(nil), values...)))
+		return nil
+	}
+	return setBasicField(f, value)
+}
+
+// setBasicField parses value into a string, bool, integer or float field
+func setBasicField(f reflect.Value, value string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
+
+// IntoWithHeaders decodes the response body into v and the response headers
+// into h (see DecodeHeaders)
+func (r *request) IntoWithHeaders(v interface{}, h interface{}) error {
+	resp, err := r.Result()
+	if err := r.decodeInto(resp, err, v); err != nil {
+		return err
+	}
+	return DecodeHeaders(resp.Headers, h)
+}
@@ -0,0 +1,47 @@
+package goclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RequestHook runs just before a request is sent. It may modify the request;
+// returning an error aborts it.
+type RequestHook func(req *http.Request) error
+
+// ResponseHook runs once a response has been received, whatever its status,
+// and before it is checked and decoded. It may modify the response headers
+// and body; returning an error fails the request.
+type ResponseHook func(resp *Response) error
+
+// OnBeforeRequest registers a hook that runs before every request is sent.
+// Hooks run in registration order.
+func (c *client) OnBeforeRequest(hook RequestHook) Client {
+	c.beforeRequest = append(c.beforeRequest, hook)
+	return c
+}
+
+// OnAfterResponse registers a hook that runs after every response is
+// received. Hooks run in registration order.
+func (c *client) OnAfterResponse(hook ResponseHook) Client {
+	c.afterResponse = append(c.afterResponse, hook)
+	return c
+}
+
+func (c *client) runBeforeRequest(req *http.Request) error {
+	for _, hook := range c.beforeRequest {
+		if err := hook(req); err != nil {
+			return fmt.Errorf("before request hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *client) runAfterResponse(resp *Response) error {
+	for _, hook := range c.afterResponse {
+		if err := hook(resp); err != nil {
+			return fmt.Errorf("after response hook failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package goclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// RequestInfo is a low-cardinality description of a request, suitable for
+// log fields, metric labels and span names
+type RequestInfo struct {
+	// ID uniquely identifies the request, see Config.IDGenerator
+	ID     string
+	Method string
+	// Route is the endpoint template before path parameter substitution,
+	// e.g. "/users/{id}"
+	Route string
+	// URL is the fully resolved request URL
+	URL string
+	// Meta holds the values set with RequestBuilder.SetMeta
+	Meta map[string]interface{}
+	// RoutingKey is the sticky routing key set with RequestBuilder.SetRoutingKey
+	RoutingKey string
+	// LocalAddr is the source IP set with RequestBuilder.SetLocalAddr
+	LocalAddr string
+	// Proxy is the proxy URL set with RequestBuilder.SetProxy
+	Proxy string
+}
+
+type requestInfoKey struct{}
+
+// RequestInfoFromContext returns the RequestInfo attached to an outgoing
+// request's context. Middlewares and interceptors can use it to label
+// requests by route instead of by raw URL.
+func RequestInfoFromContext(ctx context.Context) (RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info, ok
+}
+
+// MetaFromContext returns a metadata value set with RequestBuilder.SetMeta
+func MetaFromContext(ctx context.Context, key string) (interface{}, bool) {
+	info, ok := RequestInfoFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	value, ok := info.Meta[key]
+	return value, ok
+}
+
+func contextWithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// ErrMissingPathParam is returned when a {name} placeholder in the endpoint
+// has no value, or an empty one, so a forgotten SetPathParam never sends a
+// request to the wrong resource
+var ErrMissingPathParam = errors.New("goclient: missing path parameter")
+
+// expandPath substitutes {name} placeholders in the path of endpoint with
+// escaped values. Values of "." and ".." are escaped as well so they cannot
+// be resolved as dot segments and climb out of their place in the path.
+func expandPath(endpoint string, params map[string]string) (string, error) {
+	path, query := endpoint, ""
+	if i := strings.IndexByte(endpoint, '?'); i >= 0 {
+		path, query = endpoint[:i], endpoint[i:]
+	}
+	if !strings.Contains(path, "{") {
+		return endpoint, nil
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(path[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		name := path[start+1 : end]
+		if name == "" || strings.ContainsAny(name, "/{") {
+			b.WriteString(path[:start+1])
+			path = path[start+1:]
+			continue
+		}
+
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingPathParam, name)
+		}
+		if value == "" {
+			return "", fmt.Errorf("%w: %s is empty", ErrMissingPathParam, name)
+		}
+		escaped := url.PathEscape(value)
+		if value == "." || value == ".." {
+			escaped = strings.ReplaceAll(value, ".", "%2E")
+		}
+		b.WriteString(path[:start])
+		b.WriteString(escaped)
+		path = path[end+1:]
+	}
+	b.WriteString(path)
+	b.WriteString(query)
+	return b.String(), nil
+}
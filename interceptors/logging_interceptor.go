@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/indalyadav56/goclient"
 )
 
 type Interceptor interface {
@@ -60,10 +61,14 @@ func (l *LoggingInterceptor) RoundTrip(req *http.Request) (*http.Response, error
 		return nil, fmt.Errorf("request cannot be nil")
 	}
 
-	// Generate request ID if not present
+	// Generate request ID if not present, preferring the ID assigned by goclient
 	reqID := req.Header.Get("X-Request-ID")
 	if reqID == "" {
-		reqID = uuid.New().String()
+		if info, ok := goclient.RequestInfoFromContext(req.Context()); ok && info.ID != "" {
+			reqID = info.ID
+		} else {
+			reqID = uuid.New().String()
+		}
 		req.Header.Set("X-Request-ID", reqID)
 	}
 
@@ -83,6 +88,10 @@ func (l *LoggingInterceptor) RoundTrip(req *http.Request) (*http.Response, error
 		"url":        req.URL.String(),
 	}
 
+	if info, ok := goclient.RequestInfoFromContext(ctx); ok {
+		fields["route"] = info.Route
+	}
+
 	if l.LogHeaders {
 		fields["headers"] = headerToMap(req.Header)
 	}
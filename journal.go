@@ -0,0 +1,282 @@
+package goclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Journal is a write-ahead log of outgoing mutating requests (POST, PUT,
+// PATCH and DELETE). Each request is recorded before it is sent and marked
+// complete once any response arrives, so requests lost to a crash or a
+// network failure can be resent with Client.ReplayJournal after a restart.
+// This gives at-least-once delivery; servers should deduplicate, for example
+// with the Idempotency-Key header. Sensitive headers are not written to disk
+// and streamed bodies are not journaled.
+type Journal struct {
+	path   string
+	cipher *Cipher
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// JournalEntry is a request recorded in the journal
+type JournalEntry struct {
+	ID     string      `json:"id"`
+	Method string      `json:"method,omitempty"`
+	URL    string      `json:"url,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	Done   bool        `json:"done,omitempty"`
+}
+
+// OpenJournal opens or creates the journal file at path
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	return &Journal{path: path, file: file}, nil
+}
+
+// OpenEncryptedJournal opens or creates a journal whose records are
+// encrypted with c
+func OpenEncryptedJournal(path string, c *Cipher) (*Journal, error) {
+	j, err := OpenJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	j.cipher = c
+	return j, nil
+}
+
+// encode serializes entry as one journal line without the newline
+func (j *Journal) encode(entry JournalEntry) ([]byte, error) {
+	line, err := json.Marshal(entry)
+	if err != nil || j.cipher == nil {
+		return line, err
+	}
+	return base64.StdEncoding.AppendEncode(nil, j.cipher.Seal(line)), nil
+}
+
+// decode parses a journal line written by encode
+func (j *Journal) decode(line []byte) (JournalEntry, error) {
+	var entry JournalEntry
+	if j.cipher != nil {
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil {
+			return entry, ErrDecrypt
+		}
+		if line, err = j.cipher.Open(sealed); err != nil {
+			return entry, err
+		}
+	}
+	return entry, json.Unmarshal(line, &entry)
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// write appends a record and flushes it to stable storage
+func (j *Journal) write(entry JournalEntry) error {
+	line, err := j.encode(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+func (j *Journal) begin(id, method, url string, header http.Header, body []byte) error {
+	safe := make(http.Header, len(header))
+	for k, v := range header {
+		if !isSensitiveKey(k) {
+			safe[k] = v
+		}
+	}
+	return j.write(JournalEntry{ID: id, Method: method, URL: url, Header: safe, Body: body})
+}
+
+func (j *Journal) complete(id string) error {
+	return j.write(JournalEntry{ID: id, Done: true})
+}
+
+// Pending returns the recorded requests that never received a response, in
+// the order they were sent
+func (j *Journal) Pending() ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.pending()
+}
+
+func (j *Journal) pending() ([]JournalEntry, error) {
+	file, err := os.Open(j.path)
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	defer file.Close()
+
+	var order []string
+	entries := make(map[string]JournalEntry)
+
+	// A torn final line from a crash mid-write is skipped, but an earlier
+	// line that cannot be decrypted means the key is wrong
+	var torn error
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		if torn != nil {
+			return nil, fmt.Errorf("read journal: %w", torn)
+		}
+		entry, err := j.decode(scanner.Bytes())
+		if errors.Is(err, ErrDecrypt) {
+			torn = err
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if entry.Done {
+			delete(entries, entry.ID)
+			continue
+		}
+		if _, seen := entries[entry.ID]; !seen {
+			order = append(order, entry.ID)
+		}
+		entries[entry.ID] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+
+	pending := make([]JournalEntry, 0, len(entries))
+	for _, id := range order {
+		if entry, ok := entries[id]; ok {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, nil
+}
+
+// Compact rewrites the journal keeping only pending requests
+func (j *Journal) Compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pending, err := j.pending()
+	if err != nil {
+		return err
+	}
+
+	tmp := j.path + ".tmp"
+	if err := j.writeFile(tmp, pending); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("compact journal: %w", err)
+	}
+
+	// The journal is reopened whether or not the rename succeeds, so later
+	// requests are still recorded
+	if j.file != nil {
+		j.file.Close()
+	}
+	renameErr := os.Rename(tmp, j.path)
+	if renameErr != nil {
+		os.Remove(tmp)
+	}
+	j.file, err = os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if renameErr != nil {
+		return fmt.Errorf("compact journal: %w", renameErr)
+	}
+	return err
+}
+
+// writeFile writes entries to a new file at path and syncs it to disk
+func (j *Journal) writeFile(path string, entries []JournalEntry) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	for _, entry := range entries {
+		line, err := j.encode(entry)
+		if err == nil {
+			_, err = w.Write(append(line, '\n'))
+		}
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReplayJournal resends the journaled requests that never received a
+// response, then compacts the journal. Current authentication is applied to
+// the replayed requests. It returns the number of requests that received a
+// response; failures are returned as a *MultiError.
+func (c *client) ReplayJournal(ctx context.Context) (int, error) {
+	if c.journal == nil {
+		return 0, errors.New("goclient: no journal configured")
+	}
+
+	pending, err := c.journal.Pending()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	var errs []error
+	for _, entry := range pending {
+		r := c.newRequest(ctx, entry.Method, entry.URL)
+		r.headers = entry.Header
+		if entry.Body != nil {
+			r.body = entry.Body
+		}
+		r.journalID = entry.ID
+
+		_, err := r.Result()
+		var reqErr *RequestError
+		if err == nil || errors.As(err, &reqErr) {
+			replayed++
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("replay %s %s: %w", entry.Method, entry.URL, err))
+		}
+	}
+
+	if err := c.journal.Compact(); err != nil {
+		errs = append(errs, err)
+	}
+	return replayed, newMultiError(errs)
+}
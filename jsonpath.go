@@ -0,0 +1,171 @@
+package goclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrNoMatch is returned by Extract when a path without wildcards matches
+// nothing in the document
+var ErrNoMatch = errors.New("goclient: path matched nothing")
+
+// jsonPathStep is one segment of a parsed path
+type jsonPathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses the JSONPath subset supported by Extract: the root $,
+// child names as .name or ['name'], array indexes [n] (negative counts from
+// the end) and the wildcards .* and [*]
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", path)
+	}
+	rest := path[1:]
+
+	var steps []jsonPathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty name", path)
+			}
+			rest = rest[end:]
+			if name == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else {
+				steps = append(steps, jsonPathStep{key: name})
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed [", path)
+			}
+			sel := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case sel == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0]:
+				steps = append(steps, jsonPathStep{key: sel[1 : len(sel)-1]})
+			default:
+				n, err := strconv.Atoi(sel)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath %q: bad selector [%s]", path, sel)
+				}
+				steps = append(steps, jsonPathStep{index: n, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// evalJSONPath applies steps to doc and returns every match
+func evalJSONPath(doc interface{}, steps []jsonPathStep) []interface{} {
+	nodes := []interface{}{doc}
+	for _, step := range steps {
+		var next []interface{}
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					// Objects have no order; visit keys sorted for stable output
+					keys := make([]string, 0, len(n))
+					for k := range n {
+						keys = append(keys, k)
+					}
+					slices.Sort(keys)
+					for _, k := range keys {
+						next = append(next, n[k])
+					}
+				} else if v, ok := n[step.key]; ok && !step.isIndex {
+					next = append(next, v)
+				}
+			case []interface{}:
+				switch {
+				case step.wildcard:
+					next = append(next, n...)
+				case step.isIndex:
+					i := step.index
+					if i < 0 {
+						i += len(n)
+					}
+					if i >= 0 && i < len(n) {
+						next = append(next, n[i])
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// Extract decodes the part of a JSON body selected by a JSONPath expression
+// into v. Paths with a wildcard, such as "$.data.items[*].id", yield a JSON
+// array of every match; other paths yield the single matched value or
+// ErrNoMatch.
+func (r *Response) Extract(path string, v interface{}) error {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return err
+	}
+
+	body, err := toUTF8(r.Headers.Get("Content-Type"), r.Body)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	matches := evalJSONPath(doc, steps)
+	var selected interface{} = matches
+	wildcard := false
+	for _, step := range steps {
+		wildcard = wildcard || step.wildcard
+	}
+	if !wildcard {
+		if len(matches) == 0 {
+			return fmt.Errorf("%w: %s", ErrNoMatch, path)
+		}
+		selected = matches[0]
+	} else if matches == nil {
+		selected = []interface{}{}
+	}
+
+	data, err := json.Marshal(selected)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Extract executes the request and decodes the part of the response selected
+// by path into v (see Response.Extract)
+func (r *request) Extract(path string, v interface{}) error {
+	resp, err := r.Result()
+	if err != nil {
+		return r.decodeInto(resp, err, nil)
+	}
+	return resp.Extract(path, v)
+}
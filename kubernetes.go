@@ -0,0 +1,183 @@
+package goclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	defaultAPIServer  = "https://kubernetes.default.svc"
+)
+
+// KubernetesResolverConfig configures a KubernetesResolver
+type KubernetesResolverConfig struct {
+	// ClusterDomain is the cluster DNS suffix, default "cluster.local"
+	ClusterDomain string
+	// UseEndpoints resolves services to pod addresses through the Endpoints
+	// API instead of cluster DNS
+	UseEndpoints bool
+	// PortName selects the named endpoint port; by default the first port is used
+	PortName string
+	// IncludeNotReady also returns addresses of pods that are not ready
+	IncludeNotReady bool
+	// RefreshInterval is how long endpoints are cached, default 10s
+	RefreshInterval time.Duration
+	// Clock times the refresh interval, default the system clock
+	Clock Clock
+	// APIServer is the API server URL, default https://kubernetes.default.svc
+	APIServer string
+	// TokenFile and CAFile default to the pod's service account credentials
+	TokenFile string
+	CAFile    string
+}
+
+// KubernetesResolver resolves in-cluster service hosts such as
+// "service.namespace.svc" or "service.namespace.svc.cluster.local". Without
+// UseEndpoints it only qualifies the name for cluster DNS; with it the
+// request goes straight to a ready pod, which spreads load across pods even
+// over keep-alive connections. Hosts outside the cluster are left untouched.
+type KubernetesResolver struct {
+	cfg    KubernetesResolverConfig
+	client Client
+	cache  *resolverCache
+}
+
+type k8sEndpoints struct {
+	Subsets []struct {
+		Addresses         []k8sAddress `json:"addresses"`
+		NotReadyAddresses []k8sAddress `json:"notReadyAddresses"`
+		Ports             []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+type k8sAddress struct {
+	IP string `json:"ip"`
+}
+
+// NewKubernetesResolver returns a resolver for in-cluster services. The
+// service account credentials are only loaded when UseEndpoints is set.
+func NewKubernetesResolver(cfg KubernetesResolverConfig) (*KubernetesResolver, error) {
+	if cfg.ClusterDomain == "" {
+		cfg.ClusterDomain = "cluster.local"
+	}
+	r := &KubernetesResolver{cfg: cfg, cache: newResolverCache(cfg.RefreshInterval, cfg.Clock)}
+	if !cfg.UseEndpoints {
+		return r, nil
+	}
+
+	if cfg.APIServer == "" {
+		cfg.APIServer = defaultAPIServer
+	}
+	if cfg.TokenFile == "" {
+		cfg.TokenFile = serviceAccountDir + "/token"
+	}
+	if cfg.CAFile == "" {
+		cfg.CAFile = serviceAccountDir + "/ca.crt"
+	}
+
+	token, err := os.ReadFile(cfg.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("read service account token: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if ca, err := os.ReadFile(cfg.CAFile); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read service account CA: %w", err)
+	}
+
+	r.cfg = cfg
+	r.client = New(Config{
+		BaseURL:     strings.TrimRight(cfg.APIServer, "/"),
+		Timeout:     10 * time.Second,
+		Interceptor: transport,
+		Clock:       cfg.Clock,
+	}).SetBearerToken(strings.TrimSpace(string(token)))
+	return r, nil
+}
+
+// Resolve implements Resolver interface
+func (r *KubernetesResolver) Resolve(ctx context.Context, host string) ([]string, error) {
+	service, namespace, port, ok := r.parseHost(host)
+	if !ok {
+		return nil, nil
+	}
+
+	if !r.cfg.UseEndpoints {
+		addr := service + "." + namespace + ".svc." + r.cfg.ClusterDomain
+		if port != "" {
+			addr = net.JoinHostPort(addr, port)
+		}
+		return []string{addr}, nil
+	}
+
+	return r.cache.get(namespace+"/"+service, func() ([]string, error) {
+		return r.endpoints(ctx, namespace, service)
+	})
+}
+
+// parseHost splits "service.namespace.svc[.domain][:port]"
+func (r *KubernetesResolver) parseHost(host string) (service, namespace, port string, ok bool) {
+	name := host
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		name, port = h, p
+	}
+
+	name = strings.TrimSuffix(name, "."+r.cfg.ClusterDomain)
+	parts := strings.Split(name, ".")
+	if len(parts) != 3 || parts[2] != "svc" || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], port, true
+}
+
+// endpoints lists the pod addresses of a service from the Endpoints API
+func (r *KubernetesResolver) endpoints(ctx context.Context, namespace, service string) ([]string, error) {
+	var eps k8sEndpoints
+	err := r.client.GetWithContext(ctx, "/api/v1/namespaces/{namespace}/endpoints/{service}").
+		SetPathParam("namespace", namespace).
+		SetPathParam("service", service).
+		Into(&eps)
+	if err != nil {
+		return nil, fmt.Errorf("get endpoints %s/%s: %w", namespace, service, err)
+	}
+
+	var addrs []string
+	for _, subset := range eps.Subsets {
+		port := 0
+		for _, p := range subset.Ports {
+			if r.cfg.PortName == "" || p.Name == r.cfg.PortName {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+
+		candidates := subset.Addresses
+		if r.cfg.IncludeNotReady {
+			candidates = append(candidates, subset.NotReadyAddresses...)
+		}
+		for _, a := range candidates {
+			addrs = append(addrs, net.JoinHostPort(a.IP, strconv.Itoa(port)))
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,142 @@
+package goclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SetLocalAddr sends this request from the local IP address ip, for hosts
+// with several interfaces talking to IP-allowlisted APIs. It overrides
+// Config.LocalAddr. Connections from a local address are dialed with the
+// dial timeout and keep-alive of http.DefaultTransport; a custom DialContext
+// on the client's transport is not used for them.
+func (r *request) SetLocalAddr(ip string) RequestBuilder {
+	r.localAddr = ip
+	return r
+}
+
+// dialRouter sends requests through a transport bound to their source
+// address and, with a Resolver, to the address resolved for their host. Each
+// route gets its own clone of the base transport so pooled connections are
+// never shared between routes.
+type dialRouter struct {
+	base      *http.Transport
+	localAddr string
+
+	mu     sync.Mutex
+	routes map[dialRoute]http.RoundTripper
+}
+
+// dialRoute is where a request's connections come from and go to. target is
+// the host:port of the request URL and addr the address resolved for it,
+// both empty when no resolver picked an address.
+type dialRoute struct {
+	localAddr    string
+	target, addr string
+}
+
+// localAddrTransport wraps rt so requests can choose their source address
+// and be dialed to a resolved address. When addr is set it is the default
+// source for all requests. Only *http.Transport can be rebound; with any
+// other transport requests asking for a source address fail and resolved
+// addresses are written into the request URL.
+func localAddrTransport(rt http.RoundTripper, addr string) http.RoundTripper {
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if info, _ := RequestInfoFromContext(req.Context()); addr != "" || info.LocalAddr != "" {
+				return nil, errors.New("goclient: a local address requires an *http.Transport")
+			}
+			return rt.RoundTrip(resolvedURL(req))
+		})
+	}
+
+	return &dialRouter{base: t, localAddr: addr, routes: make(map[dialRoute]http.RoundTripper)}
+}
+
+func (l *dialRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	info, _ := RequestInfoFromContext(req.Context())
+	route := dialRoute{localAddr: info.LocalAddr}
+	if route.localAddr == "" {
+		route.localAddr = l.localAddr
+	}
+	if addr, ok := resolvedAddrFromContext(req.Context()); ok {
+		route.target, route.addr = hostPort(req.URL), addr
+	}
+	if route == (dialRoute{}) {
+		return l.base.RoundTrip(req)
+	}
+
+	l.mu.Lock()
+	rt, ok := l.routes[route]
+	if !ok {
+		rt = l.bind(route)
+		l.routes[route] = rt
+	}
+	l.mu.Unlock()
+	return rt.RoundTrip(req)
+}
+
+// localAddrDialer mirrors the dialer of http.DefaultTransport. A DialContext
+// function cannot be rebound to another source address, so bound transports
+// replace the base transport's dialer with a copy of this one.
+var localAddrDialer = net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+// bind returns a transport dialing along route. Connections to the request's
+// host go to the resolved address while TLS still verifies the host name;
+// connections to a proxy are left alone.
+func (l *dialRouter) bind(route dialRoute) http.RoundTripper {
+	t := l.base.Clone()
+	dial := t.DialContext
+	if route.localAddr != "" {
+		ip := net.ParseIP(route.localAddr)
+		if ip == nil {
+			return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, fmt.Errorf("goclient: invalid local address %q", route.localAddr)
+			})
+		}
+		dialer := localAddrDialer
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		dial = dialer.DialContext
+	}
+	if dial == nil {
+		dial = localAddrDialer.DialContext
+	}
+
+	if route.addr != "" {
+		addr := route.addr
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			_, port, _ := net.SplitHostPort(route.target)
+			addr = net.JoinHostPort(addr, port)
+		}
+		next := dial
+		dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if address == route.target {
+				address = addr
+			}
+			return next(ctx, network, address)
+		}
+	}
+	t.DialContext = dial
+	return t
+}
+
+// CloseIdleConnections closes idle connections of every bound transport
+func (l *dialRouter) CloseIdleConnections() {
+	l.base.CloseIdleConnections()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rt := range l.routes {
+		if t, ok := rt.(*http.Transport); ok {
+			t.CloseIdleConnections()
+		}
+	}
+}
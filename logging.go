@@ -0,0 +1,203 @@
+package goclient
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LoggingOptions controls what debug logging records. Sensitive headers and
+// query parameters, such as Authorization, cookies and tokens, are always
+// redacted.
+type LoggingOptions struct {
+	// LogHeaders records request and response headers
+	LogHeaders bool
+	// LogRequestBody and LogResponseBody record bodies, truncated to
+	// MaxBodySize bytes
+	LogRequestBody  bool
+	LogResponseBody bool
+	// MaxBodySize is the longest body logged, default 1000 bytes
+	MaxBodySize int
+	// RedactKeys names further headers and query parameters whose values
+	// are redacted, compared case-insensitively
+	RedactKeys []string
+}
+
+// defaultLoggingOptions log everything with bodies truncated to 1000 bytes
+var defaultLoggingOptions = LoggingOptions{
+	LogHeaders:      true,
+	LogRequestBody:  true,
+	LogResponseBody: true,
+	MaxBodySize:     1000,
+}
+
+// EnableDebug logs every request and response, with the default logger
+// unless one is set. It is safe to call while requests are in flight.
+func (c *client) EnableDebug() Client {
+	c.logger.CompareAndSwap(nil, loggerRef(NewDefaultLogger()))
+	c.debugEnabled.Store(true)
+	return c
+}
+
+// DisableDebug stops request and response logging
+func (c *client) DisableDebug() Client {
+	c.debugEnabled.Store(false)
+	return c
+}
+
+// SetLogger sets the logger used for debug logging and warnings. A nil
+// logger silences the client.
+func (c *client) SetLogger(logger Logger) Client {
+	if logger == nil {
+		c.logger.Store(nil)
+	} else {
+		c.logger.Store(loggerRef(logger))
+	}
+	return c
+}
+
+// SetLoggingOptions sets what debug logging records
+func (c *client) SetLoggingOptions(opts LoggingOptions) Client {
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = defaultLoggingOptions.MaxBodySize
+	}
+	opts.RedactKeys = append([]string(nil), opts.RedactKeys...)
+	c.logOpts.Store(&opts)
+	return c
+}
+
+func loggerRef(logger Logger) *Logger {
+	return &logger
+}
+
+// currentLogger returns the client's logger, or nil
+func (c *client) currentLogger() Logger {
+	if l := c.logger.Load(); l != nil {
+		return *l
+	}
+	return nil
+}
+
+// debugLogger returns the logger when debug logging is enabled
+func (c *client) debugLogger() Logger {
+	if !c.debugEnabled.Load() {
+		return nil
+	}
+	return c.currentLogger()
+}
+
+func (c *client) loggingOptions() *LoggingOptions {
+	if opts := c.logOpts.Load(); opts != nil {
+		return opts
+	}
+	return &defaultLoggingOptions
+}
+
+// redacts reports whether the value of key is hidden from logs
+func (o *LoggingOptions) redacts(key string) bool {
+	if isSensitiveKey(key) {
+		return true
+	}
+	for _, k := range o.RedactKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// headers renders h with redacted values
+func (o *LoggingOptions) headers(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if o.redacts(k) {
+			out[k] = redacted
+		} else {
+			out[k] = strings.Join(v, ", ")
+		}
+	}
+	return out
+}
+
+// query returns the query parameters of u with redacted values
+func (o *LoggingOptions) query(u *url.URL) url.Values {
+	q := u.Query()
+	for k := range q {
+		if o.redacts(k) {
+			q[k] = []string{redacted}
+		}
+	}
+	return q
+}
+
+// body renders b, truncated to MaxBodySize bytes on a rune boundary
+func (o *LoggingOptions) body(b []byte) string {
+	if len(b) <= o.MaxBodySize {
+		return string(b)
+	}
+	cut := o.MaxBodySize
+	for cut > 0 && !utf8.RuneStart(b[cut]) {
+		cut--
+	}
+	return string(b[:cut]) + "... [truncated]"
+}
+
+func (r *request) logRequest(logger Logger, req *http.Request, bodyReader io.Reader) {
+	opts := r.client.loggingOptions()
+
+	logged := *req.URL
+	fields := map[string]interface{}{
+		"method": req.Method,
+		"route":  r.endpoint,
+	}
+
+	if req.URL.RawQuery != "" {
+		q := opts.query(req.URL)
+		logged.RawQuery = q.Encode()
+		fields["query_params"] = q
+	}
+	fields["url"] = logged.String()
+
+	if opts.LogHeaders && len(req.Header) > 0 {
+		fields["headers"] = opts.headers(req.Header)
+	}
+
+	if opts.LogRequestBody && bodyReader != nil {
+		if bodyBytes, ok := r.body.([]byte); ok && len(bodyBytes) > 0 {
+			fields["body"] = opts.body(bodyBytes)
+		} else if r.body != nil {
+			fields["body"] = "[non-string body]"
+		}
+	}
+
+	logger.Log(LogLevelInfo, "HTTP Request", fields)
+}
+
+func (r *request) logResponse(logger Logger, resp *http.Response, body []byte, duration time.Duration) {
+	opts := r.client.loggingOptions()
+
+	fields := map[string]interface{}{
+		"status_code": resp.StatusCode,
+		"status":      resp.Status,
+		"duration_ms": duration.Milliseconds(),
+	}
+
+	if opts.LogHeaders && len(resp.Header) > 0 {
+		fields["response_headers"] = opts.headers(resp.Header)
+	}
+	if opts.LogResponseBody && len(body) > 0 {
+		fields["response_body"] = opts.body(body)
+	}
+	if resp.ContentLength >= 0 {
+		fields["content_length"] = resp.ContentLength
+	}
+
+	logLevel := LogLevelInfo
+	if resp.StatusCode >= 400 {
+		logLevel = LogLevelError
+	}
+	logger.Log(logLevel, "HTTP Response", fields)
+}
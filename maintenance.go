@@ -0,0 +1,182 @@
+package goclient
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrMaintenanceQueueFull is returned instead of parking a request when the
+// host's maintenance queue is full
+var ErrMaintenanceQueueFull = errors.New("maintenance queue full")
+
+// MaintenanceConfig enables maintenance windows: once a host answers 503
+// with a Retry-After header, new requests to it are parked until the window
+// passes instead of failing, up to QueueSize at a time
+type MaintenanceConfig struct {
+	// Threshold is the number of consecutive 503 responses with Retry-After
+	// that open a window, default 1
+	Threshold int
+	// QueueSize bounds the requests parked per host; further requests are
+	// shed with ErrMaintenanceQueueFull. Default 100.
+	QueueSize int
+	// MaxWindow caps the length of a window, default 10m
+	MaxWindow time.Duration
+}
+
+// MaintenanceState reports the maintenance window of one host
+type MaintenanceState struct {
+	Host string
+	// Until is when the current window ends, zero when the host is available
+	Until time.Time
+	// Queued is the number of requests parked right now
+	Queued int
+	// Windows counts the maintenance windows opened so far
+	Windows int
+	// Parked and Shed count the requests that were parked or rejected
+	Parked int
+	Shed   int
+}
+
+type hostMaintenance struct {
+	state       MaintenanceState
+	consecutive int
+}
+
+type maintenance struct {
+	cfg    MaintenanceConfig
+	clock  Clock
+	policy retryPolicy
+
+	mu    sync.Mutex
+	hosts map[string]*hostMaintenance
+}
+
+func newMaintenance(cfg MaintenanceConfig, clock Clock) *maintenance {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 100
+	}
+	if cfg.MaxWindow <= 0 {
+		cfg.MaxWindow = 10 * time.Minute
+	}
+	return &maintenance{
+		cfg:    cfg,
+		clock:  clock,
+		policy: retryPolicy{maxAfter: cfg.MaxWindow},
+		hosts:  make(map[string]*hostMaintenance),
+	}
+}
+
+func (m *maintenance) host(name string) *hostMaintenance {
+	h, ok := m.hosts[name]
+	if !ok {
+		h = &hostMaintenance{state: MaintenanceState{Host: name}}
+		m.hosts[name] = h
+	}
+	return h
+}
+
+// park returns how long a request to host must wait, or
+// ErrMaintenanceQueueFull. A parked request must call unpark when it wakes.
+func (m *maintenance) park(name string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.host(name)
+	wait := h.state.Until.Sub(m.clock.Now())
+	if wait <= 0 {
+		return 0, nil
+	}
+	if h.state.Queued >= m.cfg.QueueSize {
+		h.state.Shed++
+		return 0, ErrMaintenanceQueueFull
+	}
+	h.state.Queued++
+	h.state.Parked++
+	return wait, nil
+}
+
+func (m *maintenance) unpark(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.host(name).state.Queued--
+}
+
+// observe opens or closes the host's window after a response
+func (m *maintenance) observe(name string, resp *http.Response) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.host(name)
+	now := m.clock.Now()
+
+	wait, ok := m.policy.retryAfter(resp, now)
+	if !ok || resp.StatusCode != http.StatusServiceUnavailable {
+		h.consecutive = 0
+		h.state.Until = time.Time{}
+		return
+	}
+
+	h.consecutive++
+	if h.consecutive < m.cfg.Threshold {
+		return
+	}
+	if !h.state.Until.After(now) {
+		h.state.Windows++
+	}
+	h.state.Until = now.Add(wait)
+}
+
+func (m *maintenance) stats() map[string]MaintenanceState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make(map[string]MaintenanceState, len(m.hosts))
+	for name, h := range m.hosts {
+		stats[name] = h.state
+	}
+	return stats
+}
+
+// wait parks req while its host is in a maintenance window. It runs before
+// the request is handed to the http.Client so that the wait, which may last
+// up to MaxWindow, is not cut short by Config.Timeout.
+func (m *maintenance) wait(req *http.Request) error {
+	host := req.URL.Host
+	// A window may be extended while requests are parked
+	for {
+		wait, err := m.park(host)
+		if err != nil || wait == 0 {
+			return err
+		}
+		err = m.clock.Sleep(req.Context(), wait)
+		m.unpark(host)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// middleware opens and closes windows from the responses it sees
+func (m *maintenance) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err == nil {
+			m.observe(req.URL.Host, resp)
+		}
+		return resp, err
+	})
+}
+
+// MaintenanceStats returns the maintenance state of every host contacted so
+// far, or nil when Config.Maintenance is not set
+func (c *client) MaintenanceStats() map[string]MaintenanceState {
+	if c.maintenance == nil {
+		return nil
+	}
+	return c.maintenance.stats()
+}
@@ -0,0 +1,103 @@
+package goclient
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps an http.RoundTripper with additional behaviour
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts an ordinary function to the http.RoundTripper interface
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper interface
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Use appends middlewares to the client's transport chain. Middlewares run in
+// the order they were added, after those from Config.Middlewares, and wrap
+// the Interceptor. Use is meant for setup and must not race with requests.
+func (c *client) Use(mw ...Middleware) Client {
+	c.middlewares = append(c.middlewares, mw...)
+	c.httpClient.Transport = chainMiddleware(c.transport, c.middlewares...)
+	return c
+}
+
+// chainMiddleware wraps rt with the given middlewares. The first middleware
+// is the outermost one and therefore sees the request first.
+func chainMiddleware(rt http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			rt = middlewares[i](rt)
+		}
+	}
+	return rt
+}
+
+// RequestMatcher selects the requests a conditional middleware applies to
+type RequestMatcher func(req *http.Request) bool
+
+// When applies mw only to requests accepted by every matcher, so one client
+// talking to many upstreams can, for example, sign only some of them:
+//
+//	client.Use(goclient.When(signing, goclient.ForHost("api.github.com"), goclient.ForPathPrefix("/v2/")))
+func When(mw Middleware, matchers ...RequestMatcher) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		wrapped := mw(next)
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for _, match := range matchers {
+				if !match(req) {
+					return next.RoundTrip(req)
+				}
+			}
+			return wrapped.RoundTrip(req)
+		})
+	}
+}
+
+// ForHost matches requests to any of hosts, ignoring case and port. A host
+// of the form "*.example.com" matches every subdomain of example.com.
+func ForHost(hosts ...string) RequestMatcher {
+	return func(req *http.Request) bool {
+		host := strings.ToLower(req.URL.Hostname())
+		for _, h := range hosts {
+			h = strings.ToLower(h)
+			if suffix, ok := strings.CutPrefix(h, "*"); ok && strings.HasSuffix(host, suffix) {
+				return true
+			}
+			if host == h {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ForPathPrefix matches requests whose path starts with any of prefixes
+func ForPathPrefix(prefixes ...string) RequestMatcher {
+	return func(req *http.Request) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(req.URL.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ForMethod matches requests using any of methods
+func ForMethod(methods ...string) RequestMatcher {
+	return func(req *http.Request) bool {
+		for _, method := range methods {
+			if strings.EqualFold(req.Method, method) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,44 @@
+package goclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MultiError aggregates the failures of an operation that issues several
+// requests, such as a batch, a drained pool or a crawl. errors.Is and
+// errors.As inspect every wrapped error.
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d requests failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the aggregated errors
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// newMultiError returns nil when errs holds no failures
+func newMultiError(errs []error) error {
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &MultiError{Errors: failed}
+}
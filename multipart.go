@@ -0,0 +1,81 @@
+package goclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// ErrNotMultipart is returned when a response is not a multipart document
+var ErrNotMultipart = errors.New("response is not multipart")
+
+// Part is a single part of a multipart/mixed or multipart/byteranges response
+type Part struct {
+	Header textproto.MIMEHeader
+	// Body is only valid until the next call to PartReader.Next
+	Body io.Reader
+}
+
+// ContentType returns the part's Content-Type header
+func (p *Part) ContentType() string {
+	return p.Header.Get("Content-Type")
+}
+
+// ContentRange parses the part's Content-Range header, as sent in
+// multipart/byteranges responses. Total is -1 when the server reports it as unknown.
+func (p *Part) ContentRange() (start, end, total int64, err error) {
+	value := p.Header.Get("Content-Range")
+	if value == "" {
+		return 0, 0, 0, errors.New("part has no Content-Range header")
+	}
+
+	var totalStr string
+	if _, err := fmt.Sscanf(strings.Replace(value, "/", " ", 1), "bytes %d-%d %s", &start, &end, &totalStr); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q: %w", value, err)
+	}
+	if totalStr == "*" {
+		return start, end, -1, nil
+	}
+	if _, err := fmt.Sscanf(totalStr, "%d", &total); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q: %w", value, err)
+	}
+	return start, end, total, nil
+}
+
+// PartReader iterates over the parts of a multipart response
+type PartReader struct {
+	mr *multipart.Reader
+}
+
+// NewPartReader creates a PartReader for body, which must have the given
+// multipart Content-Type. Parts are read lazily from body.
+func NewPartReader(contentType string, body io.Reader) (*PartReader, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, ErrNotMultipart
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, fmt.Errorf("%w: missing boundary", ErrNotMultipart)
+	}
+	return &PartReader{mr: multipart.NewReader(body, boundary)}, nil
+}
+
+// Next returns the next part, or io.EOF when there are no more parts
+func (p *PartReader) Next() (*Part, error) {
+	part, err := p.mr.NextRawPart()
+	if err != nil {
+		return nil, err
+	}
+	return &Part{Header: part.Header, Body: part}, nil
+}
+
+// Parts returns a PartReader over a multipart/mixed or multipart/byteranges response
+func (r *Response) Parts() (*PartReader, error) {
+	return NewPartReader(r.Headers.Get("Content-Type"), bytes.NewReader(r.Body))
+}
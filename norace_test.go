@@ -0,0 +1,5 @@
+//go:build !race
+
+package goclient
+
+const raceEnabled = false
@@ -0,0 +1,167 @@
+package goclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshSkew is how long before expiry a cached token is replaced
+const tokenRefreshSkew = 30 * time.Second
+
+// clientCredentials fetches and caches OAuth2 tokens with the client
+// credentials grant (RFC 6749 section 4.4). Concurrent callers needing a new
+// token share a single request to the token endpoint.
+type clientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	httpClient   *http.Client
+	clock        Clock
+
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	inflight *tokenCall
+}
+
+// tokenCall is an in-flight token request that other callers wait on
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// WithOAuth2ClientCredentials returns a copy of the client that authenticates
+// every request with a bearer token obtained from tokenURL using the client
+// credentials grant, leaving c unchanged. The token is cached and refreshed
+// shortly before it expires.
+func (c *client) WithOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) Client {
+	clone := c.clone()
+	clone.tokenProvider = &clientCredentials{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		httpClient:   &http.Client{Timeout: c.httpClient.Timeout, Transport: c.transport},
+		clock:        c.clock,
+	}
+	return clone
+}
+
+// Token implements TokenProvider interface. It returns a valid access
+// token, fetching a new one when needed.
+func (s *clientCredentials) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	if s.token != "" && s.clock.Now().Before(s.expiry) {
+		token := s.token
+		s.mu.Unlock()
+		return token, nil
+	}
+
+	call := s.inflight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		s.inflight = call
+		go s.fetch(context.WithoutCancel(ctx), call)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// RefreshToken implements TokenRefresher interface. It discards the cached
+// token if it is the rejected one and fetches a new one; a token already
+// replaced by a concurrent refresh is kept.
+func (s *clientCredentials) RefreshToken(ctx context.Context, rejected string) error {
+	s.mu.Lock()
+	if s.token == rejected {
+		s.token = ""
+	}
+	s.mu.Unlock()
+
+	_, err := s.Token(ctx)
+	return err
+}
+
+// fetch requests a token and publishes the result to the waiting callers.
+// ctx carries the values of the request that needed the token but not its
+// cancellation, so that one canceled request does not fail the others.
+func (s *clientCredentials) fetch(ctx context.Context, call *tokenCall) {
+	token, lifetime, err := s.request(ctx)
+
+	s.mu.Lock()
+	if err == nil {
+		s.token = token
+		s.expiry = s.clock.Now().Add(lifetime - tokenRefreshSkew)
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+
+	call.token, call.err = token, err
+	close(call.done)
+}
+
+func (s *clientCredentials) request(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("oauth2: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("oauth2: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, fmt.Errorf("oauth2: reading token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("oauth2: token endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	var tok tokenResponse
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return "", 0, fmt.Errorf("oauth2: decoding token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", 0, fmt.Errorf("oauth2: token response has no access_token")
+	}
+
+	lifetime := time.Duration(tok.ExpiresIn) * time.Second
+	if tok.ExpiresIn <= 0 {
+		lifetime = time.Hour
+	}
+	if lifetime <= tokenRefreshSkew {
+		lifetime = tokenRefreshSkew + lifetime/2
+	}
+	return tok.AccessToken, lifetime, nil
+}
@@ -0,0 +1,320 @@
+package goclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutboxConfig configures an Outbox
+type OutboxConfig struct {
+	// Dir holds one file per queued request
+	Dir string
+	// ShouldEnqueue decides whether a failed mutating request is queued. The
+	// response is nil for transport errors. By default transport errors,
+	// 429 and 5xx responses are queued.
+	ShouldEnqueue func(resp *Response, err error) bool
+	// TTL is how long a request is retried before it is dropped, default 24h
+	TTL time.Duration
+	// RetryWaitMin and RetryWaitMax bound the backoff between attempts, default 1s and 5m
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	// OnDrop is called when a request expires without succeeding
+	OnDrop func(entry OutboxEntry)
+	// Cipher, if set, encrypts queued requests on disk
+	Cipher *Cipher
+}
+
+// OutboxEntry is a request waiting in the outbox
+type OutboxEntry struct {
+	ID          string      `json:"id"`
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	Created     time.Time   `json:"created"`
+	Attempts    int         `json:"attempts"`
+	NextAttempt time.Time   `json:"next_attempt"`
+}
+
+// Outbox is a disk-backed queue of failed mutating requests for agents with
+// intermittent connectivity. A client configured with an Outbox queues
+// requests that fail according to ShouldEnqueue and resends them in the
+// background with exponential backoff until they succeed or expire. The
+// queue survives restarts. The caller of the original request still gets
+// its error. Sensitive headers are not written to disk; current
+// authentication is applied when a request is resent.
+type Outbox struct {
+	cfg    OutboxConfig
+	policy retryPolicy
+
+	mu      sync.Mutex
+	wake    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+	running bool
+}
+
+// OpenOutbox creates the outbox directory if needed
+func OpenOutbox(cfg OutboxConfig) (*Outbox, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.RetryWaitMin <= 0 {
+		cfg.RetryWaitMin = time.Second
+	}
+	if cfg.RetryWaitMax <= 0 {
+		cfg.RetryWaitMax = 5 * time.Minute
+	}
+	if cfg.ShouldEnqueue == nil {
+		cfg.ShouldEnqueue = func(resp *Response, err error) bool {
+			return resp == nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		}
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("open outbox: %w", err)
+	}
+
+	return &Outbox{
+		cfg:    cfg,
+		policy: retryPolicy{waitMin: cfg.RetryWaitMin, waitMax: cfg.RetryWaitMax},
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+// Len returns the number of queued requests
+func (o *Outbox) Len() int {
+	entries, _ := o.entries()
+	return len(entries)
+}
+
+// Close stops the background worker. Queued requests stay on disk.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.running {
+		return nil
+	}
+	o.running = false
+	close(o.stop)
+	<-o.stopped
+	return nil
+}
+
+func (o *Outbox) path(id string) string {
+	return filepath.Join(o.cfg.Dir, id+o.ext())
+}
+
+// ext is the file extension of queued requests, which differs for
+// encrypted entries
+func (o *Outbox) ext() string {
+	if o.cfg.Cipher != nil {
+		return ".sealed"
+	}
+	return ".json"
+}
+
+// save writes entry atomically
+func (o *Outbox) save(entry OutboxEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if o.cfg.Cipher != nil {
+		data = o.cfg.Cipher.Seal(data)
+	}
+	tmp := o.path(entry.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path(entry.ID))
+}
+
+func (o *Outbox) remove(id string) {
+	os.Remove(o.path(id))
+}
+
+// entries loads every queued request ordered by creation time
+func (o *Outbox) entries() ([]OutboxEntry, error) {
+	files, err := os.ReadDir(o.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []OutboxEntry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), o.ext()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(o.cfg.Dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		if o.cfg.Cipher != nil {
+			if data, err = o.cfg.Cipher.Open(data); err != nil {
+				return nil, fmt.Errorf("outbox %s: %w", f.Name(), err)
+			}
+		}
+		var entry OutboxEntry
+		if json.Unmarshal(data, &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Created.Before(entries[j].Created) })
+	return entries, nil
+}
+
+// enqueue queues a failed request and wakes the worker
+func (o *Outbox) enqueue(c *client, method, url string, header http.Header, body []byte) error {
+	safe := make(http.Header, len(header))
+	for k, v := range header {
+		if !isSensitiveKey(k) {
+			safe[k] = v
+		}
+	}
+
+	now := c.clock.Now()
+	entry := OutboxEntry{
+		ID:          c.newID(),
+		Method:      method,
+		URL:         url,
+		Header:      safe,
+		Body:        body,
+		Created:     now,
+		Attempts:    1,
+		NextAttempt: now.Add(o.policy.backoff(1, c.rand)),
+	}
+	if err := o.save(entry); err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// start launches the background worker for client c once
+func (o *Outbox) start(c *client) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.running {
+		return
+	}
+	o.running = true
+	o.stopped = make(chan struct{})
+	go o.run(c)
+}
+
+func (o *Outbox) run(c *client) {
+	defer close(o.stopped)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-o.stop
+		cancel()
+	}()
+
+	for {
+		wait := o.process(ctx, c)
+
+		sleepCtx, stopSleep := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-o.wake:
+				stopSleep()
+			case <-sleepCtx.Done():
+			}
+		}()
+		err := c.clock.Sleep(sleepCtx, wait)
+		stopSleep()
+
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return
+		}
+	}
+}
+
+// process resends the due requests and returns how long to wait for the next one
+func (o *Outbox) process(ctx context.Context, c *client) time.Duration {
+	wait := o.cfg.RetryWaitMax
+
+	entries, err := o.entries()
+	if err != nil {
+		return wait
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return wait
+		}
+
+		now := c.clock.Now()
+		if now.Sub(entry.Created) > o.cfg.TTL {
+			o.remove(entry.ID)
+			if o.cfg.OnDrop != nil {
+				o.cfg.OnDrop(entry)
+			}
+			continue
+		}
+		if now.Before(entry.NextAttempt) {
+			if d := entry.NextAttempt.Sub(now); d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		r := c.newRequest(ctx, entry.Method, entry.URL)
+		r.headers = entry.Header
+		if entry.Body != nil {
+			r.body = entry.Body
+		}
+		r.fromOutbox = true
+
+		resp, err := r.Result()
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) {
+			resp = &Response{StatusCode: reqErr.StatusCode, Body: reqErr.Response}
+		}
+		if err == nil || !o.cfg.ShouldEnqueue(resp, err) {
+			o.remove(entry.ID)
+			continue
+		}
+
+		entry.Attempts++
+		delay := o.policy.backoff(entry.Attempts, c.rand)
+		entry.NextAttempt = c.clock.Now().Add(delay)
+		o.save(entry)
+		if delay < wait {
+			wait = delay
+		}
+	}
+	return wait
+}
+
+// queueInOutbox queues a failed mutating request and returns err, joined
+// with the queueing failure if any
+func (r *request) queueInOutbox(req *http.Request, body []byte, resp *Response, err error) error {
+	o := r.client.outbox
+	if o == nil || r.fromOutbox || r.stream || !isInvalidatingMethod(r.method) || !o.cfg.ShouldEnqueue(resp, err) {
+		return err
+	}
+	if qerr := o.enqueue(r.client, r.method, req.URL.String(), req.Header, body); qerr != nil {
+		return newMultiError([]error{err, qerr})
+	}
+	return err
+}
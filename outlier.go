@@ -0,0 +1,162 @@
+package goclient
+
+import (
+	"sync"
+	"time"
+)
+
+// OutlierDetectionConfig enables passive health checking of the addresses
+// returned by a Resolver. An address that fails repeatedly is ejected from
+// rotation for a while, and once the ejection ends it is reintroduced
+// gradually.
+type OutlierDetectionConfig struct {
+	// ConsecutiveFailures ejects an address after this many failures in a row, default 5.
+	// Transport errors and 5xx responses count as failures.
+	ConsecutiveFailures int
+	// SlowThreshold, when set, also counts responses slower than it as failures
+	SlowThreshold time.Duration
+	// BaseEjectionTime is multiplied by the number of times an address was ejected, default 30s
+	BaseEjectionTime time.Duration
+	// MaxEjectionPercent caps the share of a host's addresses ejected at once, default 50
+	MaxEjectionPercent int
+	// RampUp is how long a returning address takes to get its full share of traffic, default 30s
+	RampUp time.Duration
+	// OnEvent is called when an address is ejected or restored
+	OnEvent func(OutlierEvent)
+}
+
+// OutlierEvent reports an address being ejected or restored
+type OutlierEvent struct {
+	Host    string
+	Addr    string
+	Ejected bool
+	// Until is the end of the ejection for ejected addresses
+	Until time.Time
+}
+
+type outlierState struct {
+	host         string
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+	ejected      bool
+}
+
+// outlierDetector tracks the health of resolved addresses
+type outlierDetector struct {
+	cfg   OutlierDetectionConfig
+	clock Clock
+	rand  RandSource
+
+	mu    sync.Mutex
+	addrs map[string]*outlierState
+}
+
+func newOutlierDetector(cfg OutlierDetectionConfig, clock Clock, rand RandSource) *outlierDetector {
+	if cfg.ConsecutiveFailures <= 0 {
+		cfg.ConsecutiveFailures = 5
+	}
+	if cfg.BaseEjectionTime <= 0 {
+		cfg.BaseEjectionTime = 30 * time.Second
+	}
+	if cfg.MaxEjectionPercent <= 0 {
+		cfg.MaxEjectionPercent = 50
+	}
+	if cfg.RampUp <= 0 {
+		cfg.RampUp = 30 * time.Second
+	}
+	return &outlierDetector{cfg: cfg, clock: clock, rand: rand, addrs: make(map[string]*outlierState)}
+}
+
+// filter returns the addresses that may receive the next request. Ejected
+// addresses are left out and recently restored ones are kept with a
+// probability growing linearly over RampUp. It never returns an empty list.
+func (d *outlierDetector) filter(host string, addrs []string) []string {
+	if d == nil {
+		return addrs
+	}
+
+	var events []OutlierEvent
+	available := make([]string, 0, len(addrs))
+
+	d.mu.Lock()
+	now := d.clock.Now()
+	for _, addr := range addrs {
+		s, ok := d.addrs[addr]
+		if !ok {
+			available = append(available, addr)
+			continue
+		}
+		if s.ejected {
+			if now.Before(s.ejectedUntil) {
+				continue
+			}
+			s.ejected = false
+			s.failures = 0
+			events = append(events, OutlierEvent{Host: host, Addr: addr})
+		}
+		if ramp := now.Sub(s.ejectedUntil); s.ejections > 0 && ramp < d.cfg.RampUp {
+			if d.rand.Float64() >= float64(ramp)/float64(d.cfg.RampUp) {
+				continue
+			}
+		}
+		available = append(available, addr)
+	}
+	d.mu.Unlock()
+
+	d.notify(events)
+	if len(available) == 0 {
+		return addrs
+	}
+	return available
+}
+
+// record feeds the outcome of a request to addr, one of total addresses of host
+func (d *outlierDetector) record(host, addr string, total int, failed bool) {
+	if d == nil {
+		return
+	}
+
+	var events []OutlierEvent
+
+	d.mu.Lock()
+	s, ok := d.addrs[addr]
+	if !ok {
+		s = &outlierState{host: host}
+		d.addrs[addr] = s
+	}
+
+	if !failed {
+		s.failures = 0
+	} else if s.failures++; s.failures >= d.cfg.ConsecutiveFailures && !s.ejected && d.canEject(host, total) {
+		s.ejections++
+		s.ejected = true
+		s.failures = 0
+		s.ejectedUntil = d.clock.Now().Add(d.cfg.BaseEjectionTime * time.Duration(s.ejections))
+		events = append(events, OutlierEvent{Host: host, Addr: addr, Ejected: true, Until: s.ejectedUntil})
+	}
+	d.mu.Unlock()
+
+	d.notify(events)
+}
+
+// canEject reports whether one more address of host may be ejected. Must be
+// called with d.mu held.
+func (d *outlierDetector) canEject(host string, total int) bool {
+	ejected := 0
+	for _, s := range d.addrs {
+		if s.host == host && s.ejected {
+			ejected++
+		}
+	}
+	return (ejected+1)*100 <= total*d.cfg.MaxEjectionPercent
+}
+
+func (d *outlierDetector) notify(events []OutlierEvent) {
+	if d.cfg.OnEvent == nil {
+		return
+	}
+	for _, e := range events {
+		d.cfg.OnEvent(e)
+	}
+}
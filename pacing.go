@@ -0,0 +1,110 @@
+package goclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PacingConfig enables adaptive pacing: the client spaces out requests while
+// the observed upstream latency is above TargetLatency and speeds back up as
+// it recovers
+type PacingConfig struct {
+	// TargetLatency is the latency above which submissions slow down
+	TargetLatency time.Duration
+	// MinInterval is the smallest gap between requests, default 0
+	MinInterval time.Duration
+	// MaxInterval is the largest gap between requests, default 5s
+	MaxInterval time.Duration
+}
+
+// pacer implements adaptive pacing with an exponentially weighted moving
+// average of latency and a multiplicative increase/decrease of the interval
+type pacer struct {
+	cfg   PacingConfig
+	clock Clock
+
+	mu       sync.Mutex
+	ewma     time.Duration
+	interval time.Duration
+	next     time.Time
+}
+
+const pacingSmoothing = 0.2
+
+func newPacer(cfg PacingConfig, clock Clock) *pacer {
+	if cfg.MaxInterval <= 0 {
+		cfg.MaxInterval = 5 * time.Second
+	}
+	return &pacer{cfg: cfg, clock: clock, interval: cfg.MinInterval}
+}
+
+// reserve books the next send slot and returns how long to wait for it
+func (p *pacer) reserve() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	start := p.next
+	if start.Before(now) {
+		start = now
+	}
+	p.next = start.Add(p.interval)
+	return start.Sub(now)
+}
+
+// observe feeds a latency sample and adjusts the interval
+func (p *pacer) observe(latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ewma == 0 {
+		p.ewma = latency
+	} else {
+		p.ewma = time.Duration(pacingSmoothing*float64(latency) + (1-pacingSmoothing)*float64(p.ewma))
+	}
+
+	// Intervals below one step are not worth enforcing
+	step := p.cfg.TargetLatency / 10
+	if step <= 0 {
+		step = time.Millisecond
+	}
+
+	if p.ewma > p.cfg.TargetLatency {
+		p.interval *= 2
+		if p.interval < step {
+			p.interval = step
+		}
+		if p.interval > p.cfg.MaxInterval {
+			p.interval = p.cfg.MaxInterval
+		}
+		return
+	}
+
+	p.interval /= 2
+	if p.interval < step || p.interval < p.cfg.MinInterval {
+		p.interval = p.cfg.MinInterval
+	}
+}
+
+// currentInterval returns the gap currently enforced between requests
+func (p *pacer) currentInterval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.interval
+}
+
+func (p *pacer) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if err := p.clock.Sleep(req.Context(), p.reserve()); err != nil {
+			return nil, err
+		}
+
+		start := p.clock.Now()
+		resp, err := next.RoundTrip(req)
+		if err == nil {
+			p.observe(p.clock.Now().Sub(start))
+		}
+		return resp, err
+	})
+}
@@ -0,0 +1,178 @@
+package goclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// PagerConfig configures a Pager
+type PagerConfig struct {
+	// ItemsField names the JSON field holding the items of a page. When empty
+	// the body itself must be a JSON array.
+	ItemsField string
+	// CursorField names a top-level JSON field holding the cursor of the next
+	// page, which is sent as the CursorParam query parameter. When empty the
+	// Link header's rel="next" URL is followed.
+	CursorField string
+	CursorParam string
+	// NextPage, when set, returns the endpoint of the page after resp and
+	// takes precedence over CursorField and Link headers
+	NextPage func(resp *Response) (string, bool)
+}
+
+// ResumeToken is an opaque checkpoint of a Pager's position. It is a plain
+// string, so it can be stored in JSON, a database or a file and passed to
+// ResumePager after a restart.
+type ResumeToken string
+
+// resumeState is the content of a ResumeToken
+type resumeState struct {
+	Version int    `json:"v"`
+	Next    string `json:"next"`
+	Pages   int    `json:"pages"`
+}
+
+// Pager walks a paginated endpoint one page at a time, decoding each page's
+// items as T:
+//
+//	pager := goclient.NewPager[User](client, "/users", goclient.PagerConfig{ItemsField: "data"})
+//	for pager.More() {
+//		users, err := pager.Next(ctx)
+//		...
+//		checkpoint(pager.Token())
+//	}
+type Pager[T any] struct {
+	client Client
+	cfg    PagerConfig
+	next   string
+	pages  int
+}
+
+// NewPager creates a Pager starting at endpoint
+func NewPager[T any](client Client, endpoint string, cfg PagerConfig) *Pager[T] {
+	return &Pager[T]{client: client, cfg: cfg, next: endpoint}
+}
+
+// ResumePager creates a Pager continuing from a token returned by Token
+func ResumePager[T any](client Client, token ResumeToken, cfg PagerConfig) (*Pager[T], error) {
+	data, err := base64.RawURLEncoding.DecodeString(string(token))
+	if err != nil {
+		return nil, fmt.Errorf("invalid resume token: %w", err)
+	}
+	var state resumeState
+	if err := json.Unmarshal(data, &state); err != nil || state.Version != 1 {
+		return nil, errors.New("invalid resume token")
+	}
+	return &Pager[T]{client: client, cfg: cfg, next: state.Next, pages: state.Pages}, nil
+}
+
+// More reports whether there are pages left
+func (p *Pager[T]) More() bool {
+	return p.next != ""
+}
+
+// Pages returns how many pages have been fetched, including those fetched
+// before the pager was resumed
+func (p *Pager[T]) Pages() int {
+	return p.pages
+}
+
+// Token checkpoints the pager. Resuming from it fetches the page that the
+// next call to Next would fetch; a failed page is fetched again.
+func (p *Pager[T]) Token() ResumeToken {
+	data, _ := json.Marshal(resumeState{Version: 1, Next: p.next, Pages: p.pages})
+	return ResumeToken(base64.RawURLEncoding.EncodeToString(data))
+}
+
+// Next fetches the next page and returns its items. It returns io.EOF when
+// there are no pages left.
+func (p *Pager[T]) Next(ctx context.Context) ([]T, error) {
+	if p.next == "" {
+		return nil, io.EOF
+	}
+
+	resp, err := p.client.GetWithContext(ctx, p.next).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	body := resp.Body
+	if p.cfg.ItemsField != "" {
+		if body, err = UnwrapField(p.cfg.ItemsField)(body); err != nil {
+			return nil, err
+		}
+	}
+	var items []T
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, fmt.Errorf("failed to decode page items: %w", err)
+		}
+	}
+
+	next, err := p.nextPage(resp)
+	if err != nil {
+		return nil, err
+	}
+	p.next = next
+	p.pages++
+	return items, nil
+}
+
+// nextPage works out the endpoint of the page after resp, or "" at the end
+func (p *Pager[T]) nextPage(resp *Response) (string, error) {
+	if p.cfg.NextPage != nil {
+		next, ok := p.cfg.NextPage(resp)
+		if !ok {
+			return "", nil
+		}
+		return next, nil
+	}
+
+	if p.cfg.CursorField != "" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(resp.Body, &fields); err != nil {
+			return "", fmt.Errorf("failed to decode page cursor: %w", err)
+		}
+		// Cursors may be strings or numbers; null or "" ends the walk
+		cursor := strings.TrimSpace(string(fields[p.cfg.CursorField]))
+		if strings.HasPrefix(cursor, `"`) {
+			if err := json.Unmarshal([]byte(cursor), &cursor); err != nil {
+				return "", fmt.Errorf("failed to decode page cursor: %w", err)
+			}
+		} else if cursor == "null" || cursor == "false" {
+			cursor = ""
+		}
+		if cursor == "" {
+			return "", nil
+		}
+
+		u, err := url.Parse(p.next)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		q.Set(p.cfg.CursorParam, cursor)
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	links := ParseLinkHeader(resp.Headers)["next"]
+	if len(links) == 0 {
+		return "", nil
+	}
+	base, err := url.Parse(p.next)
+	if err != nil {
+		return links[0], nil
+	}
+	u, err := base.Parse(links[0])
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
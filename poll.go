@@ -0,0 +1,67 @@
+package goclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Poller repeatedly GETs an endpoint until a condition holds, for example
+// while waiting on the status of an asynchronous job. Create one with
+// Client.Poll.
+type Poller struct {
+	client   *client
+	endpoint string
+	interval time.Duration
+	max      time.Duration
+	until    func(*Response) bool
+}
+
+// Poll returns a Poller for endpoint
+func (c *client) Poll(endpoint string) *Poller {
+	return &Poller{client: c, endpoint: endpoint, interval: time.Second}
+}
+
+// Every sets the wait between polls, default 1s. Waits are jittered.
+// Non-positive intervals keep the default rather than polling in a tight loop.
+func (p *Poller) Every(interval time.Duration) *Poller {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	p.interval = interval
+	return p
+}
+
+// Backoff lets the wait double after each poll up to max
+func (p *Poller) Backoff(max time.Duration) *Poller {
+	p.max = max
+	return p
+}
+
+// Until sets the condition that ends polling. Without one, the first
+// successful response ends it.
+func (p *Poller) Until(done func(*Response) bool) *Poller {
+	p.until = done
+	return p
+}
+
+// Run polls until the condition holds and returns the matching response.
+// Failed polls are retried. When ctx ends, its error is returned together
+// with the last poll error.
+func (p *Poller) Run(ctx context.Context) (*Response, error) {
+	c := p.client
+	policy := retryPolicy{waitMin: p.interval, waitMax: max(p.max, p.interval)}
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		resp, err := c.GetWithContext(ctx, p.endpoint).Result()
+		if err == nil && (p.until == nil || p.until(resp)) {
+			return resp, nil
+		}
+		lastErr = err
+
+		if err := c.clock.Sleep(ctx, policy.backoff(attempt, c.rand)); err != nil {
+			return nil, newMultiError([]error{fmt.Errorf("poll %s: %w", p.endpoint, err), lastErr})
+		}
+	}
+}
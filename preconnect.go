@@ -0,0 +1,112 @@
+package goclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// preconnectInterval is how often KeepWarm refreshes warm connections. It
+// stays below the 90s idle timeout of the default transport.
+const preconnectInterval = 30 * time.Second
+
+// Preconnect opens connections to hosts ahead of time so the first real
+// request does not pay for DNS, TCP and TLS setup. Hosts may be bare host
+// names, which are contacted over https, or URLs; with no hosts the client's
+// base URL is used. Use KeepWarm to keep the connections open afterwards.
+func (c *client) Preconnect(ctx context.Context, hosts ...string) error {
+	return c.warm(ctx, c.warmTargets(hosts))
+}
+
+// KeepWarm preconnects to hosts like Preconnect and then refreshes the
+// connections in the background every 30 seconds until stop is called or ctx
+// is done. Each refresh sends a HEAD / request to every host through the
+// client's transport, including its middleware and circuit breaker, and
+// counts against server-side rate limits. Refresh failures are ignored.
+func (c *client) KeepWarm(ctx context.Context, hosts ...string) (stop func()) {
+	ctx, stop = context.WithCancel(ctx)
+	targets := c.warmTargets(hosts)
+
+	go func() {
+		_ = c.warm(ctx, targets)
+		for c.clock.Sleep(ctx, preconnectInterval) == nil {
+			_ = c.warm(ctx, targets)
+		}
+	}()
+	return stop
+}
+
+// warmTargets turns hosts into the URLs contacted by warm
+func (c *client) warmTargets(hosts []string) []string {
+	if len(hosts) == 0 && c.base() != "" {
+		hosts = []string{c.base()}
+	}
+
+	targets := make([]string, len(hosts))
+	for i, host := range hosts {
+		if !strings.Contains(host, "://") {
+			host = "https://" + host
+		}
+		targets[i] = strings.TrimRight(host, "/") + "/"
+	}
+	return targets
+}
+
+// warm sends a HEAD request to every target concurrently. Any response, even
+// an error status, leaves an idle connection in the transport's pool.
+func (c *client) warm(ctx context.Context, targets []string) error {
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+			if err != nil {
+				errs[i] = fmt.Errorf("preconnect %s: %w", target, err)
+				return
+			}
+			resp, err := c.httpClient.Do(req)
+			if err != nil {
+				errs[i] = fmt.Errorf("preconnect %s: %w", target, err)
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}(i, target)
+	}
+	wg.Wait()
+
+	return newMultiError(errs)
+}
+
+// primeWorkers bounds how many endpoints Prime fetches at once
+const primeWorkers = 8
+
+// Prime fetches endpoints concurrently through a request pool so that the
+// response cache, DNS and TLS state are warm before traffic arrives.
+// Endpoints are resolved like any other request. Failures are reported as a
+// *MultiError naming each endpoint.
+func (c *client) Prime(ctx context.Context, endpoints ...string) error {
+	pool := c.Pool(primeWorkers)
+	defer pool.Wait()
+
+	results := make([]<-chan Result, len(endpoints))
+	for i, endpoint := range endpoints {
+		results[i] = pool.Submit(c.GetWithContext(ctx, endpoint))
+	}
+
+	var errs []error
+	for i, result := range results {
+		if r := <-result; r.Error != nil {
+			errs = append(errs, fmt.Errorf("prime %s: %w", endpoints[i], r.Error))
+		}
+	}
+	return newMultiError(errs)
+}
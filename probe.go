@@ -0,0 +1,104 @@
+package goclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProbeResult describes what a server reports about an endpoint
+type ProbeResult struct {
+	// Method is the HTTP method that produced the result (OPTIONS or HEAD)
+	Method         string
+	StatusCode     int
+	AllowedMethods []string
+	AcceptPatch    []string
+	AcceptRanges   string
+	Server         string
+	CORS           CORSInfo
+	Headers        http.Header
+}
+
+// CORSInfo holds the CORS response headers advertised by a server
+type CORSInfo struct {
+	AllowOrigin      string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+// Allows reports whether method is among the advertised allowed methods
+func (p *ProbeResult) Allows(method string) bool {
+	for _, m := range p.AllowedMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *client) Probe(endpoint string) (*ProbeResult, error) {
+	return c.ProbeWithContext(context.Background(), endpoint)
+}
+
+// ProbeWithContext sends an OPTIONS request to endpoint and falls back to
+// HEAD when the server does not support OPTIONS
+func (c *client) ProbeWithContext(ctx context.Context, endpoint string) (*ProbeResult, error) {
+	resp, err := c.newRequest(ctx, http.MethodOptions, endpoint).Result()
+	if err == nil {
+		return newProbeResult(http.MethodOptions, resp), nil
+	}
+
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) ||
+		(reqErr.StatusCode != http.StatusMethodNotAllowed && reqErr.StatusCode != http.StatusNotImplemented) {
+		return nil, err
+	}
+
+	resp, err = c.newRequest(ctx, http.MethodHead, endpoint).Result()
+	if err != nil {
+		return nil, err
+	}
+	return newProbeResult(http.MethodHead, resp), nil
+}
+
+func newProbeResult(method string, resp *Response) *ProbeResult {
+	h := resp.Headers
+	result := &ProbeResult{
+		Method:         method,
+		StatusCode:     resp.StatusCode,
+		AllowedMethods: splitHeaderList(h, "Allow"),
+		AcceptPatch:    splitHeaderList(h, "Accept-Patch"),
+		AcceptRanges:   h.Get("Accept-Ranges"),
+		Server:         h.Get("Server"),
+		Headers:        h,
+		CORS: CORSInfo{
+			AllowOrigin:      h.Get("Access-Control-Allow-Origin"),
+			AllowMethods:     splitHeaderList(h, "Access-Control-Allow-Methods"),
+			AllowHeaders:     splitHeaderList(h, "Access-Control-Allow-Headers"),
+			ExposeHeaders:    splitHeaderList(h, "Access-Control-Expose-Headers"),
+			AllowCredentials: strings.EqualFold(h.Get("Access-Control-Allow-Credentials"), "true"),
+		},
+	}
+	if maxAge, err := strconv.Atoi(h.Get("Access-Control-Max-Age")); err == nil {
+		result.CORS.MaxAge = maxAge
+	}
+	return result
+}
+
+// splitHeaderList parses a comma separated header that may be repeated
+func splitHeaderList(h http.Header, key string) []string {
+	var out []string
+	for _, value := range h.Values(key) {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
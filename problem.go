@@ -0,0 +1,77 @@
+package goclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// ProblemDetails is an RFC 7807 problem document. It is set as the wrapped
+// error of a RequestError when a server answers with application/problem+json,
+// so it can be retrieved with errors.As.
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance"`
+	// Extensions holds any additional members of the problem document
+	Extensions map[string]interface{} `json:"-"`
+}
+
+func (p *ProblemDetails) Error() string {
+	msg := p.Title
+	if msg == "" {
+		msg = p.Type
+	}
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	return fmt.Sprintf("problem (status %d): %s", p.Status, msg)
+}
+
+// parseProblem decodes body as a problem document when contentType says it is one
+func parseProblem(contentType string, body []byte) (*ProblemDetails, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != problemContentType {
+		return nil, false
+	}
+
+	var problem ProblemDetails
+	if err := json.Unmarshal(body, &problem); err != nil {
+		return nil, false
+	}
+
+	var members map[string]interface{}
+	if err := json.Unmarshal(body, &members); err == nil {
+		for _, known := range []string{"type", "title", "status", "detail", "instance"} {
+			delete(members, known)
+		}
+		if len(members) > 0 {
+			problem.Extensions = members
+		}
+	}
+
+	// "about:blank" is the default type when the member is absent (RFC 7807 section 4.2)
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	return &problem, true
+}
+
+// decodeError maps an error response to an error using the configured
+// ErrorDecoder, then problem details. It returns nil when neither applies.
+func (c *client) decodeError(resp *http.Response, body []byte) error {
+	if c.errorDecoder != nil {
+		if err := c.errorDecoder(resp.StatusCode, body); err != nil {
+			return err
+		}
+	}
+	if problem, ok := parseProblem(resp.Header.Get("Content-Type"), body); ok {
+		return problem
+	}
+	return nil
+}
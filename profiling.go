@@ -0,0 +1,60 @@
+package goclient
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/url"
+	"runtime/pprof"
+)
+
+// PublishExpvar publishes the client's request, pool and cache counters as
+// an expvar variable under name, served at /debug/vars by the expvar
+// package. It fails when the name is already in use.
+func (c *client) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return errors.New("goclient: expvar " + name + " is already published")
+	}
+	expvar.Publish(name, expvar.Func(func() any {
+		s := c.snapshot()
+		return map[string]any{
+			"requests":  s.Requests,
+			"pools":     s.Pools,
+			"cache":     s.Cache,
+			"circuits":  s.Circuits,
+			"throttled": len(s.Throttle),
+		}
+	}))
+	return nil
+}
+
+// runLabeled executes the request under pprof labels naming the operation
+// and host when Config.ProfilerLabels is set, so CPU profiles attribute
+// time to outbound calls. The labels are also carried by the request context.
+func (r *request) runLabeled() {
+	if !r.client.pprofLabels {
+		r.execute()
+		return
+	}
+
+	labels := pprof.Labels(
+		"goclient.operation", r.method+" "+r.endpoint,
+		"goclient.host", r.labelHost(),
+	)
+	pprof.Do(r.ctx, labels, func(ctx context.Context) {
+		r.ctx = ctx
+		r.execute()
+	})
+}
+
+// labelHost returns the host the request is addressed to without resolving
+// its full URL
+func (r *request) labelHost() string {
+	if u, err := url.Parse(r.endpoint); err == nil && u.Host != "" {
+		return u.Host
+	}
+	if u, err := url.Parse(r.client.base()); err == nil {
+		return u.Host
+	}
+	return ""
+}
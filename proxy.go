@@ -0,0 +1,180 @@
+package goclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrNoProxies is returned by a ProxyProvider that has no proxies to offer
+var ErrNoProxies = errors.New("goclient: no proxies available")
+
+// ProxyProvider chooses the egress proxy for each request. MarkFailed is
+// called when a request through proxy fails at the transport level so the
+// provider can take it out of rotation.
+type ProxyProvider interface {
+	Next(req *http.Request) (*url.URL, error)
+	MarkFailed(proxy *url.URL, err error)
+}
+
+// RoundRobinProxyProvider hands out proxies in turn. Proxies marked as failed
+// are skipped until their cooldown expires; when every proxy is cooling down
+// the one that recovers first is used.
+type RoundRobinProxyProvider struct {
+	proxies  []*url.URL
+	cooldown time.Duration
+
+	mu          sync.Mutex
+	next        int
+	failedUntil []time.Time
+}
+
+// NewRoundRobinProxyProvider parses the given proxy URLs. Supported schemes
+// are http, https, socks5 and socks5h; credentials in the URL are used for
+// proxy authentication. A cooldown of zero defaults to 30 seconds.
+func NewRoundRobinProxyProvider(cooldown time.Duration, proxies ...string) (*RoundRobinProxyProvider, error) {
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+
+	p := &RoundRobinProxyProvider{
+		cooldown:    cooldown,
+		failedUntil: make([]time.Time, len(proxies)),
+	}
+	for _, raw := range proxies {
+		u, err := parseProxyURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.proxies = append(p.proxies, u)
+	}
+	return p, nil
+}
+
+// Next returns the next healthy proxy
+func (p *RoundRobinProxyProvider) Next(req *http.Request) (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.proxies) == 0 {
+		return nil, ErrNoProxies
+	}
+
+	now := time.Now()
+	fallback := -1
+	for i := 0; i < len(p.proxies); i++ {
+		idx := (p.next + i) % len(p.proxies)
+		if !p.failedUntil[idx].After(now) {
+			p.next = idx + 1
+			return p.proxies[idx], nil
+		}
+		if fallback < 0 || p.failedUntil[idx].Before(p.failedUntil[fallback]) {
+			fallback = idx
+		}
+	}
+
+	p.next = fallback + 1
+	return p.proxies[fallback], nil
+}
+
+// MarkFailed puts proxy on cooldown
+func (p *RoundRobinProxyProvider) MarkFailed(proxy *url.URL, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, u := range p.proxies {
+		if u.String() == proxy.String() {
+			p.failedUntil[i] = time.Now().Add(p.cooldown)
+		}
+	}
+}
+
+type proxyKey struct{}
+
+// ProxyFromRequest returns the proxy set on req with RequestBuilder.SetProxy
+// or else chosen by the client's ProxyProvider. It has the signature of
+// http.Transport.Proxy so custom transports set as Config.Interceptor can
+// honour proxy rotation.
+func ProxyFromRequest(req *http.Request) (*url.URL, error) {
+	if info, ok := RequestInfoFromContext(req.Context()); ok && info.Proxy != "" {
+		return parseProxyURL(info.Proxy)
+	}
+	proxy, _ := req.Context().Value(proxyKey{}).(*url.URL)
+	return proxy, nil
+}
+
+// parseProxyURL parses a proxy URL with a supported scheme
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, nil
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q", raw, u.Scheme)
+	}
+}
+
+// SetProxy sends this request through the proxy at rawURL, overriding
+// Config.ProxyURL and the ProxyProvider. Supported schemes are http, https,
+// socks5 and socks5h.
+func (r *request) SetProxy(rawURL string) RequestBuilder {
+	r.proxy = rawURL
+	return r
+}
+
+// proxyTransport returns a transport that dials through the proxy picked for
+// each request, falling back to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables unless env is false. Only *http.Transport can be
+// reconfigured; other round trippers must use ProxyFromRequest themselves.
+func proxyTransport(rt http.RoundTripper, env bool) http.RoundTripper {
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return rt
+	}
+	t = t.Clone()
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxy, err := ProxyFromRequest(req)
+		if proxy != nil || err != nil || !env {
+			return proxy, err
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+	return t
+}
+
+// proxyMiddleware picks a proxy for every request and reports transport
+// failures back to the provider
+func proxyMiddleware(provider ProxyProvider) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// A proxy set on the request wins
+			if info, _ := RequestInfoFromContext(req.Context()); info.Proxy != "" {
+				return next.RoundTrip(req)
+			}
+
+			proxy, err := provider.Next(req)
+			if err != nil {
+				return nil, err
+			}
+			if proxy == nil {
+				return next.RoundTrip(req)
+			}
+
+			req = req.WithContext(context.WithValue(req.Context(), proxyKey{}, proxy))
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				provider.MarkFailed(proxy, err)
+			} else if resp.StatusCode == http.StatusProxyAuthRequired {
+				provider.MarkFailed(proxy, fmt.Errorf("proxy %s: %s", proxy.Host, resp.Status))
+			}
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package goclient
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SetQueryParamsFromStruct sets query parameters from the exported fields of
+// the struct v, or a pointer to one, following `url` struct tags:
+//
+//	type ListOptions struct {
+//		Status string    `url:"status,omitempty"`
+//		IDs    []int     `url:"id"`                              // id=1&id=2
+//		Tags   []string  `url:"tags,comma"`                      // tags=a,b
+//		Since  time.Time `url:"since,omitempty" layout:"2006-01-02"`
+//		Until  time.Time `url:"until,unix"`                      // seconds
+//		Debug  bool      `url:"-"`
+//	}
+//
+// Fields without a tag use their name, embedded structs are flattened and
+// nil pointers are skipped. Times are RFC 3339 unless a layout tag or the
+// unix option says otherwise. Values replace earlier ones for the same key;
+// encoding errors are returned when the request is sent.
+func (r *request) SetQueryParamsFromStruct(v interface{}) RequestBuilder {
+	values := url.Values{}
+	if err := encodeQuery(values, reflect.ValueOf(v)); err != nil {
+		r.queryErr = err
+		return r
+	}
+	for key, vs := range values {
+		if r.queryParams == nil {
+			r.queryParams = url.Values{}
+		}
+		r.queryParams[key] = vs
+	}
+	return r
+}
+
+// encodeQuery adds the fields of the struct rv to values
+func encodeQuery(values url.Values, rv reflect.Value) error {
+	rv, ok := indirectValue(rv)
+	if !ok {
+		return nil
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("query params: expected a struct, got %s", rv.Kind())
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := rv.Field(i)
+
+		if field.Anonymous && name == "" && indirectType(field.Type).Kind() == reflect.Struct && indirectType(field.Type) != timeType {
+			if err := encodeQuery(values, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		options := strings.Split(opts, ",")
+		has := func(opt string) bool {
+			for _, o := range options {
+				if o == opt {
+					return true
+				}
+			}
+			return false
+		}
+
+		if has("omitempty") && fv.IsZero() {
+			continue
+		}
+		fv, ok := indirectValue(fv)
+		if !ok {
+			continue
+		}
+
+		format := func(v reflect.Value) (string, error) {
+			return formatQueryValue(v, field.Tag.Get("layout"), has("unix"))
+		}
+
+		if (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) && fv.Type().Elem().Kind() != reflect.Uint8 {
+			items := make([]string, 0, fv.Len())
+			for j := 0; j < fv.Len(); j++ {
+				s, err := format(fv.Index(j))
+				if err != nil {
+					return fmt.Errorf("query param %s: %w", name, err)
+				}
+				items = append(items, s)
+			}
+			if has("omitempty") && len(items) == 0 {
+				continue
+			}
+			if has("comma") {
+				values.Set(name, strings.Join(items, ","))
+			} else {
+				values[name] = items
+			}
+			continue
+		}
+
+		s, err := format(fv)
+		if err != nil {
+			return fmt.Errorf("query param %s: %w", name, err)
+		}
+		values.Set(name, s)
+	}
+	return nil
+}
+
+// indirectValue follows pointers and interfaces, reporting false on nil
+func indirectValue(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.IsValid()
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// formatQueryValue formats a single scalar query value
+func formatQueryValue(v reflect.Value, layout string, unix bool) (string, error) {
+	v, ok := indirectValue(v)
+	if !ok {
+		return "", nil
+	}
+
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		switch {
+		case unix:
+			return strconv.FormatInt(t.Unix(), 10), nil
+		case layout != "":
+			return t.Format(layout), nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package goclient
+
+// raceEnabled reports whether the race detector is on. It instruments
+// allocations, so allocation budgets do not hold under it.
+const raceEnabled = true
@@ -0,0 +1,61 @@
+package goclient
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// RandSource supplies the randomness used for jitter. *rand.Rand satisfies it;
+// seed one via Config.Rand to make backoff schedules reproducible.
+type RandSource interface {
+	Float64() float64
+}
+
+// IDGenerator produces identifiers such as request IDs and idempotency keys
+type IDGenerator func() string
+
+// NewSequentialIDGenerator returns an IDGenerator yielding prefix-1, prefix-2, ...
+// which keeps recorded fixtures byte-for-byte reproducible
+func NewSequentialIDGenerator(prefix string) IDGenerator {
+	var n uint64
+	return func() string {
+		return fmt.Sprintf("%s-%d", prefix, atomic.AddUint64(&n, 1))
+	}
+}
+
+type globalRand struct{}
+
+func (globalRand) Float64() float64 {
+	return rand.Float64()
+}
+
+// lockedRand serializes access to a RandSource that is not safe for
+// concurrent use, such as *rand.Rand
+type lockedRand struct {
+	mu  sync.Mutex
+	src RandSource
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.src.Float64()
+}
+
+func newRandSource(src RandSource) RandSource {
+	if src == nil {
+		return globalRand{}
+	}
+	return &lockedRand{src: src}
+}
+
+func newIDGenerator(gen IDGenerator) IDGenerator {
+	if gen == nil {
+		return uuid.NewString
+	}
+	return gen
+}
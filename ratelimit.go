@@ -0,0 +1,84 @@
+package goclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimit configures the client-side token bucket that paces requests
+type RateLimit struct {
+	// RequestsPerSecond is the sustained request rate
+	RequestsPerSecond float64
+	// Burst is the number of requests that may be sent at once, default 1
+	Burst int
+}
+
+// rateLimiter is a token bucket shared by every request of a client,
+// including those sent through Batch and Pool
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	clock Clock
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limit *RateLimit, clock Clock) *rateLimiter {
+	if limit == nil || limit.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   limit.RequestsPerSecond,
+		burst:  burst,
+		clock:  clock,
+		tokens: burst,
+		last:   clock.Now(),
+	}
+}
+
+// reserve takes a token, possibly borrowing against future refills, and
+// returns how long to wait before the token is available
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token that was not used
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
+
+// wait blocks until a token is available or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	if err := l.clock.Sleep(ctx, l.reserve()); err != nil {
+		l.cancel()
+		return fmt.Errorf("rate limit wait: %w", err)
+	}
+	return nil
+}
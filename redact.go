@@ -0,0 +1,152 @@
+package goclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys lists header and query parameter names whose values are never
+// printed. Keys are compared case-insensitively.
+var sensitiveKeys = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"api_key":             true,
+	"apikey":              true,
+	"access_token":        true,
+	"token":               true,
+	"password":            true,
+	"secret":              true,
+}
+
+func isSensitiveKey(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// redactedMap renders values as a sorted key=value list, masking sensitive keys
+func redactedMap(values map[string][]string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := strings.Join(values[k], ", ")
+		if isSensitiveKey(k) {
+			v = redacted
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// redactedURL returns raw with its password and the values of sensitive query
+// parameters redacted
+func redactedURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			if isSensitiveKey(k) {
+				q[k] = []string{redacted}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
+
+// redactedError returns the message of err with the request URL it reports
+// redacted
+func redactedError(err error) string {
+	msg := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		msg = strings.ReplaceAll(msg, urlErr.URL, redactedURL(urlErr.URL))
+	}
+	return msg
+}
+
+func singleValued(m map[string]string) map[string][]string {
+	out := make(map[string][]string, len(m))
+	for k, v := range m {
+		out[k] = []string{v}
+	}
+	return out
+}
+
+// String implements fmt.Stringer without exposing credentials
+func (c *client) String() string {
+	auth := "none"
+	switch {
+	case c.tokenProvider != nil:
+		auth = "bearer " + redacted
+	case c.basicAuth.Username != "":
+		auth = "basic " + c.basicAuth.Username + ":" + redacted
+	case c.auth != nil:
+		auth = "custom"
+	}
+	return fmt.Sprintf("goclient.Client{baseURL: %q, auth: %s}", c.base(), auth)
+}
+
+// GoString implements fmt.GoStringer so %#v is redacted as well
+func (c *client) GoString() string {
+	return c.String()
+}
+
+// DebugString returns a detailed, redacted description of the client configuration
+func (c *client) DebugString() string {
+	var b strings.Builder
+	b.WriteString(c.String())
+	fmt.Fprintf(&b, "\n  timeout: %s", c.httpClient.Timeout)
+	c.headersMu.RLock()
+	fmt.Fprintf(&b, "\n  global headers: %s", redactedMap(singleValued(c.globalHeaders)))
+	c.headersMu.RUnlock()
+	fmt.Fprintf(&b, "\n  global query: %s", redactedMap(singleValued(c.globalQuery)))
+	fmt.Fprintf(&b, "\n  debug: %t", c.debugEnabled.Load())
+	return b.String()
+}
+
+// String implements fmt.Stringer without exposing credentials
+func (r *request) String() string {
+	return fmt.Sprintf("goclient.Request{method: %s, endpoint: %q}", r.method, r.endpoint)
+}
+
+// GoString implements fmt.GoStringer so %#v is redacted as well
+func (r *request) GoString() string {
+	return r.String()
+}
+
+// DebugString returns a detailed, redacted description of the request
+func (r *request) DebugString() string {
+	headers := make(http.Header)
+	for k, v := range r.headers {
+		headers[k] = v
+	}
+	for k, v := range r.addedHeaders {
+		headers[k] = append(headers[k], v...)
+	}
+
+	var b strings.Builder
+	b.WriteString(r.String())
+	fmt.Fprintf(&b, "\n  path params: %s", redactedMap(singleValued(r.pathParams)))
+	fmt.Fprintf(&b, "\n  query: %s", redactedMap(r.queryParams))
+	fmt.Fprintf(&b, "\n  headers: %s", redactedMap(headers))
+	if r.body != nil {
+		fmt.Fprintf(&b, "\n  body: %T", r.body)
+	}
+	return b.String()
+}
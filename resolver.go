@@ -0,0 +1,129 @@
+package goclient
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// Resolver maps the host of an outgoing request, typically a logical service
+// name, to the network addresses (host:port) currently serving it
+type Resolver interface {
+	// Resolve returns the addresses for host. An empty result with a nil
+	// error leaves the request untouched, so resolvers can ignore hosts
+	// they do not manage.
+	Resolve(ctx context.Context, host string) ([]string, error)
+}
+
+// ResolverFunc adapts an ordinary function to the Resolver interface
+type ResolverFunc func(ctx context.Context, host string) ([]string, error)
+
+// Resolve implements Resolver interface
+func (f ResolverFunc) Resolve(ctx context.Context, host string) ([]string, error) {
+	return f(ctx, host)
+}
+
+// SetRoutingKey routes the request consistently: every request with the same
+// key, such as a tenant ID, goes to the same resolved address for as long as
+// that address stays available. Requests without a key are spread round-robin.
+func (r *request) SetRoutingKey(key string) RequestBuilder {
+	r.routingKey = key
+	return r
+}
+
+// stickyAddr picks the address for key by rendezvous hashing, so adding or
+// removing an address only moves the keys that hashed to it
+func stickyAddr(key string, addrs []string) string {
+	var best string
+	var bestScore uint64
+	for _, addr := range addrs {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(addr))
+		if score := h.Sum64(); best == "" || score > bestScore {
+			best, bestScore = addr, score
+		}
+	}
+	return best
+}
+
+// resolverMiddleware sends each request to one of the addresses resolved for
+// its host, rotating between them and skipping outliers. Requests with a
+// routing key stick to one address. Only the connection goes to the address:
+// the URL, Host header and TLS server name keep the logical name.
+func resolverMiddleware(resolver Resolver, outliers *outlierDetector, clock Clock) Middleware {
+	var counter uint64
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			host := req.URL.Host
+			addrs, err := resolver.Resolve(req.Context(), host)
+			if err != nil {
+				return nil, fmt.Errorf("resolve %s: %w", host, err)
+			}
+			if len(addrs) == 0 {
+				return next.RoundTrip(req)
+			}
+
+			candidates := outliers.filter(host, addrs)
+			var addr string
+			if info, _ := RequestInfoFromContext(req.Context()); info.RoutingKey != "" {
+				addr = stickyAddr(info.RoutingKey, candidates)
+			} else {
+				addr = candidates[atomic.AddUint64(&counter, 1)%uint64(len(candidates))]
+			}
+
+			req = req.WithContext(context.WithValue(req.Context(), resolvedAddrKey{}, addr))
+
+			start := clock.Now()
+			resp, err := next.RoundTrip(req)
+			if outliers != nil {
+				failed := err != nil || resp.StatusCode >= 500 ||
+					(outliers.cfg.SlowThreshold > 0 && clock.Now().Sub(start) > outliers.cfg.SlowThreshold)
+				outliers.record(host, addr, len(addrs), failed)
+			}
+			return resp, err
+		})
+	}
+}
+
+// resolvedAddrKey carries the address picked by resolverMiddleware down to
+// the transport dialing it
+type resolvedAddrKey struct{}
+
+func resolvedAddrFromContext(ctx context.Context) (string, bool) {
+	addr, ok := ctx.Value(resolvedAddrKey{}).(string)
+	return addr, ok
+}
+
+// resolvedURL points req at its resolved address, for transports whose
+// dialer cannot be redirected. The Host header keeps the logical name, but
+// TLS then verifies the certificate against the address.
+func resolvedURL(req *http.Request) *http.Request {
+	addr, ok := resolvedAddrFromContext(req.Context())
+	if !ok {
+		return req
+	}
+	req = req.Clone(req.Context())
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+	req.URL.Host = addr
+	return req
+}
+
+// hostPort returns the host:port a transport dials for u
+func hostPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
@@ -0,0 +1,252 @@
+package goclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRetryableStatusCodes are retried when Config.RetryableStatusCodes is empty
+var defaultRetryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Attempt is one try of a request
+type Attempt struct {
+	Start    time.Time
+	Duration time.Duration
+	// StatusCode is zero when no response was received
+	StatusCode int
+	Err        error
+}
+
+// AttemptsError reports every attempt of a request that failed after being
+// retried. For failed responses it is the wrapped error of the RequestError.
+type AttemptsError struct {
+	Attempts []Attempt
+	Err      error
+}
+
+func (e *AttemptsError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, len(e.Attempts))
+}
+
+func (e *AttemptsError) Unwrap() error {
+	return e.Err
+}
+
+// retryPolicy decides whether and when a failed attempt is repeated
+type retryPolicy struct {
+	maxRetries int
+	waitMin    time.Duration
+	waitMax    time.Duration
+	statuses   map[int]bool
+	condition  func(*Response, error) bool
+	maxAfter   time.Duration
+	allMethods bool
+}
+
+func newRetryPolicy(cfg Config) retryPolicy {
+	p := retryPolicy{
+		maxRetries: cfg.MaxRetries,
+		waitMin:    cfg.RetryWaitMin,
+		waitMax:    cfg.RetryWaitMax,
+		statuses:   make(map[int]bool),
+		maxAfter:   cfg.MaxRetryAfter,
+		allMethods: cfg.RetryAllMethods,
+	}
+	if p.waitMin <= 0 {
+		p.waitMin = 100 * time.Millisecond
+	}
+	if p.waitMax <= 0 {
+		p.waitMax = 5 * time.Second
+	}
+	if p.waitMax < p.waitMin {
+		p.waitMax = p.waitMin
+	}
+
+	codes := cfg.RetryableStatusCodes
+	if len(codes) == 0 {
+		codes = defaultRetryableStatusCodes
+	}
+	for _, code := range codes {
+		p.statuses[code] = true
+	}
+	return p
+}
+
+// retriable reports whether req may be sent again. Repeating a request that
+// is not idempotent could apply it twice, so unless the policy covers every
+// method only idempotent methods and requests carrying an Idempotency-Key
+// header are retried.
+func (p retryPolicy) retriable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return p.allMethods || req.Header.Get("Idempotency-Key") != ""
+}
+
+// shouldRetry reports whether an attempt that produced resp or err is worth repeating
+func (p retryPolicy) shouldRetry(resp *http.Response, body []byte, err error) bool {
+	if p.condition != nil {
+		var r *Response
+		if resp != nil {
+			r = &Response{StatusCode: resp.StatusCode, Headers: resp.Header, Body: body}
+		}
+		return p.condition(r, err)
+	}
+	if err != nil {
+		// Only transport failures are transient; oversized bodies stay
+		// oversized, open circuits and full maintenance queues fail fast
+		var tooLarge *RequestTooLargeError
+		return resp == nil && !errors.As(err, &tooLarge) && !errors.Is(err, ErrCircuitOpen) &&
+			!errors.Is(err, ErrMaintenanceQueueFull)
+	}
+	return p.statuses[resp.StatusCode]
+}
+
+// backoff returns the wait before the given retry (1-based) using exponential
+// backoff with jitter drawn from the client's random source
+func (p retryPolicy) backoff(retry int, rand RandSource) time.Duration {
+	wait := float64(p.waitMin) * math.Pow(2, float64(retry-1))
+	if wait > float64(p.waitMax) {
+		wait = float64(p.waitMax)
+	}
+	// Equal jitter keeps at least half of the computed wait
+	return time.Duration(wait/2 + rand.Float64()*wait/2)
+}
+
+// retryAfter returns the delay requested by a Retry-After header on a 429 or
+// 503 response, capped at maxAfter when set. ok is false when the header is
+// absent or unparseable.
+func (p retryPolicy) retryAfter(resp *http.Response, now time.Time) (wait time.Duration, ok bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		wait = t.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	if p.maxAfter > 0 && wait > p.maxAfter {
+		wait = p.maxAfter
+	}
+	return wait, true
+}
+
+// send performs a single attempt and reads the whole response body
+func (r *request) send(hc *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	if r.client.maintenance != nil {
+		if err := r.client.maintenance.wait(req); err != nil {
+			return nil, nil, fmt.Errorf("request failed: %w", err)
+		}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		if resp.Body != nil {
+			// A consumer that gave up leaves a body not worth downloading
+			if r.consumeErr == nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+			}
+			resp.Body.Close()
+		}
+	}()
+
+	// Successful bodies may be decoded straight from the stream
+	if r.consume != nil && resp.StatusCode < 300 {
+		guard, done, err := r.client.bodyReader(resp)
+		if err != nil {
+			return resp, nil, fmt.Errorf("error reading response body: %w", err)
+		}
+		var body io.Reader = http.NoBody
+		if guard != nil {
+			defer done()
+			body = guard
+		}
+		r.consumeErr = r.consume(resp, body)
+		return resp, nil, nil
+	}
+
+	body, err := r.client.readBody(resp)
+	if err != nil {
+		return resp, nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	return resp, body, nil
+}
+
+// recordAttempt adds an attempt to the request's history, which is only
+// kept once a request is retried
+func (r *request) recordAttempt(start time.Time, resp *http.Response, err error) {
+	a := Attempt{Start: start, Duration: r.client.clock.Now().Sub(start), Err: err}
+	if resp != nil {
+		a.StatusCode = resp.StatusCode
+	}
+	r.attempts = append(r.attempts, a)
+}
+
+// reportAttempts wraps the error of a retried request with its history
+func (r *request) reportAttempts(reqErr *RequestError) {
+	if len(r.attempts) > 1 {
+		reqErr.Err = &AttemptsError{Attempts: r.attempts, Err: reqErr.Err}
+	}
+}
+
+// sendWithRetry sends req, repeating failed attempts according to the retry
+// policy. It returns the outcome of the last attempt and the number of attempts made.
+func (r *request) sendWithRetry(req *http.Request) (*http.Response, []byte, int, error) {
+	hc := r.httpClient()
+	policy := r.retryPolicy()
+
+	for attempt := 1; ; attempt++ {
+		start := r.client.clock.Now()
+		resp, body, err := r.send(hc, req)
+
+		if attempt > policy.maxRetries || !policy.retriable(req) || !policy.shouldRetry(resp, body, err) || r.ctx.Err() != nil {
+			if len(r.attempts) > 0 {
+				r.recordAttempt(start, resp, err)
+			}
+			if err != nil && attempt > 1 {
+				err = &AttemptsError{Attempts: r.attempts, Err: err}
+			}
+			return resp, body, attempt, err
+		}
+		r.recordAttempt(start, resp, err)
+
+		// A consumed one-shot stream cannot be sent again
+		if RewindBody(req) != nil {
+			return resp, body, attempt, err
+		}
+		wait, ok := policy.retryAfter(resp, r.client.clock.Now())
+		if !ok {
+			wait = policy.backoff(attempt, r.client.rand)
+		}
+		if sleepErr := r.client.clock.Sleep(r.ctx, wait); sleepErr != nil {
+			return resp, body, attempt, err
+		}
+	}
+}
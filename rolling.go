@@ -0,0 +1,171 @@
+package goclient
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// RollingFileConfig configures a RollingFile
+type RollingFileConfig struct {
+	// Path is the file being written; rotated files get a .1, .2, ... suffix
+	Path string
+	// MaxSize is the size in bytes at which the file is rotated, default 10MB
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept, default 5
+	MaxBackups int
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// RollingFile is an io.WriteCloser for debug logs, dumps and other recordings
+// that rotates the file by size and keeps a bounded number of optionally
+// gzipped backups, so recording can stay enabled in production. Writes are
+// never split across files.
+type RollingFile struct {
+	cfg RollingFileConfig
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRollingFile opens cfg.Path for appending
+func NewRollingFile(cfg RollingFileConfig) (*RollingFile, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 10 << 20
+	}
+	if cfg.MaxBackups <= 0 {
+		cfg.MaxBackups = 5
+	}
+
+	f := &RollingFile{cfg: cfg}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RollingFile) open() error {
+	file, err := os.OpenFile(f.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when it would push the file past MaxSize
+func (f *RollingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.cfg.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, fmt.Errorf("rotate %s: %w", f.cfg.Path, err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (f *RollingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RollingFile) backup(n int) string {
+	name := fmt.Sprintf("%s.%d", f.cfg.Path, n)
+	if f.cfg.Compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// rotate shifts the backups, moves the current file to backup 1 and reopens
+func (f *RollingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if err := os.Remove(f.backup(f.cfg.MaxBackups)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := f.cfg.MaxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(f.backup(n), f.backup(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	var err error
+	if f.cfg.Compress {
+		err = gzipFile(f.cfg.Path, f.backup(1))
+	} else {
+		err = os.Rename(f.cfg.Path, f.backup(1))
+	}
+	if err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// gzipFile compresses src into dst and removes src
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
+
+// NewWriterLogger returns a DefaultLogger that writes to w, for example a
+// RollingFile
+func NewWriterLogger(w io.Writer) *DefaultLogger {
+	return &DefaultLogger{
+		logger: log.New(w, "[GOCLIENT] ", log.LstdFlags|log.Lmicroseconds),
+	}
+}
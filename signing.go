@@ -0,0 +1,175 @@
+package goclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// SignerOptions customizes an HMAC signing scheme. Zero values select the
+// defaults noted on each field.
+type SignerOptions struct {
+	// Hash is the HMAC hash function, default SHA-256
+	Hash func() hash.Hash
+	// KeyIDHeader carries the key ID, default X-Key-Id
+	KeyIDHeader string
+	// TimestampHeader carries the signing time, default X-Timestamp
+	TimestampHeader string
+	// SignatureHeader carries the signature, default X-Signature
+	SignatureHeader string
+	// DigestHeader optionally carries the body digest as well
+	DigestHeader string
+	// Timestamp formats the signing time, default Unix seconds
+	Timestamp func(time.Time) string
+	// Encode formats the signature and body digest, default lowercase hex
+	Encode func([]byte) string
+	// StringToSign builds the signed message from the method, the path with
+	// its query, the formatted timestamp and the encoded body digest. The
+	// default joins them with newlines.
+	StringToSign func(method, path, timestamp, digest string) string
+	// Clock provides the signing time, default the system clock
+	Clock Clock
+	// IsSkewError reports whether a response may be a rejection caused by
+	// clock skew, default 401 and 403. The skew is measured from the
+	// response's Date header; if it exceeds MaxSkew the request is signed
+	// again with the corrected time, which is also used from then on.
+	IsSkewError func(resp *http.Response) bool
+	// MaxSkew is the clock difference tolerated before correcting it,
+	// default 5 seconds
+	MaxSkew time.Duration
+	// DisableSkewCorrection turns off skew detection and re-signing
+	DisableSkewCorrection bool
+}
+
+func (o SignerOptions) withDefaults() SignerOptions {
+	if o.Hash == nil {
+		o.Hash = sha256.New
+	}
+	if o.KeyIDHeader == "" {
+		o.KeyIDHeader = "X-Key-Id"
+	}
+	if o.TimestampHeader == "" {
+		o.TimestampHeader = "X-Timestamp"
+	}
+	if o.SignatureHeader == "" {
+		o.SignatureHeader = "X-Signature"
+	}
+	if o.Timestamp == nil {
+		o.Timestamp = func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+	}
+	if o.Encode == nil {
+		o.Encode = hex.EncodeToString
+	}
+	if o.StringToSign == nil {
+		o.StringToSign = func(method, path, timestamp, digest string) string {
+			return method + "\n" + path + "\n" + timestamp + "\n" + digest
+		}
+	}
+	if o.Clock == nil {
+		o.Clock = realClock{}
+	}
+	if o.IsSkewError == nil {
+		o.IsSkewError = func(resp *http.Response) bool {
+			return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+		}
+	}
+	if o.MaxSkew <= 0 {
+		o.MaxSkew = 5 * time.Second
+	}
+	return o
+}
+
+// measureSkew returns how far the server clock, from the Date header of
+// resp, is ahead of now
+func measureSkew(resp *http.Response, now time.Time) (time.Duration, bool) {
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+	return date.Sub(now), true
+}
+
+// HMACSigning returns a middleware that signs every attempt over the method,
+// path, timestamp and body digest with an HMAC of secret. Bodies must be
+// replayable so they can be hashed without being consumed. Requests rejected
+// because of clock skew are signed again; see SignerOptions.IsSkewError.
+func HMACSigning(keyID, secret string, opts SignerOptions) Middleware {
+	opts = opts.withDefaults()
+	key := []byte(secret)
+	// offset is the learned server clock skew in nanoseconds
+	var offset atomic.Int64
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		sign := func(req *http.Request) (*http.Request, error) {
+			digest := opts.Hash()
+			if req.Body != nil && req.Body != http.NoBody {
+				if req.GetBody == nil {
+					return nil, ErrBodyNotReplayable
+				}
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				_, err = io.Copy(digest, body)
+				body.Close()
+				if err != nil {
+					return nil, err
+				}
+			}
+			bodyDigest := opts.Encode(digest.Sum(nil))
+			timestamp := opts.Timestamp(opts.Clock.Now().Add(time.Duration(offset.Load())))
+
+			mac := hmac.New(opts.Hash, key)
+			io.WriteString(mac, opts.StringToSign(req.Method, req.URL.RequestURI(), timestamp, bodyDigest))
+
+			req = req.Clone(req.Context())
+			req.Header.Set(opts.KeyIDHeader, keyID)
+			req.Header.Set(opts.TimestampHeader, timestamp)
+			req.Header.Set(opts.SignatureHeader, opts.Encode(mac.Sum(nil)))
+			if opts.DigestHeader != "" {
+				req.Header.Set(opts.DigestHeader, bodyDigest)
+			}
+			return req, nil
+		}
+
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			signed, err := sign(req)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next.RoundTrip(signed)
+			if err != nil || opts.DisableSkewCorrection || !opts.IsSkewError(resp) {
+				return resp, err
+			}
+
+			skew, ok := measureSkew(resp, opts.Clock.Now())
+			current := time.Duration(offset.Load())
+			if !ok || (skew-current).Abs() <= opts.MaxSkew {
+				return resp, nil
+			}
+			offset.Store(int64(skew))
+
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return resp, nil
+				}
+				req = req.Clone(req.Context())
+				req.Body = body
+			}
+			signed, err = sign(req)
+			if err != nil {
+				return resp, nil
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			return next.RoundTrip(signed)
+		})
+	}
+}
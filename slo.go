@@ -0,0 +1,175 @@
+package goclient
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxSLOSamples bounds the observations an SLO keeps per window
+const maxSLOSamples = 10000
+
+// SLO is a service level objective for outbound calls, evaluated over a
+// sliding window
+type SLO struct {
+	// Operation is the method and route template, such as
+	// "GET /users/{id}". An empty Operation covers every request.
+	Operation string
+	// Window is the sliding window the objective is evaluated over,
+	// default 5 minutes
+	Window time.Duration
+	// Quantile and Latency require the given latency quantile, such as
+	// 0.99, to stay below Latency. A zero Latency disables the check.
+	Quantile float64
+	Latency  time.Duration
+	// MaxErrorRate is the highest tolerated share of failed requests,
+	// between 0 and 1. Zero disables the check.
+	MaxErrorRate float64
+	// MinRequests is the number of requests in the window below which the
+	// objective is not evaluated, default 20
+	MinRequests int
+}
+
+// SLOBreach reports an objective that started or stopped being violated
+type SLOBreach struct {
+	SLO       SLO
+	Operation string
+	// Latency is the observed latency at the objective's quantile
+	Latency   time.Duration
+	ErrorRate float64
+	Requests  int
+	// Recovered is true when the objective is met again after a breach
+	Recovered bool
+}
+
+// SLOConfig configures SLO tracking. OnBreach is called when an objective
+// starts being violated and again, with Recovered set, once it is met.
+type SLOConfig struct {
+	Objectives []SLO
+	OnBreach   func(SLOBreach)
+}
+
+type sloSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+type sloTracker struct {
+	slo      SLO
+	samples  []sloSample
+	breached bool
+}
+
+// sloMonitor evaluates the objectives of a client
+type sloMonitor struct {
+	onBreach func(SLOBreach)
+	clock    Clock
+
+	mu       sync.Mutex
+	trackers []*sloTracker
+}
+
+func newSLOMonitor(cfg *SLOConfig, clock Clock) *sloMonitor {
+	if cfg == nil || len(cfg.Objectives) == 0 {
+		return nil
+	}
+
+	m := &sloMonitor{onBreach: cfg.OnBreach, clock: clock}
+	for _, slo := range cfg.Objectives {
+		if slo.Window <= 0 {
+			slo.Window = 5 * time.Minute
+		}
+		if slo.MinRequests <= 0 {
+			slo.MinRequests = 20
+		}
+		if slo.Quantile <= 0 || slo.Quantile > 1 {
+			slo.Quantile = 0.99
+		}
+		m.trackers = append(m.trackers, &sloTracker{slo: slo})
+	}
+	return m
+}
+
+// observe records a finished request and reports objectives whose state
+// changed
+func (m *sloMonitor) observe(operation string, latency time.Duration, failed bool) {
+	if m == nil {
+		return
+	}
+	now := m.clock.Now()
+
+	var events []SLOBreach
+	m.mu.Lock()
+	for _, t := range m.trackers {
+		if t.slo.Operation != "" && t.slo.Operation != operation {
+			continue
+		}
+		if event, changed := t.add(now, operation, sloSample{at: now, latency: latency, failed: failed}); changed {
+			events = append(events, event)
+		}
+	}
+	m.mu.Unlock()
+
+	if m.onBreach != nil {
+		for _, event := range events {
+			m.onBreach(event)
+		}
+	}
+}
+
+// add records sample and re-evaluates the objective
+func (t *sloTracker) add(now time.Time, operation string, sample sloSample) (SLOBreach, bool) {
+	cutoff := now.Add(-t.slo.Window)
+	drop := 0
+	for drop < len(t.samples) && t.samples[drop].at.Before(cutoff) {
+		drop++
+	}
+	if len(t.samples)-drop >= maxSLOSamples {
+		drop = len(t.samples) - maxSLOSamples + 1
+	}
+	t.samples = append(t.samples[drop:], sample)
+
+	if len(t.samples) < t.slo.MinRequests {
+		return SLOBreach{}, false
+	}
+
+	latencies := make([]time.Duration, len(t.samples))
+	failures := 0
+	for i, s := range t.samples {
+		latencies[i] = s.latency
+		if s.failed {
+			failures++
+		}
+	}
+	slices.Sort(latencies)
+	idx := int(math.Ceil(t.slo.Quantile*float64(len(latencies)))) - 1
+	observed := latencies[max(idx, 0)]
+	errorRate := float64(failures) / float64(len(t.samples))
+
+	breached := (t.slo.Latency > 0 && observed > t.slo.Latency) ||
+		(t.slo.MaxErrorRate > 0 && errorRate > t.slo.MaxErrorRate)
+	if breached == t.breached {
+		return SLOBreach{}, false
+	}
+	t.breached = breached
+
+	if t.slo.Operation != "" {
+		operation = t.slo.Operation
+	}
+	return SLOBreach{
+		SLO:       t.slo,
+		Operation: operation,
+		Latency:   observed,
+		ErrorRate: errorRate,
+		Requests:  len(t.samples),
+		Recovered: !breached,
+	}, true
+}
+
+// observeSLO feeds the outcome of the request to the client's objectives
+func (r *request) observeSLO(start time.Time) {
+	failed := r.err != nil || (r.response != nil && r.response.StatusCode >= 500)
+	r.client.slo.observe(r.method+" "+r.endpoint, r.client.clock.Now().Sub(start), failed)
+}
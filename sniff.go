@@ -0,0 +1,74 @@
+package goclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrUnsupportedContentType is returned by Into when a response without a
+// Content-Type is detected as text or binary data and the target is not a
+// *string or *[]byte
+var ErrUnsupportedContentType = errors.New("goclient: cannot decode response content type")
+
+// SniffContentType detects the media type of a body: application/json,
+// application/xml, a text/ type or application/octet-stream
+func SniffContentType(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return "text/plain"
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "application/json"
+	}
+
+	detected := mediaType(http.DetectContentType(body))
+	switch {
+	case detected == "text/xml" || (trimmed[0] == '<' && detected == "text/plain"):
+		return "application/xml"
+	case json.Valid(trimmed):
+		// Scalars such as numbers, strings and booleans
+		return "application/json"
+	}
+	return detected
+}
+
+// contentTypeFor returns the content type used to decode a response that
+// declared contentType
+func (c *client) contentTypeFor(contentType string, body []byte) string {
+	if contentType != "" {
+		return contentType
+	}
+	if c.sniff {
+		if detected := SniffContentType(body); detected != "application/octet-stream" || c.defaultType == "" {
+			return detected
+		}
+	}
+	return c.defaultType
+}
+
+// decodeUntyped decodes a body whose type was sniffed or defaulted and has
+// no registered codec. ok is false for JSON, which takes the usual path.
+func decodeUntyped(contentType string, body []byte, v interface{}) (ok bool, err error) {
+	media := mediaType(contentType)
+	switch {
+	case media == "" || media == "application/json":
+		return false, nil
+	case media == "application/xml" || strings.HasSuffix(media, "+xml"):
+		return true, xml.Unmarshal(body, v)
+	}
+
+	switch target := v.(type) {
+	case *[]byte:
+		*target = append((*target)[:0], body...)
+	case *string:
+		*target = string(body)
+	default:
+		return true, fmt.Errorf("%w %s into %T", ErrUnsupportedContentType, media, v)
+	}
+	return true, nil
+}
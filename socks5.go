@@ -0,0 +1,273 @@
+package goclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// WithSOCKS5 sends every request through the SOCKS5 proxy at addr. Host
+// names are resolved by the proxy. Username and password may be empty when
+// the proxy does not require authentication.
+func WithSOCKS5(addr, username, password string) Option {
+	return func(c *Config) {
+		u := &url.URL{Scheme: "socks5h", Host: addr}
+		if username != "" {
+			u.User = url.UserPassword(username, password)
+		}
+		c.ProxyProvider = &RoundRobinProxyProvider{
+			proxies:     []*url.URL{u},
+			cooldown:    30 * time.Second,
+			failedUntil: make([]time.Time, 1),
+		}
+	}
+}
+
+const (
+	socksVersion      = 5
+	socksAuthNone     = 0x00
+	socksAuthPassword = 0x02
+	socksNoAcceptable = 0xff
+	socksCmdAssociate = 0x03
+	socksAtypIPv4     = 0x01
+	socksAtypDomain   = 0x03
+	socksAtypIPv6     = 0x04
+)
+
+// socksHandshake negotiates authentication on conn and issues cmd for addr,
+// returning the address bound by the proxy
+func socksHandshake(conn net.Conn, cmd byte, addr, username, password string) (*net.UDPAddr, error) {
+	methods := []byte{socksAuthNone}
+	if username != "" {
+		methods = append(methods, socksAuthPassword)
+	}
+	greeting := append([]byte{socksVersion, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return nil, err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != socksVersion {
+		return nil, fmt.Errorf("socks5: unexpected version %d", reply[0])
+	}
+	switch reply[1] {
+	case socksAuthNone:
+	case socksAuthPassword:
+		if len(username) > 255 || len(password) > 255 {
+			return nil, errors.New("socks5: credentials too long")
+		}
+		auth := []byte{1, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return nil, err
+		}
+		if reply[1] != 0 {
+			return nil, errors.New("socks5: authentication failed")
+		}
+	case socksNoAcceptable:
+		return nil, errors.New("socks5: no acceptable authentication method")
+	default:
+		return nil, fmt.Errorf("socks5: unsupported authentication method %d", reply[1])
+	}
+
+	header, err := socksAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, cmd, 0}, header...)); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 3)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return nil, err
+	}
+	if head[1] != 0 {
+		return nil, fmt.Errorf("socks5: request failed with code %d", head[1])
+	}
+	bound, _, err := readSocksAddr(conn)
+	if err != nil {
+		return nil, err
+	}
+	return bound, nil
+}
+
+// socksAddr encodes addr as a SOCKS5 address and port
+func socksAddr(addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("socks5: invalid port %q", portStr)
+	}
+
+	var b []byte
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("socks5: host name too long")
+		}
+		b = append([]byte{socksAtypDomain, byte(len(host))}, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		b = append([]byte{socksAtypIPv4}, ip4...)
+	} else {
+		b = append([]byte{socksAtypIPv6}, ip...)
+	}
+	return binary.BigEndian.AppendUint16(b, uint16(port)), nil
+}
+
+// readSocksAddr decodes a SOCKS5 address and port from r. Domain addresses
+// are returned as a string with a nil *net.UDPAddr.
+func readSocksAddr(r io.Reader) (*net.UDPAddr, string, error) {
+	atyp := make([]byte, 1)
+	if _, err := io.ReadFull(r, atyp); err != nil {
+		return nil, "", err
+	}
+
+	var host []byte
+	switch atyp[0] {
+	case socksAtypIPv4:
+		host = make([]byte, net.IPv4len)
+	case socksAtypIPv6:
+		host = make([]byte, net.IPv6len)
+	case socksAtypDomain:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(r, n); err != nil {
+			return nil, "", err
+		}
+		host = make([]byte, n[0])
+	default:
+		return nil, "", fmt.Errorf("socks5: unknown address type %d", atyp[0])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, host); err != nil {
+		return nil, "", err
+	}
+	if _, err := io.ReadFull(r, port); err != nil {
+		return nil, "", err
+	}
+
+	p := int(binary.BigEndian.Uint16(port))
+	if atyp[0] == socksAtypDomain {
+		return nil, net.JoinHostPort(string(host), strconv.Itoa(p)), nil
+	}
+	return &net.UDPAddr{IP: net.IP(host), Port: p}, "", nil
+}
+
+var _ net.PacketConn = (*SOCKS5PacketConn)(nil)
+
+// SOCKS5PacketConn is a UDP association through a SOCKS5 proxy. Datagrams
+// written to it are relayed by the proxy to their destination. The
+// association lasts until Close.
+type SOCKS5PacketConn struct {
+	udp  *net.UDPConn
+	ctrl net.Conn
+}
+
+// DialSOCKS5UDP opens a UDP association through the SOCKS5 proxy at addr
+func DialSOCKS5UDP(ctx context.Context, addr, username, password string) (*SOCKS5PacketConn, error) {
+	var d net.Dialer
+	ctrl, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		ctrl.SetDeadline(deadline)
+	}
+
+	relay, err := socksHandshake(ctrl, socksCmdAssociate, "0.0.0.0:0", username, password)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	ctrl.SetDeadline(time.Time{})
+	if relay == nil {
+		ctrl.Close()
+		return nil, errors.New("socks5: proxy returned a domain relay address")
+	}
+	if relay.IP.IsUnspecified() {
+		relay.IP = ctrl.RemoteAddr().(*net.TCPAddr).IP
+	}
+
+	udp, err := net.DialUDP("udp", nil, relay)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	return &SOCKS5PacketConn{udp: udp, ctrl: ctrl}, nil
+}
+
+// WriteTo sends p to addr through the proxy
+func (c *SOCKS5PacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	header, err := socksAddr(addr.String())
+	if err != nil {
+		return 0, err
+	}
+	packet := append([]byte{0, 0, 0}, header...)
+	if _, err := c.udp.Write(append(packet, p...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ReadFrom reads the next datagram relayed by the proxy
+func (c *SOCKS5PacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	buf := make([]byte, len(p)+262)
+	for {
+		n, err := c.udp.Read(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		// Fragmented datagrams are not supported and are dropped
+		if n < 4 || buf[2] != 0 {
+			continue
+		}
+
+		r := bytes.NewReader(buf[3:n])
+		udpAddr, domain, err := readSocksAddr(r)
+		if err != nil {
+			continue
+		}
+		var from net.Addr = udpAddr
+		if udpAddr == nil {
+			from = socksDomainAddr(domain)
+		}
+		return copy(p, buf[n-r.Len():n]), from, nil
+	}
+}
+
+// Close ends the association
+func (c *SOCKS5PacketConn) Close() error {
+	err := c.udp.Close()
+	if cerr := c.ctrl.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c *SOCKS5PacketConn) LocalAddr() net.Addr                { return c.udp.LocalAddr() }
+func (c *SOCKS5PacketConn) SetDeadline(t time.Time) error      { return c.udp.SetDeadline(t) }
+func (c *SOCKS5PacketConn) SetReadDeadline(t time.Time) error  { return c.udp.SetReadDeadline(t) }
+func (c *SOCKS5PacketConn) SetWriteDeadline(t time.Time) error { return c.udp.SetWriteDeadline(t) }
+
+// socksDomainAddr is a datagram source reported by name
+type socksDomainAddr string
+
+func (a socksDomainAddr) Network() string { return "udp" }
+func (a socksDomainAddr) String() string  { return string(a) }
@@ -0,0 +1,96 @@
+package goclient
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// SplitTarget is a base URL and its share of traffic in a TrafficSplit
+type SplitTarget struct {
+	BaseURL string
+	Weight  int
+}
+
+// TrafficSplit spreads requests across several base URLs in proportion to
+// their weights, e.g. 95 for the stable upstream and 5 for a canary. Weights
+// can be changed at runtime to shift traffic gradually. Set it as
+// Config.TrafficSplit; it replaces Config.BaseURL for relative endpoints.
+// Targets are drawn from the client's Config.Rand, so a seeded source makes
+// the split reproducible. A zero TrafficSplit has no targets and leaves the
+// base URL unchanged until SetTargets is called.
+type TrafficSplit struct {
+	mu      sync.RWMutex
+	targets []SplitTarget
+}
+
+// NewTrafficSplit returns a split across targets
+func NewTrafficSplit(targets ...SplitTarget) (*TrafficSplit, error) {
+	s := &TrafficSplit{}
+	if err := s.SetTargets(targets...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetTargets atomically replaces the targets and their weights
+func (s *TrafficSplit) SetTargets(targets ...SplitTarget) error {
+	total := 0
+	for _, t := range targets {
+		if t.Weight < 0 {
+			return fmt.Errorf("traffic split: negative weight for %s", t.BaseURL)
+		}
+		total += t.Weight
+	}
+	if total == 0 {
+		return errors.New("traffic split: at least one target needs a positive weight")
+	}
+
+	s.mu.Lock()
+	s.targets = append([]SplitTarget(nil), targets...)
+	s.mu.Unlock()
+	return nil
+}
+
+// SetWeight changes the weight of an existing target
+func (s *TrafficSplit) SetWeight(baseURL string, weight int) error {
+	targets := s.Targets()
+	for i := range targets {
+		if targets[i].BaseURL == baseURL {
+			targets[i].Weight = weight
+			return s.SetTargets(targets...)
+		}
+	}
+	return fmt.Errorf("traffic split: unknown target %s", baseURL)
+}
+
+// Targets returns a copy of the current targets
+func (s *TrafficSplit) Targets() []SplitTarget {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]SplitTarget(nil), s.targets...)
+}
+
+// pick chooses a base URL with probability proportional to its weight, or
+// fallback when the split has no targets yet
+func (s *TrafficSplit) pick(rand RandSource, fallback string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.targets) == 0 {
+		return fallback
+	}
+
+	total := 0
+	for _, t := range s.targets {
+		total += t.Weight
+	}
+
+	n := int(rand.Float64() * float64(total))
+	for _, t := range s.targets {
+		if n < t.Weight {
+			return t.BaseURL
+		}
+		n -= t.Weight
+	}
+	return s.targets[len(s.targets)-1].BaseURL
+}
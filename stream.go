@@ -0,0 +1,73 @@
+package goclient
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// IntoWriter copies a successful response body to w as it arrives instead of
+// buffering it in Response.Body, so large downloads use constant memory.
+// Error responses are buffered and returned as by Into. Config.Timeout
+// bounds the whole transfer, so raise it or use a context for long downloads.
+func (r *request) IntoWriter(w io.Writer) error {
+	streamed := false
+	r.consume = func(_ *http.Response, body io.Reader) error {
+		streamed = true
+		_, err := io.Copy(w, body)
+		return err
+	}
+
+	resp, err := r.Result()
+	if err != nil {
+		return r.decodeInto(resp, err, nil)
+	}
+	if streamed {
+		return nil
+	}
+	// Cache hits and error statuses allowed by DisableStatusError arrive buffered
+	_, err = w.Write(resp.Body)
+	return err
+}
+
+type streamResult struct {
+	resp *Response
+	err  error
+}
+
+// Stream sends the request and returns the response body for the caller to
+// read, without buffering it. It returns once a successful response has
+// arrived or the request has failed. The caller must close the body; closing
+// it early abandons the rest of the transfer.
+func (r *request) Stream() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	started := make(chan struct{})
+	r.consume = func(_ *http.Response, body io.Reader) error {
+		close(started)
+		_, err := io.Copy(pw, body)
+		return err
+	}
+
+	done := make(chan streamResult, 1)
+	go func() {
+		resp, err := r.Result()
+		pw.CloseWithError(err)
+		done <- streamResult{resp: resp, err: err}
+	}()
+
+	select {
+	case <-started:
+		return pr, nil
+	case res := <-done:
+		select {
+		case <-started:
+			// The body was streamed and has already been read to the end
+			return pr, nil
+		default:
+		}
+		if res.err != nil {
+			return nil, res.err
+		}
+		return io.NopCloser(bytes.NewReader(res.resp.Body)), nil
+	}
+}
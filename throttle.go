@@ -0,0 +1,161 @@
+package goclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ThrottleConfig enables adaptive throttling: once a host answers 429 the
+// client caps the request rate to it, halves the cap on every further 429
+// and raises it step by step after each quiet cooldown (AIMD) until the
+// throttle is lifted
+type ThrottleConfig struct {
+	// InitialRate is the rate in requests per second applied after the first 429, default 10
+	InitialRate float64
+	// MinRate is the lowest rate the throttle backs off to, default 0.1
+	MinRate float64
+	// DecreaseFactor multiplies the rate on each further 429, default 0.5
+	DecreaseFactor float64
+	// IncreaseStep is added to the rate after each cooldown without a 429, default 1
+	IncreaseStep float64
+	// Cooldown is the quiet period between rate increases, default 5s
+	Cooldown time.Duration
+}
+
+// ThrottleState reports the adaptive throttle of one host
+type ThrottleState struct {
+	Host string
+	// Throttled is false once the rate has recovered past InitialRate
+	Throttled bool
+	// Rate is the current cap in requests per second
+	Rate float64
+	// TooManyRequests counts the 429 responses received from the host
+	TooManyRequests int
+}
+
+type hostThrottle struct {
+	state      ThrottleState
+	next       time.Time
+	lastChange time.Time
+}
+
+type throttler struct {
+	cfg   ThrottleConfig
+	clock Clock
+
+	mu    sync.Mutex
+	hosts map[string]*hostThrottle
+}
+
+func newThrottler(cfg ThrottleConfig, clock Clock) *throttler {
+	if cfg.InitialRate <= 0 {
+		cfg.InitialRate = 10
+	}
+	if cfg.MinRate <= 0 {
+		cfg.MinRate = 0.1
+	}
+	if cfg.DecreaseFactor <= 0 || cfg.DecreaseFactor >= 1 {
+		cfg.DecreaseFactor = 0.5
+	}
+	if cfg.IncreaseStep <= 0 {
+		cfg.IncreaseStep = 1
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 5 * time.Second
+	}
+	return &throttler{cfg: cfg, clock: clock, hosts: make(map[string]*hostThrottle)}
+}
+
+func (t *throttler) host(name string) *hostThrottle {
+	h, ok := t.hosts[name]
+	if !ok {
+		h = &hostThrottle{state: ThrottleState{Host: name}}
+		t.hosts[name] = h
+	}
+	return h
+}
+
+// reserve books the next send slot for host and returns how long to wait
+func (t *throttler) reserve(name string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.host(name)
+	if !h.state.Throttled {
+		return 0
+	}
+
+	now := t.clock.Now()
+	start := h.next
+	if start.Before(now) {
+		start = now
+	}
+	h.next = start.Add(time.Duration(float64(time.Second) / h.state.Rate))
+	return start.Sub(now)
+}
+
+// observe adjusts the host's rate after a response
+func (t *throttler) observe(name string, status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.host(name)
+	now := t.clock.Now()
+
+	if status == http.StatusTooManyRequests {
+		h.state.TooManyRequests++
+		if !h.state.Throttled {
+			h.state.Throttled = true
+			h.state.Rate = t.cfg.InitialRate
+		} else if h.state.Rate *= t.cfg.DecreaseFactor; h.state.Rate < t.cfg.MinRate {
+			h.state.Rate = t.cfg.MinRate
+		}
+		h.lastChange = now
+		return
+	}
+
+	if h.state.Throttled && now.Sub(h.lastChange) >= t.cfg.Cooldown {
+		h.state.Rate += t.cfg.IncreaseStep
+		h.lastChange = now
+		if h.state.Rate > t.cfg.InitialRate {
+			h.state.Throttled = false
+			h.state.Rate = 0
+		}
+	}
+}
+
+func (t *throttler) stats() map[string]ThrottleState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make(map[string]ThrottleState, len(t.hosts))
+	for name, h := range t.hosts {
+		stats[name] = h.state
+	}
+	return stats
+}
+
+func (t *throttler) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		host := req.URL.Host
+		if err := t.clock.Sleep(req.Context(), t.reserve(host)); err != nil {
+			return nil, err
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err == nil {
+			t.observe(host, resp.StatusCode)
+		}
+		return resp, err
+	})
+}
+
+// ThrottleStats returns the adaptive throttle state of every host contacted
+// so far, or nil when Config.Throttle is not set
+func (c *client) ThrottleStats() map[string]ThrottleState {
+	if c.throttler == nil {
+		return nil
+	}
+	return c.throttler.stats()
+}
@@ -0,0 +1,85 @@
+package goclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSConfig configures TLS for the transport built by New. It has no effect
+// when Config.Interceptor is set.
+type TLSConfig struct {
+	// RootCAs verifies server certificates, default the system pool
+	RootCAs *x509.CertPool
+	// CAFile and CAPEM hold PEM certificates added to RootCAs, or to an
+	// empty pool when RootCAs is nil so only they are trusted
+	CAFile string
+	CAPEM  []byte
+	// CertFile and KeyFile hold a PEM client certificate and key for mutual
+	// TLS. Certificates may hold already loaded ones instead.
+	CertFile     string
+	KeyFile      string
+	Certificates []tls.Certificate
+	// ServerName overrides the name verified in server certificates
+	ServerName string
+	// InsecureSkipVerify disables server certificate verification. Only use
+	// it for testing.
+	InsecureSkipVerify bool
+	// MinVersion is the lowest accepted version, default TLS 1.2
+	MinVersion uint16
+	// CipherSuites restricts the TLS 1.2 cipher suites; TLS 1.3 suites are
+	// not configurable
+	CipherSuites []uint16
+}
+
+// WithTLS sets the TLS configuration of the transport
+func WithTLS(cfg TLSConfig) Option {
+	return func(c *Config) {
+		c.TLS = &cfg
+	}
+}
+
+// build loads the certificates and returns the crypto/tls configuration
+func (c *TLSConfig) build() (*tls.Config, error) {
+	out := &tls.Config{
+		RootCAs:            c.RootCAs,
+		Certificates:       append([]tls.Certificate(nil), c.Certificates...),
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         c.MinVersion,
+		CipherSuites:       c.CipherSuites,
+	}
+	if out.MinVersion == 0 {
+		out.MinVersion = tls.VersionTLS12
+	}
+
+	if c.CAFile != "" || len(c.CAPEM) > 0 {
+		if out.RootCAs == nil {
+			out.RootCAs = x509.NewCertPool()
+		} else {
+			out.RootCAs = out.RootCAs.Clone()
+		}
+		pem := c.CAPEM
+		if c.CAFile != "" {
+			data, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("goclient: read CA file: %w", err)
+			}
+			pem = append(append([]byte(nil), pem...), data...)
+		}
+		if !out.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("goclient: no CA certificates found")
+		}
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("goclient: load client certificate: %w", err)
+		}
+		out.Certificates = append(out.Certificates, cert)
+	}
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package goclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TokenProvider supplies the bearer token for each request, for tokens that
+// rotate or are fetched from a secret store such as Vault
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenProviderFunc adapts an ordinary function to the TokenProvider interface
+type TokenProviderFunc func(ctx context.Context) (string, error)
+
+// Token implements TokenProvider interface
+func (f TokenProviderFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken is a TokenProvider that always returns the same token
+type StaticToken string
+
+// Token implements TokenProvider interface
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// TokenRefresher is implemented by token providers that can replace a token
+// the server rejected. It is called when a request fails with 401, and the
+// request is then retried once with the new token.
+type TokenRefresher interface {
+	RefreshToken(ctx context.Context, rejected string) error
+}
+
+// OnUnauthorized registers a callback run when a request fails with 401,
+// for example to obtain new credentials and install them with
+// SetBearerToken. The request is retried once after refresh succeeds.
+func (c *client) OnUnauthorized(refresh func(ctx context.Context) error) Client {
+	c.onUnauthorized = refresh
+	return c
+}
+
+// SetTokenProvider authenticates every request with a bearer token from p.
+// A nil provider disables bearer authentication.
+func (c *client) SetTokenProvider(p TokenProvider) Client {
+	c.tokenProvider = p
+	return c
+}
+
+// SetTokenProvider overrides the client's bearer token source for this request
+func (r *request) SetTokenProvider(p TokenProvider) RequestBuilder {
+	r.tokenProvider = p
+	return r
+}
+
+// bearerToken returns the token for this request, or "" when bearer
+// authentication is not configured
+func (r *request) bearerToken() (string, error) {
+	p := r.tokenProviderFor()
+	if p == nil {
+		return "", nil
+	}
+	return p.Token(r.ctx)
+}
+
+// tokenProviderFor returns the provider used for this request
+func (r *request) tokenProviderFor() TokenProvider {
+	if r.tokenProvider != nil {
+		return r.tokenProvider
+	}
+	return r.client.tokenProvider
+}
+
+// reauthenticate refreshes the credentials after req was rejected with 401
+// and prepares req to be sent again. It reports false when no refresh is
+// configured or the body cannot be replayed.
+func (r *request) reauthenticate(req *http.Request) (bool, error) {
+	rejected := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+
+	switch refresher, ok := r.tokenProviderFor().(TokenRefresher); {
+	case r.client.onUnauthorized != nil:
+		if err := r.client.onUnauthorized(r.ctx); err != nil {
+			return false, fmt.Errorf("failed to refresh credentials: %w", err)
+		}
+	case ok:
+		if err := refresher.RefreshToken(r.ctx, rejected); err != nil {
+			return false, fmt.Errorf("failed to refresh token: %w", err)
+		}
+	default:
+		return false, nil
+	}
+
+	if RewindBody(req) != nil {
+		return false, nil
+	}
+
+	if err := r.authenticate(req); err != nil {
+		return false, err
+	}
+	return true, nil
+}
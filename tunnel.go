@@ -0,0 +1,179 @@
+package goclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// TunnelError is returned when a proxy refuses a CONNECT request
+type TunnelError struct {
+	Proxy      string
+	Addr       string
+	StatusCode int
+	Status     string
+}
+
+func (e *TunnelError) Error() string {
+	return fmt.Sprintf("goclient: proxy %s refused tunnel to %s: %s", e.Proxy, e.Addr, e.Status)
+}
+
+// WithTunnel sends every request through a CONNECT tunnel opened by the HTTP
+// proxy at proxyURL, including plain HTTP requests that a proxy would
+// otherwise forward itself. Credentials in the URL are sent as Basic proxy
+// authentication. It cannot be combined with Config.ProxyURL, a
+// ProxyProvider or a local address, and requests using SetProxy or
+// SetLocalAddr fail.
+func WithTunnel(proxyURL string) Option {
+	return func(c *Config) {
+		c.TunnelProxy = proxyURL
+	}
+}
+
+// DialTunnel opens a CONNECT tunnel through the HTTP or HTTPS proxy at
+// proxyURL to addr, a host:port that need not serve HTTP, and returns the
+// connection to it
+func DialTunnel(ctx context.Context, proxyURL, addr string) (net.Conn, error) {
+	proxy, err := parseTunnelProxy(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	return dialTunnel(ctx, proxy, addr)
+}
+
+func parseTunnelProxy(proxyURL string) (*url.URL, error) {
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", proxyURL, err)
+	}
+	if proxy.Scheme != "http" && proxy.Scheme != "https" {
+		return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q", proxyURL, proxy.Scheme)
+	}
+	return proxy, nil
+}
+
+func dialTunnel(ctx context.Context, proxy *url.URL, addr string) (net.Conn, error) {
+	host := proxy.Host
+	if proxy.Port() == "" {
+		port := "80"
+		if proxy.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(proxy.Hostname(), port)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	// Unblock the handshake when ctx ends
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, tunnelErr(ctx, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, tunnelErr(ctx, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &TunnelError{Proxy: proxy.Redacted(), Addr: addr, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if !stop() {
+		return nil, ctx.Err()
+	}
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// tunnelErr prefers the context error over the one caused by closing the
+// connection
+func tunnelErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// bufferedConn returns bytes the proxy sent right after its response before
+// reading from the connection again
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// tunnelTransport makes rt dial every connection through a CONNECT tunnel
+func tunnelTransport(rt http.RoundTripper, proxyURL string) http.RoundTripper {
+	proxy, err := parseTunnelProxy(proxyURL)
+	t, ok := rt.(*http.Transport)
+	if err == nil && !ok {
+		err = fmt.Errorf("goclient: a tunnel requires an *http.Transport")
+	}
+	if err != nil {
+		return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, err
+		})
+	}
+
+	t = t.Clone()
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialTunnel(ctx, proxy, addr)
+	}
+	return &tunnel{t}
+}
+
+// tunnel rejects requests asking for a proxy or source address of their
+// own, which its connections cannot honour. Requests routed by a Resolver
+// have the resolved address written into their URL.
+type tunnel struct {
+	*http.Transport
+}
+
+func (t *tunnel) RoundTrip(req *http.Request) (*http.Response, error) {
+	if info, _ := RequestInfoFromContext(req.Context()); info.Proxy != "" || info.LocalAddr != "" {
+		return nil, errors.New("goclient: requests through a tunnel cannot set a proxy or local address")
+	}
+	return t.Transport.RoundTrip(resolvedURL(req))
+}
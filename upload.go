@@ -0,0 +1,180 @@
+package goclient
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// formPart is a field or file of a multipart/form-data request body
+type formPart struct {
+	field    string
+	value    string
+	filename string
+	path     string
+	reader   io.Reader
+}
+
+// SetFile uploads the file at path as a multipart/form-data file field. The
+// file is opened when the body is sent, and again if the request is retried.
+func (r *request) SetFile(field, path string) RequestBuilder {
+	r.form = append(r.form, formPart{field: field, filename: filepath.Base(path), path: path})
+	return r
+}
+
+// SetFileReader uploads the contents of reader as a multipart/form-data file
+// field. The reader is consumed once, so the request cannot be retried.
+func (r *request) SetFileReader(field, filename string, reader io.Reader) RequestBuilder {
+	r.form = append(r.form, formPart{field: field, filename: filename, reader: reader})
+	return r
+}
+
+// SetMultipartFields adds multipart/form-data value fields, sent in key order
+func (r *request) SetMultipartFields(fields map[string]string) RequestBuilder {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.form = append(r.form, formPart{field: k, value: fields[k]})
+	}
+	return r
+}
+
+// applyForm replaces the request body with a streamed multipart/form-data
+// document built from the form parts. Bodies made only of fields and files
+// can be rewound for retries.
+func (r *request) applyForm() error {
+	parts := r.form
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	var rewind func() (io.Reader, error)
+	replayable := true
+	for _, p := range parts {
+		if p.reader != nil {
+			replayable = false
+		}
+	}
+	if replayable {
+		rewind = func() (io.Reader, error) {
+			return formBody(parts, boundary)
+		}
+	}
+
+	body, err := formBody(parts, boundary)
+	if err != nil {
+		return err
+	}
+	r.SetBodyStream(body, rewind)
+	r.SetHeader("Content-Type", "multipart/form-data; boundary="+boundary)
+	return nil
+}
+
+// formBody lays out the multipart document as a sequence of readers: part
+// headers and fields are encoded up front while file contents are read in
+// place, so files are never buffered in memory. Closing the body closes any
+// file still open.
+func formBody(parts []formPart, boundary string) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+
+	var readers []io.Reader
+	var files formFiles
+	for _, p := range parts {
+		if p.path == "" && p.reader == nil {
+			if err := mw.WriteField(p.field, p.value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if _, err := mw.CreateFormFile(p.field, p.filename); err != nil {
+			return nil, err
+		}
+		readers = append(readers, bytes.NewReader(bytes.Clone(buf.Bytes())))
+		buf.Reset()
+
+		if p.reader != nil {
+			readers = append(readers, p.reader)
+		} else {
+			f := &lazyFile{path: p.path}
+			readers = append(readers, f)
+			files = append(files, f)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	readers = append(readers, bytes.NewReader(buf.Bytes()))
+
+	return readCloser{io.MultiReader(readers...), files}, nil
+}
+
+// formFiles closes the files of a multipart body
+type formFiles []*lazyFile
+
+func (files formFiles) Close() error {
+	var first error
+	for _, f := range files {
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// lazyFile opens a file on first read and closes it at the end of the file,
+// on any read error, or when the body is closed. The transport may close the
+// body while another goroutine is still reading it.
+type lazyFile struct {
+	path string
+
+	mu     sync.Mutex
+	f      *os.File
+	closed bool
+}
+
+func (l *lazyFile) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return 0, os.ErrClosed
+	}
+	if l.f == nil {
+		f, err := os.Open(l.path)
+		if err != nil {
+			return 0, err
+		}
+		l.f = f
+	}
+	n, err := l.f.Read(p)
+	if err != nil {
+		l.close()
+	}
+	return n, err
+}
+
+func (l *lazyFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.close()
+}
+
+func (l *lazyFile) close() error {
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,193 @@
+package goclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeKind classifies a difference between two snapshots
+type ChangeKind int
+
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a single structural difference between two JSON documents. Path
+// uses dot notation with numeric array indices, e.g. "flags.0.enabled".
+type Change struct {
+	Path string
+	Kind ChangeKind
+	Old  interface{}
+	New  interface{}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s: %v -> %v", c.Kind, c.Path, c.Old, c.New)
+}
+
+// WatchConfig configures Watch
+type WatchConfig struct {
+	// Interval between polls, default 30s
+	Interval time.Duration
+	// IgnoreFields lists dot paths removed before comparing, e.g. "meta.generatedAt"
+	IgnoreFields []string
+	// OnError is called when a poll fails; the watch continues
+	OnError func(err error)
+	// Clock times the interval, default the system clock
+	Clock Clock
+}
+
+// Watch polls the request produced by build on an interval and calls
+// onChange with the structural diff whenever the normalized JSON response
+// changes. The first successful response is the baseline. It blocks until ctx
+// is done.
+func Watch(ctx context.Context, build func() RequestBuilder, cfg WatchConfig, onChange func(changes []Change)) error {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+
+	var previous interface{}
+	haveBaseline := false
+
+	poll := func() {
+		resp, err := build().Result()
+		if err == nil {
+			var current interface{}
+			if current, err = normalizeSnapshot(resp.Body, cfg.IgnoreFields); err == nil {
+				if haveBaseline {
+					if changes := DiffJSON(previous, current); len(changes) > 0 {
+						onChange(changes)
+					}
+				}
+				previous, haveBaseline = current, true
+				return
+			}
+		}
+		if cfg.OnError != nil {
+			cfg.OnError(err)
+		}
+	}
+
+	poll()
+	for {
+		if err := cfg.Clock.Sleep(ctx, cfg.Interval); err != nil {
+			return err
+		}
+		poll()
+	}
+}
+
+// normalizeSnapshot decodes body and strips the ignored fields
+func normalizeSnapshot(body []byte, ignore []string) (interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	for _, p := range ignore {
+		removePath(doc, strings.Split(p, "."))
+	}
+	return doc, nil
+}
+
+func removePath(doc interface{}, path []string) {
+	obj, ok := doc.(map[string]interface{})
+	if !ok || len(path) == 0 {
+		return
+	}
+	if len(path) == 1 {
+		delete(obj, path[0])
+		return
+	}
+	removePath(obj[path[0]], path[1:])
+}
+
+// DiffJSON returns the structural differences between two decoded JSON
+// documents, ordered by path
+func DiffJSON(old, new interface{}) []Change {
+	var changes []Change
+	diffValue("", old, new, &changes)
+	return changes
+}
+
+func diffValue(path string, old, new interface{}, changes *[]Change) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(o)+len(n))
+			for k := range o {
+				keys = append(keys, k)
+			}
+			for k := range n {
+				if _, ok := o[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				ov, inOld := o[k]
+				nv, inNew := n[k]
+				child := joinPath(path, k)
+				switch {
+				case !inNew:
+					*changes = append(*changes, Change{Path: child, Kind: ChangeRemoved, Old: ov})
+				case !inOld:
+					*changes = append(*changes, Change{Path: child, Kind: ChangeAdded, New: nv})
+				default:
+					diffValue(child, ov, nv, changes)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			for i := 0; i < len(o) || i < len(n); i++ {
+				child := joinPath(path, strconv.Itoa(i))
+				switch {
+				case i >= len(n):
+					*changes = append(*changes, Change{Path: child, Kind: ChangeRemoved, Old: o[i]})
+				case i >= len(o):
+					*changes = append(*changes, Change{Path: child, Kind: ChangeAdded, New: n[i]})
+				default:
+					diffValue(child, o[i], n[i], changes)
+				}
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, Change{Path: path, Kind: ChangeModified, Old: old, New: new})
+	}
+}
+
+func joinPath(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	return parent + "." + child
+}